@@ -0,0 +1,247 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/gofiber/fiber/v2"
+)
+
+const indexBatchSize = 1000
+
+// uniqueIndex maps a field of T to the primary key of the record holding it,
+// under idx/<name>/<value>. Index keys are written in the same transaction
+// as the record, so they can never disagree with it.
+type uniqueIndex[T any] struct {
+	name string
+	// field names the indexed field in conflict errors and lookups.
+	field string
+	// value returns the indexed value. Empty values are not indexed.
+	value func(*T) string
+	// normalize, when set, is applied to stored and looked up values alike,
+	// for instance to match emails case-insensitively.
+	normalize func(string) string
+}
+
+func (i *uniqueIndex[T]) valueOf(record *T) string {
+	if record == nil {
+		return ""
+	}
+	return i.normalized(i.value(record))
+}
+
+func (i *uniqueIndex[T]) normalized(value string) string {
+	if i.normalize == nil {
+		return value
+	}
+	return i.normalize(value)
+}
+
+func (i *uniqueIndex[T]) key(value string) []byte {
+	return []byte(fmt.Sprintf("idx/%s/%s", i.name, value))
+}
+
+func (i *uniqueIndex[T]) builtKey() []byte {
+	return []byte(fmt.Sprintf("meta/idx/%s", i.name))
+}
+
+func (i *uniqueIndex[T]) progressKey() []byte {
+	return []byte(fmt.Sprintf("meta/idx-build/%s", i.name))
+}
+
+// lookup returns the primary key stored for value, or nil when no record
+// has it.
+func (i *uniqueIndex[T]) lookup(txn *badger.Txn, value string) ([]byte, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	item, err := txn.Get(i.key(value))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+// update moves the index key of the record at primaryKey from its old to
+// its new value. Pass a nil old record on insert and a nil new record on
+// delete. It fails when another record already has the new value.
+func (i *uniqueIndex[T]) update(txn *badger.Txn, primaryKey []byte, old *T, new *T) error {
+	oldValue, newValue := i.valueOf(old), i.valueOf(new)
+	if oldValue == newValue {
+		return nil
+	}
+
+	if newValue != "" {
+		existing, err := i.lookup(txn, newValue)
+		if err != nil {
+			return err
+		}
+		if existing != nil && !bytes.Equal(existing, primaryKey) {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s already taken", i.field))
+		}
+	}
+
+	if oldValue != "" {
+		// A record skipped as a duplicate by build has no key of its own,
+		// and the one under its value belongs to another record.
+		existing, err := i.lookup(txn, oldValue)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(existing, primaryKey) {
+			if err := txn.Delete(i.key(oldValue)); err != nil {
+				return err
+			}
+		}
+	}
+	if newValue != "" {
+		return txn.Set(i.key(newValue), primaryKey)
+	}
+	return nil
+}
+
+// indexSet holds every index kept for a record type.
+type indexSet[T any] []*uniqueIndex[T]
+
+func (s indexSet[T]) update(txn *badger.Txn, primaryKey []byte, old *T, new *T) error {
+	for _, index := range s {
+		if err := index.update(txn, primaryKey, old, new); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get returns the record whose field has value, loaded with load, or nil
+// when no record has it.
+func (s indexSet[T]) get(txn *badger.Txn, field string, value string, load func(*badger.Txn, []byte) (*T, error)) (*T, error) {
+	for _, index := range s {
+		if index.field != field {
+			continue
+		}
+
+		key, err := index.lookup(txn, index.normalized(value))
+		if err != nil || key == nil {
+			return nil, err
+		}
+		return load(txn, key)
+	}
+	return nil, fmt.Errorf("no index on %s", field)
+}
+
+// build indexes records written before an index existed. Each index is only
+// built once; a marker key records that it is complete.
+func (s indexSet[T]) build(db *badger.DB, prefix []byte, decode func([]byte) (T, error), batchSize int) error {
+	for _, index := range s {
+		if err := index.build(db, prefix, decode, batchSize); err != nil {
+			return fmt.Errorf("index %s: %w", index.name, err)
+		}
+	}
+	return nil
+}
+
+// build indexes batchSize records per transaction, so stores of any size stay
+// under Badger's transaction limit. Each batch saves the last key it covered,
+// where an interrupted build picks up again, and the marker is only set once
+// the last batch is written.
+//
+// Records whose value another record already holds are left out of the
+// index and logged rather than failing the build, so existing duplicates
+// do not keep the server from starting. They can be found again by
+// changing the field on one of the records.
+func (i *uniqueIndex[T]) build(db *badger.DB, prefix []byte, decode func([]byte) (T, error), batchSize int) error {
+	for {
+		done := false
+		err := db.Update(func(txn *badger.Txn) error {
+			if _, err := txn.Get(i.builtKey()); err == nil {
+				done = true
+				return nil
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+
+			var after []byte
+			if item, err := txn.Get(i.progressKey()); err == nil {
+				if after, err = item.ValueCopy(nil); err != nil {
+					return err
+				}
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+
+			it := txn.NewIterator(badger.IteratorOptions{
+				PrefetchValues: true,
+				PrefetchSize:   100,
+				Prefix:         prefix,
+			})
+			defer it.Close()
+
+			start := prefix
+			if after != nil {
+				start = after
+			}
+
+			var last []byte
+			read := 0
+			for it.Seek(start); it.Valid() && read < batchSize; it.Next() {
+				item := it.Item()
+				if after != nil && bytes.Equal(item.Key(), after) {
+					continue
+				}
+
+				value, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				record, err := decode(value)
+				if err != nil {
+					return err
+				}
+				key := item.KeyCopy(nil)
+				if err := i.insert(txn, key, &record); err != nil {
+					return err
+				}
+
+				last = key
+				read++
+			}
+
+			if last != nil {
+				return txn.Set(i.progressKey(), last)
+			}
+
+			done = true
+			if err := txn.Delete(i.progressKey()); err != nil {
+				return err
+			}
+			return txn.Set(i.builtKey(), nil)
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// insert indexes a record for build, skipping it when another record
+// already has its value.
+func (i *uniqueIndex[T]) insert(txn *badger.Txn, primaryKey []byte, record *T) error {
+	value := i.valueOf(record)
+	if value == "" {
+		return nil
+	}
+
+	existing, err := i.lookup(txn, value)
+	if err != nil {
+		return err
+	}
+	if existing != nil && !bytes.Equal(existing, primaryKey) {
+		log.Printf("index %s: skipping %s, %s %q is already used by %s", i.name, primaryKey, i.field, value, existing)
+		return nil
+	}
+	return txn.Set(i.key(value), primaryKey)
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/dgraph-io/badger/v3"
+)
+
+type LoginAttempt struct {
+	Failures    int64 `json:"failures"`
+	LockedUntil int64 `json:"lockedUntil"`
+}
+
+var loginAttemptRecord = utils.NewRecord[LoginAttempt](1)
+
+func init() {
+	gob.Register(LoginAttempt{})
+}
+
+// maxLoginFailureRetries bounds how often RecordLoginFailure retries after
+// losing a write conflict to a concurrent failure for the same key.
+const maxLoginFailureRetries = 50
+
+func loginAttemptKey(key string) []byte {
+	return []byte(fmt.Sprintf("login_attempt/%s", key))
+}
+
+func getLoginAttempt(txn *badger.Txn, key string) (*LoginAttempt, error) {
+	item, err := txn.Get(loginAttemptKey(key))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var attempt *LoginAttempt
+	err = item.Value(func(v []byte) error {
+		a, err := loginAttemptRecord.Unmarshal(v)
+		attempt = &a
+		return err
+	})
+	return attempt, err
+}
+
+// GetLoginAttempt returns nil when there are no recent failures for key.
+func (q *Queries) GetLoginAttempt(key string) (*LoginAttempt, error) {
+	var attempt *LoginAttempt
+	err := q.db.View(func(txn *badger.Txn) error {
+		var err error
+		attempt, err = getLoginAttempt(txn, key)
+		return err
+	})
+
+	return attempt, err
+}
+
+// RecordLoginFailure counts a failed login for key and applies the lockout
+// returned for the new failure count. Every failure pushes the key's TTL out
+// by window, so the tally resets itself once failures stop. Concurrent
+// failures conflict on the same key, so the update is retried until it
+// commits; otherwise parallel guesses would go uncounted.
+func (q *Queries) RecordLoginFailure(key string, window time.Duration, lockout func(failures int64) time.Duration) (*LoginAttempt, error) {
+	var attempt *LoginAttempt
+	var err error
+	for i := 0; i < maxLoginFailureRetries; i++ {
+		attempt, err = q.recordLoginFailure(key, window, lockout)
+		if err != badger.ErrConflict {
+			break
+		}
+	}
+
+	return attempt, err
+}
+
+func (q *Queries) recordLoginFailure(key string, window time.Duration, lockout func(failures int64) time.Duration) (*LoginAttempt, error) {
+	var attempt *LoginAttempt
+	err := q.db.Update(func(txn *badger.Txn) error {
+		existing, err := getLoginAttempt(txn, key)
+		if err != nil {
+			return err
+		}
+
+		attempt = &LoginAttempt{}
+		if existing != nil {
+			attempt = existing
+		}
+		attempt.Failures++
+
+		ttl := window
+		if d := lockout(attempt.Failures); d > 0 {
+			attempt.LockedUntil = time.Now().Add(d).Unix()
+			if d > ttl {
+				ttl = d
+			}
+		}
+
+		value, err := loginAttemptRecord.Marshal(attempt)
+		if err != nil {
+			return err
+		}
+
+		return txn.SetEntry(badger.NewEntry(loginAttemptKey(key), value).WithTTL(ttl))
+	})
+
+	return attempt, err
+}
+
+func (q *Queries) ClearLoginAttempts(key string) error {
+	return q.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(loginAttemptKey(key))
+	})
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoginAttemptTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *badger.DB
+}
+
+func (s *LoginAttemptTestSuite) SetupSuite() {
+	conn, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+}
+
+func (s *LoginAttemptTestSuite) TearDownSuite() {
+	s.conn.Close()
+}
+
+func (s *LoginAttemptTestSuite) TestRecordLoginFailure() {
+	lockout := func(failures int64) time.Duration {
+		if failures >= 2 {
+			return time.Minute
+		}
+		return 0
+	}
+
+	attempt, err := s.q.RecordLoginFailure("user:janedoe", time.Hour, lockout)
+	s.NoError(err)
+	s.Equal(int64(1), attempt.Failures)
+	s.Zero(attempt.LockedUntil)
+
+	attempt, err = s.q.RecordLoginFailure("user:janedoe", time.Hour, lockout)
+	s.NoError(err)
+	s.Equal(int64(2), attempt.Failures)
+	s.Greater(attempt.LockedUntil, time.Now().Unix())
+
+	s.NoError(s.q.ClearLoginAttempts("user:janedoe"))
+	attempt, err = s.q.GetLoginAttempt("user:janedoe")
+	s.NoError(err)
+	s.Nil(attempt)
+}
+
+func (s *LoginAttemptTestSuite) TestConcurrentFailuresAreAllCounted() {
+	const workers = 20
+	noLockout := func(int64) time.Duration { return 0 }
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.q.RecordLoginFailure("user:johndoe", time.Hour, noLockout)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		s.NoError(err)
+	}
+
+	attempt, err := s.q.GetLoginAttempt("user:johndoe")
+	s.NoError(err)
+	s.Require().NotNil(attempt)
+	s.Equal(int64(workers), attempt.Failures)
+}
+
+func TestLoginAttempts(t *testing.T) {
+	suite.Run(t, new(LoginAttemptTestSuite))
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/dgraph-io/badger/v3"
+)
+
+const migrationBatchSize = 1000
+
+var schemaVersionKey = []byte("meta/schema_version")
+
+// Migration rewrites every value under Prefix. Migrate returns the new value
+// for a key, or nil to leave it as it is. A migration interrupted by a crash
+// resumes after the last batch it wrote, but that batch may be partly
+// applied, so Migrate must accept values it has already migrated.
+type Migration struct {
+	Description string
+	Prefix      []byte
+	Migrate     func(key []byte, value []byte) ([]byte, error)
+}
+
+// migrations is the registry of data migrations, keyed by the schema version
+// each one brings the store to. Add new migrations with the next version;
+// never change or remove one that has shipped.
+var migrations = map[uint64]Migration{
+	1: {
+		Description: "Write users with the versioned codec",
+		Prefix:      []byte("user/"),
+		Migrate:     recodeRecords(userRecord),
+	},
+	2: {
+		Description: "Write sessions with the versioned codec",
+		Prefix:      []byte("session/"),
+		Migrate:     recodeRecords(sessionRecord),
+	},
+}
+
+func migrationProgressKey(version uint64) []byte {
+	return []byte(fmt.Sprintf("meta/migration/%d", version))
+}
+
+// SchemaVersion returns the version of the last migration applied to the
+// store, 0 for a store that has never been migrated.
+func (q *Queries) SchemaVersion() (uint64, error) {
+	var version uint64
+	err := q.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(schemaVersionKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return item.Value(func(v []byte) error {
+			version, err = strconv.ParseUint(string(v), 10, 64)
+			return err
+		})
+	})
+
+	return version, err
+}
+
+// Migrate applies the registered migrations newer than the store's schema
+// version, in order.
+func (q *Queries) Migrate() error {
+	return q.runMigrations(migrations, migrationBatchSize)
+}
+
+func (q *Queries) runMigrations(registry map[uint64]Migration, batchSize int) error {
+	current, err := q.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	versions := make([]uint64, 0, len(registry))
+	for version := range registry {
+		if version > current {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] < versions[j]
+	})
+
+	for _, version := range versions {
+		if err := q.runMigration(version, registry[version], batchSize); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", version, registry[version].Description, err)
+		}
+	}
+	return nil
+}
+
+// runMigration walks the prefix in batches. Each batch is written with a
+// WriteBatch together with the last key it covered, which is where the
+// migration picks up again after a restart.
+func (q *Queries) runMigration(version uint64, migration Migration, batchSize int) error {
+	progressKey := migrationProgressKey(version)
+
+	var after []byte
+	err := q.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(progressKey)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		after, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		entries, last, err := q.migrateBatch(migration, after, batchSize)
+		if err != nil {
+			return err
+		}
+		if last == nil {
+			break
+		}
+
+		wb := q.db.NewWriteBatch()
+		for _, entry := range entries {
+			if err := wb.SetEntry(entry); err != nil {
+				wb.Cancel()
+				return err
+			}
+		}
+		if err := wb.Set(progressKey, last); err != nil {
+			wb.Cancel()
+			return err
+		}
+		if err := wb.Flush(); err != nil {
+			return err
+		}
+
+		after = last
+	}
+
+	return q.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(schemaVersionKey, []byte(strconv.FormatUint(version, 10))); err != nil {
+			return err
+		}
+		return txn.Delete(progressKey)
+	})
+}
+
+// migrateBatch migrates up to batchSize keys after the given key. It returns
+// the entries to write and the last key it read, which is nil once the
+// prefix is exhausted.
+func (q *Queries) migrateBatch(migration Migration, after []byte, batchSize int) ([]*badger.Entry, []byte, error) {
+	var entries []*badger.Entry
+	var last []byte
+
+	err := q.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{
+			PrefetchValues: true,
+			PrefetchSize:   100,
+			Prefix:         migration.Prefix,
+		})
+		defer it.Close()
+
+		start := migration.Prefix
+		if after != nil {
+			start = after
+		}
+
+		read := 0
+		for it.Seek(start); it.Valid() && read < batchSize; it.Next() {
+			item := it.Item()
+			if after != nil && bytes.Equal(item.Key(), after) {
+				continue
+			}
+
+			key := item.KeyCopy(nil)
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			migrated, err := migration.Migrate(key, value)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", key, err)
+			}
+			if migrated != nil {
+				// keep the TTL of sessions and other expiring keys
+				entry := badger.NewEntry(key, migrated)
+				entry.ExpiresAt = item.ExpiresAt()
+				entries = append(entries, entry)
+			}
+
+			last = key
+			read++
+		}
+
+		return nil
+	})
+
+	return entries, last, err
+}
+
+// recodeRecords rewrites values older than the record's current version,
+// such as the plain gob values stored before records had a header.
+func recodeRecords[T any](record *utils.Record[T]) func(key []byte, value []byte) ([]byte, error) {
+	return func(key []byte, value []byte) ([]byte, error) {
+		version, err := utils.RecordVersion(value)
+		if err != nil {
+			return nil, err
+		}
+		if version >= record.Version() {
+			return nil, nil
+		}
+
+		decoded, err := record.Unmarshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return record.Marshal(&decoded)
+	}
+}
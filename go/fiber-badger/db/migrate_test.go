@@ -0,0 +1,152 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrateTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *badger.DB
+}
+
+func (s *MigrateTestSuite) SetupTest() {
+	conn, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	s.NoError(s.conn.Update(func(txn *badger.Txn) error {
+		for i := 1; i <= 5; i++ {
+			if err := txn.Set([]byte(fmt.Sprintf("item/%d", i)), []byte("v1")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func (s *MigrateTestSuite) TearDownTest() {
+	s.conn.Close()
+}
+
+func (s *MigrateTestSuite) TestRunsPendingMigrationsInOrder() {
+	var ran []string
+	registry := map[uint64]Migration{
+		2: {Prefix: []byte("item/"), Migrate: func(key, value []byte) ([]byte, error) {
+			ran = append(ran, "2 "+string(key))
+			return append(value, "+2"...), nil
+		}},
+		1: {Prefix: []byte("item/"), Migrate: func(key, value []byte) ([]byte, error) {
+			ran = append(ran, "1 "+string(key))
+			if string(key) == "item/3" {
+				return nil, nil
+			}
+			return append(value, "+1"...), nil
+		}},
+	}
+
+	s.NoError(s.q.runMigrations(registry, 2))
+	s.Len(ran, 10)
+	s.Equal("1 item/1", ran[0])
+	s.Equal("2 item/1", ran[5])
+	s.Equal("v1+1+2", s.get("item/1"))
+	s.Equal("v1+2", s.get("item/3"), "Returning nil leaves the value as it is")
+
+	version, err := s.q.SchemaVersion()
+	s.NoError(err)
+	s.Equal(uint64(2), version)
+
+	// applied migrations do not run again
+	ran = nil
+	s.NoError(s.q.runMigrations(registry, 2))
+	s.Empty(ran)
+}
+
+func (s *MigrateTestSuite) TestResumesAfterCrash() {
+	crash := true
+	var ran []string
+	registry := map[uint64]Migration{
+		1: {Prefix: []byte("item/"), Migrate: func(key, value []byte) ([]byte, error) {
+			if crash && string(key) == "item/4" {
+				return nil, errors.New("crash")
+			}
+			ran = append(ran, string(key))
+			return []byte("v2"), nil
+		}},
+	}
+
+	s.ErrorContains(s.q.runMigrations(registry, 2), "crash")
+	s.Equal("v2", s.get("item/2"))
+	s.Equal("v1", s.get("item/3"), "The failed batch is not written")
+
+	version, err := s.q.SchemaVersion()
+	s.NoError(err)
+	s.Equal(uint64(0), version)
+
+	crash = false
+	ran = nil
+	s.NoError(s.q.runMigrations(registry, 2))
+	s.Equal([]string{"item/3", "item/4", "item/5"}, ran)
+	s.Equal("v2", s.get("item/5"))
+
+	version, err = s.q.SchemaVersion()
+	s.NoError(err)
+	s.Equal(uint64(1), version)
+}
+
+func (s *MigrateTestSuite) TestRecodesLegacyRecords() {
+	legacy, err := utils.GobCodec.Marshal(&Session{ID: "legacy", Username: "johndoe"})
+	s.NoError(err)
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	s.NoError(s.conn.Update(func(txn *badger.Txn) error {
+		entry := badger.NewEntry(sessionKey("legacy"), legacy)
+		entry.ExpiresAt = uint64(expiresAt)
+		return txn.SetEntry(entry)
+	}))
+
+	s.NoError(s.q.Migrate())
+
+	s.NoError(s.conn.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(sessionKey("legacy"))
+		s.NoError(err)
+		s.Equal(uint64(expiresAt), item.ExpiresAt(), "The TTL is kept")
+
+		value, err := item.ValueCopy(nil)
+		s.NoError(err)
+		s.Equal(byte(0), value[0], "The value has a header")
+		return nil
+	}))
+
+	session, err := s.q.GetSession("legacy")
+	s.NoError(err)
+	s.Equal("johndoe", session.Username)
+}
+
+func TestMigrate(t *testing.T) {
+	suite.Run(t, new(MigrateTestSuite))
+}
+
+func (s *MigrateTestSuite) get(key string) string {
+	s.T().Helper()
+	var value []byte
+	s.NoError(s.conn.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	}))
+	return string(value)
+}
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/dgraph-io/badger/v3"
+)
+
+type Session struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	UserAgent  string `json:"userAgent"`
+	IP         string `json:"ip"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastSeenAt int64  `json:"lastSeenAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+}
+
+type CreateSessionParams struct {
+	Username  string
+	UserAgent string
+	IP        string
+}
+
+var sessionRecord = utils.NewRecord[Session](1)
+
+func init() {
+	gob.Register(Session{})
+}
+
+func sessionKey(id string) []byte {
+	return []byte(fmt.Sprintf("session/%s", id))
+}
+
+// userSessionPrefix indexes sessions by user so they can be listed and
+// revoked together. Index keys have no value and share the session's TTL.
+func userSessionPrefix(username string) []byte {
+	return []byte(fmt.Sprintf("user_session/%s/", username))
+}
+
+func userSessionKey(username string, id string) []byte {
+	return append(userSessionPrefix(username), id...)
+}
+
+func setSession(txn *badger.Txn, session *Session) error {
+	value, err := sessionRecord.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	entry := badger.NewEntry(sessionKey(session.ID), value)
+	entry.ExpiresAt = uint64(session.ExpiresAt)
+	if err := txn.SetEntry(entry); err != nil {
+		return err
+	}
+
+	index := badger.NewEntry(userSessionKey(session.Username, session.ID), nil)
+	index.ExpiresAt = uint64(session.ExpiresAt)
+	return txn.SetEntry(index)
+}
+
+func getSession(txn *badger.Txn, id string) (*Session, error) {
+	item, err := txn.Get(sessionKey(id))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var session *Session
+	err = item.Value(func(v []byte) error {
+		s, err := sessionRecord.Unmarshal(v)
+		session = &s
+		return err
+	})
+	return session, err
+}
+
+func deleteSession(txn *badger.Txn, session *Session) error {
+	if err := txn.Delete(sessionKey(session.ID)); err != nil {
+		return err
+	}
+	return txn.Delete(userSessionKey(session.Username, session.ID))
+}
+
+// CreateSession stores a new session for the user. The keys carry a Badger
+// TTL, so expired sessions disappear without a sweeper.
+func (q *Queries) CreateSession(data CreateSessionParams, ttl time.Duration) (*Session, error) {
+	now := time.Now()
+	session := &Session{
+		ID:         utils.GenID(),
+		Username:   data.Username,
+		UserAgent:  data.UserAgent,
+		IP:         data.IP,
+		CreatedAt:  now.Unix(),
+		LastSeenAt: now.Unix(),
+		ExpiresAt:  now.Add(ttl).Unix(),
+	}
+
+	err := q.db.Update(func(txn *badger.Txn) error {
+		return setSession(txn, session)
+	})
+
+	return session, err
+}
+
+// GetSession returns nil when the session does not exist or has expired.
+func (q *Queries) GetSession(id string) (*Session, error) {
+	var session *Session
+	err := q.db.View(func(txn *badger.Txn) error {
+		var err error
+		session, err = getSession(txn, id)
+		return err
+	})
+
+	return session, err
+}
+
+// GetUserSessions returns the user's live sessions.
+func (q *Queries) GetUserSessions(username string) ([]*Session, error) {
+	sessions := make([]*Session, 0)
+	prefix := userSessionPrefix(username)
+	err := q.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{
+			Prefix: prefix,
+		})
+		defer it.Close()
+
+		for it.Seek(prefix); it.Valid(); it.Next() {
+			id := string(it.Item().Key()[len(prefix):])
+			session, err := getSession(txn, id)
+			if err != nil {
+				return err
+			}
+			if session != nil {
+				sessions = append(sessions, session)
+			}
+		}
+
+		return nil
+	})
+
+	return sessions, err
+}
+
+// TouchSession records that the session was just used from the given
+// client. The session keeps its original expiry. Losing a write conflict is
+// not an error: the concurrent request that won has just touched the session
+// itself.
+func (q *Queries) TouchSession(id string, userAgent string, ip string) error {
+	err := q.db.Update(func(txn *badger.Txn) error {
+		session, err := getSession(txn, id)
+		if err != nil || session == nil {
+			return err
+		}
+
+		session.UserAgent = userAgent
+		session.IP = ip
+		session.LastSeenAt = time.Now().Unix()
+		return setSession(txn, session)
+	})
+	if err == badger.ErrConflict {
+		return nil
+	}
+	return err
+}
+
+func (q *Queries) DeleteSession(id string) error {
+	return q.db.Update(func(txn *badger.Txn) error {
+		session, err := getSession(txn, id)
+		if err != nil || session == nil {
+			return err
+		}
+
+		return deleteSession(txn, session)
+	})
+}
+
+// RevokeSession deletes one of the user's sessions. It reports false when the
+// user has no live session with the given ID.
+func (q *Queries) RevokeSession(id string, username string) (bool, error) {
+	revoked := false
+	err := q.db.Update(func(txn *badger.Txn) error {
+		session, err := getSession(txn, id)
+		if err != nil || session == nil || session.Username != username {
+			return err
+		}
+
+		revoked = true
+		return deleteSession(txn, session)
+	})
+
+	return revoked, err
+}
+
+// deleteUserSessions deletes every session in the user's index.
+func deleteUserSessions(txn *badger.Txn, username string) error {
+	prefix := userSessionPrefix(username)
+	it := txn.NewIterator(badger.IteratorOptions{
+		Prefix: prefix,
+	})
+
+	var ids []string
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		ids = append(ids, string(it.Item().Key()[len(prefix):]))
+	}
+	it.Close()
+
+	for _, id := range ids {
+		if err := txn.Delete(sessionKey(id)); err != nil {
+			return err
+		}
+		if err := txn.Delete(userSessionKey(username, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteUserSessions logs the user out everywhere.
+func (q *Queries) DeleteUserSessions(username string) error {
+	return q.db.Update(func(txn *badger.Txn) error {
+		return deleteUserSessions(txn, username)
+	})
+}
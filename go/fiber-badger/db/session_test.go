@@ -0,0 +1,62 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/suite"
+)
+
+type SessionTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *badger.DB
+}
+
+func (s *SessionTestSuite) SetupSuite() {
+	conn, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+}
+
+func (s *SessionTestSuite) TearDownSuite() {
+	s.conn.Close()
+}
+
+func (s *SessionTestSuite) TestConcurrentTouchesDoNotFail() {
+	const workers = 20
+	session, err := s.q.CreateSession(CreateSessionParams{Username: "johndoe", UserAgent: "laptop"}, time.Hour)
+	s.Require().NoError(err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.q.TouchSession(session.ID, "phone", "10.0.0.1")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		s.NoError(err)
+	}
+
+	touched, err := s.q.GetSession(session.ID)
+	s.NoError(err)
+	s.Require().NotNil(touched)
+	s.Equal("phone", touched.UserAgent)
+	s.Equal(session.ExpiresAt, touched.ExpiresAt, "Touching keeps the original expiry")
+}
+
+func TestSessions(t *testing.T) {
+	suite.Run(t, new(SessionTestSuite))
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/dgraph-io/badger/v3"
+)
+
+const TokenPurposeMagicLink = "magic_link"
+
+type Token struct {
+	Username  string `json:"username"`
+	Purpose   string `json:"purpose"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+var tokenRecord = utils.NewRecord[Token](1)
+
+func init() {
+	gob.Register(Token{})
+}
+
+func tokenKey(purpose string, tokenHash string) []byte {
+	return []byte(fmt.Sprintf("token/%s/%s", purpose, tokenHash))
+}
+
+// userTokenPrefix indexes tokens by user so they can be deleted with the
+// account. Index keys have no value and share the token's TTL.
+func userTokenPrefix(username string) []byte {
+	return []byte(fmt.Sprintf("user_token/%s/", username))
+}
+
+func userTokenKey(username string, purpose string, tokenHash string) []byte {
+	return append(userTokenPrefix(username), fmt.Sprintf("%s/%s", purpose, tokenHash)...)
+}
+
+// CreateToken stores a single-use token for the user and returns its plain
+// value. Only the hash is kept, under a key whose TTL expires the token.
+func (q *Queries) CreateToken(purpose string, username string, ttl time.Duration) (string, error) {
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = q.db.Update(func(txn *badger.Txn) error {
+		value, err := tokenRecord.Marshal(&Token{
+			Username:  username,
+			Purpose:   purpose,
+			ExpiresAt: time.Now().Add(ttl).Unix(),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := txn.SetEntry(badger.NewEntry(tokenKey(purpose, tokenHash), value).WithTTL(ttl)); err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(userTokenKey(username, purpose, tokenHash), nil).WithTTL(ttl))
+	})
+
+	return token, err
+}
+
+// ConsumeToken deletes the token and returns who it was issued to. It
+// returns nil when the token is unknown, expired or already used.
+func (q *Queries) ConsumeToken(purpose string, token string) (*Token, error) {
+	var result *Token
+	err := q.db.Update(func(txn *badger.Txn) error {
+		tokenHash := utils.HashOpaqueToken(token)
+		key := tokenKey(purpose, tokenHash)
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		err = item.Value(func(v []byte) error {
+			t, err := tokenRecord.Unmarshal(v)
+			result = &t
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+		return txn.Delete(userTokenKey(result.Username, purpose, tokenHash))
+	})
+
+	// A concurrent consumer won the race, so this one must not succeed.
+	if err == badger.ErrConflict {
+		return nil, nil
+	}
+
+	return result, err
+}
+
+// deleteUserTokens deletes every token in the user's index, so that links
+// sent to a deleted account cannot sign in to a new one with the same
+// username.
+func deleteUserTokens(txn *badger.Txn, username string) error {
+	prefix := userTokenPrefix(username)
+	it := txn.NewIterator(badger.IteratorOptions{
+		Prefix: prefix,
+	})
+
+	var keys []string
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Item().Key()[len(prefix):]))
+	}
+	it.Close()
+
+	for _, key := range keys {
+		if err := txn.Delete([]byte("token/" + key)); err != nil {
+			return err
+		}
+		if err := txn.Delete(append(userTokenPrefix(username), key...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,37 +1,109 @@
 package db
 
 import (
+	"bytes"
 	"encoding/gob"
 	"fmt"
+	"strings"
 
 	"github.com/ashwins93/fiber-badger/utils"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
 	Username     string `json:"username"`
 	PasswordHash string `json:"-"`
+	Email        string `json:"email,omitempty"`
 	FirstName    string `json:"firstName"`
 	LastName     string `json:"lastName"`
 }
 
+// CreateUserParams limits usernames to letters and digits because they are
+// embedded in keys such as user_session/<username>/. A "/" would let the
+// prefix for one user match another user's keys.
 type CreateUserParams struct {
-	Username  string `json:"username" validate:"required,min=6,max=25"`
-	Password  string `json:"password" validate:"required,min=8,max=15"`
+	Username  string `json:"username" validate:"required,min=6,max=25,alphanum"`
+	Password  string `json:"password" validate:"omitempty,password=Username Email FirstName LastName"`
+	Email     string `json:"email" validate:"required_without=Password,omitempty,email"`
 	FirstName string `json:"firstName" validate:"min=2,alpha"`
 	LastName  string `json:"lastName" validate:"min=2,alpha"`
 }
 
+// UpdateUserParams holds the fields to change. Nil fields keep their current
+// value, like the coalesce in the SQL templates' updates.
+type UpdateUserParams struct {
+	Email     *string `json:"email" validate:"omitempty,email"`
+	FirstName *string `json:"firstName" validate:"omitempty,min=2,alpha"`
+	LastName  *string `json:"lastName" validate:"omitempty,min=2,alpha"`
+	Password  *string `json:"password"`
+}
+
+var userRecord = utils.NewRecord[User](1)
+
 func init() {
 	gob.Register(User{})
 }
 
-func (q *Queries) CreateNewUser(data *CreateUserParams) (*User, error) {
+func userKey(username string) []byte {
+	return []byte(fmt.Sprintf("user/%s", username))
+}
+
+var userEmailIndex = &uniqueIndex[User]{
+	name:  "user/email",
+	field: "Email",
+	value: func(user *User) string {
+		return user.Email
+	},
+	normalize: strings.ToLower,
+}
+
+var userIndexes = indexSet[User]{userEmailIndex}
+
+// putUser writes the user and moves its index keys away from old, which is
+// nil for a new user.
+func putUser(txn *badger.Txn, old *User, user *User) error {
+	key := userKey(user.Username)
+	if err := userIndexes.update(txn, key, old, user); err != nil {
+		return err
+	}
+
+	value, err := userRecord.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(key, value)
+}
+
+func getUser(txn *badger.Txn, key []byte) (*User, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var user *User
+	err = item.Value(func(v []byte) error {
+		u, err := userRecord.Unmarshal(v)
+		user = &u
+		return err
+	})
+	return user, err
+}
+
+// BuildIndexes indexes users stored before their indexes were added.
+func (q *Queries) BuildIndexes() error {
+	return userIndexes.build(q.db, []byte("user/"), userRecord.Unmarshal, indexBatchSize)
+}
+
+// CreateNewUser stores the user with an already hashed password. An empty
+// hash makes a passwordless account.
+func (q *Queries) CreateNewUser(data *CreateUserParams, passwordHash string) (*User, error) {
 	var user *User
 	err := q.db.Update(func(txn *badger.Txn) error {
-		key := []byte(fmt.Sprintf("user/%s", data.Username))
+		key := userKey(data.Username)
 		_, err := txn.Get(key)
 
 		if err == nil {
@@ -40,34 +112,26 @@ func (q *Queries) CreateNewUser(data *CreateUserParams) (*User, error) {
 			return err
 		}
 
-		passwordHash, err := bcrypt.GenerateFromPassword([]byte(data.Password), 10)
-		if err != nil {
-			return err
-		}
-
 		user = &User{
 			Username:     data.Username,
-			PasswordHash: string(passwordHash),
+			PasswordHash: passwordHash,
+			Email:        data.Email,
 			FirstName:    data.FirstName,
 			LastName:     data.LastName,
 		}
 
-		value, err := utils.MarshalStruct(user)
-		if err != nil {
-			return err
-		}
-
-		err = txn.Set(key, value)
-
-		return err
+		return putUser(txn, nil, user)
 	})
 
 	return user, err
 }
 
-func (q *Queries) GetAllUsers() ([]*User, error) {
+// GetUsers returns up to limit users ordered by username, starting after the
+// given username. Pass an empty username for the first page.
+func (q *Queries) GetUsers(after string, limit int) ([]*User, error) {
 	users := make([]*User, 0)
 	prefix := []byte("user/")
+	start := userKey(after)
 	err := q.db.View(func(txn *badger.Txn) error {
 		it := txn.NewIterator(badger.IteratorOptions{
 			PrefetchSize: 10,
@@ -75,10 +139,13 @@ func (q *Queries) GetAllUsers() ([]*User, error) {
 		})
 		defer it.Close()
 
-		for it.Seek(prefix); it.Valid(); it.Next() {
+		for it.Seek(start); it.Valid() && len(users) < limit; it.Next() {
 			item := it.Item()
+			if after != "" && bytes.Equal(item.Key(), start) {
+				continue
+			}
 			err := item.Value(func(v []byte) error {
-				user, err := utils.UnmarshalStruct[User](v)
+				user, err := userRecord.Unmarshal(v)
 				users = append(users, &user)
 				return err
 			})
@@ -92,3 +159,108 @@ func (q *Queries) GetAllUsers() ([]*User, error) {
 
 	return users, err
 }
+
+// GetUser returns nil when no user with the given username exists.
+func (q *Queries) GetUser(username string) (*User, error) {
+	var user *User
+	err := q.db.View(func(txn *badger.Txn) error {
+		var err error
+		user, err = getUser(txn, userKey(username))
+		return err
+	})
+
+	return user, err
+}
+
+// GetUserBy looks the user up through the index on field, such as "Email",
+// which matches case-insensitively. It returns nil when no user has the
+// value.
+func (q *Queries) GetUserBy(field string, value string) (*User, error) {
+	var user *User
+	err := q.db.View(func(txn *badger.Txn) error {
+		var err error
+		user, err = userIndexes.get(txn, field, value, getUser)
+		return err
+	})
+
+	return user, err
+}
+
+// UpdateUser applies the non-nil fields of data to the user. An empty
+// passwordHash keeps the current password. It returns nil when no user with
+// the given username exists.
+func (q *Queries) UpdateUser(username string, data *UpdateUserParams, passwordHash string) (*User, error) {
+	var user *User
+	err := q.db.Update(func(txn *badger.Txn) error {
+		existing, err := getUser(txn, userKey(username))
+		if err != nil || existing == nil {
+			return err
+		}
+
+		updated := *existing
+		if data.Email != nil {
+			updated.Email = *data.Email
+		}
+		if data.FirstName != nil {
+			updated.FirstName = *data.FirstName
+		}
+		if data.LastName != nil {
+			updated.LastName = *data.LastName
+		}
+		if passwordHash != "" {
+			updated.PasswordHash = passwordHash
+		}
+		// without either the account could never sign in again
+		if updated.Email == "" && updated.PasswordHash == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Email is required for accounts without a password")
+		}
+
+		user = &updated
+		return putUser(txn, existing, user)
+	})
+
+	return user, err
+}
+
+// DeleteUser removes the user along with their index keys, sessions and
+// tokens. It reports false when no user with the given username exists.
+func (q *Queries) DeleteUser(username string) (bool, error) {
+	deleted := false
+	err := q.db.Update(func(txn *badger.Txn) error {
+		key := userKey(username)
+		user, err := getUser(txn, key)
+		if err != nil || user == nil {
+			return err
+		}
+
+		if err := userIndexes.update(txn, key, user, nil); err != nil {
+			return err
+		}
+		if err := deleteUserSessions(txn, username); err != nil {
+			return err
+		}
+		if err := deleteUserTokens(txn, username); err != nil {
+			return err
+		}
+
+		deleted = true
+		return txn.Delete(key)
+	})
+
+	return deleted, err
+}
+
+func (q *Queries) SetPasswordHash(username string, passwordHash string) error {
+	return q.db.Update(func(txn *badger.Txn) error {
+		user, err := getUser(txn, userKey(username))
+		if err != nil {
+			return err
+		} else if user == nil {
+			return badger.ErrKeyNotFound
+		}
+
+		updated := *user
+		updated.PasswordHash = passwordHash
+		return putUser(txn, user, &updated)
+	})
+}
@@ -0,0 +1,338 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/suite"
+)
+
+type UsersTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *badger.DB
+}
+
+func (s *UsersTestSuite) SetupSuite() {
+	conn, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	userList := []CreateUserParams{
+		{Username: "janedoe", Email: "janedoe@example.com", FirstName: "Jane", LastName: "Doe"},
+		{Username: "johnsmith", Email: "johnsmith@example.com", FirstName: "John", LastName: "Smith"},
+		{Username: "janesmith", Email: "janesmith@example.com", FirstName: "Jane", LastName: "Smith"},
+	}
+
+	for _, user := range userList {
+		s.insertUser(user)
+	}
+}
+
+func (s *UsersTestSuite) BeforeTest(suite, testName string) {
+	s.T().Log("BeforeTest: ", testName)
+	switch testName {
+	case "TestDeleteUser":
+		s.insertUser(CreateUserParams{Username: "jsmith", Email: "jsmith@example.com"})
+	}
+}
+
+func (s *UsersTestSuite) TearDownSuite() {
+	s.conn.Close()
+}
+
+func (s *UsersTestSuite) TestCreateUser() {
+	s.insertUser(CreateUserParams{
+		Username:  "johndoe",
+		Email:     "johndoe@example.com",
+		FirstName: "John",
+		LastName:  "Doe",
+	})
+
+	_, err := s.q.CreateNewUser(&CreateUserParams{Username: "johndoe", Email: "other@example.com"}, "hash")
+	s.ErrorContains(err, "Username already taken")
+
+	_, err = s.q.CreateNewUser(&CreateUserParams{Username: "johndoe2", Email: "JohnDoe@example.com"}, "hash")
+	s.ErrorContains(err, "Email already taken")
+}
+
+func (s *UsersTestSuite) TestGetUsers() {
+	users, err := s.q.GetUsers("", 10)
+	s.NoError(err)
+	s.Len(users, 4)
+
+	users, err = s.q.GetUsers("janesmith", 2)
+	s.NoError(err)
+	s.Len(users, 2)
+	s.Equal("johndoe", users[0].Username)
+	s.Equal("johnsmith", users[1].Username)
+}
+
+func (s *UsersTestSuite) TestGetUser() {
+	user, err := s.q.GetUser("janedoe")
+	s.NoError(err)
+	s.Equal("janedoe@example.com", user.Email)
+	s.Equal("hash", user.PasswordHash)
+}
+
+func (s *UsersTestSuite) TestGetUserNotFound() {
+	user, err := s.q.GetUser("nonexistent")
+	s.NoError(err)
+	s.Nil(user)
+}
+
+func (s *UsersTestSuite) TestGetUserBy() {
+	user, err := s.q.GetUserBy("Email", "JaneSmith@Example.com")
+	s.NoError(err)
+	s.Equal("janesmith", user.Username)
+
+	user, err = s.q.GetUserBy("Email", "nonexistent@example.com")
+	s.NoError(err)
+	s.Nil(user)
+
+	_, err = s.q.GetUserBy("FirstName", "Jane")
+	s.ErrorContains(err, "no index on FirstName")
+}
+
+func (s *UsersTestSuite) TestUpdateUser() {
+	email := "jane.smith@example.com"
+	firstName := "Janet"
+
+	user, err := s.q.UpdateUser("janesmith", &UpdateUserParams{Email: &email, FirstName: &firstName}, "")
+	s.NoError(err)
+	s.Equal(email, user.Email)
+	s.Equal("Janet", user.FirstName)
+	s.Equal("Smith", user.LastName, "Fields left out are unchanged")
+	s.Equal("hash", user.PasswordHash, "An empty hash keeps the password")
+
+	// the email index follows the change
+	user, err = s.q.GetUserBy("Email", "janesmith@example.com")
+	s.NoError(err)
+	s.Nil(user)
+	user, err = s.q.GetUserBy("Email", email)
+	s.NoError(err)
+	s.Equal("janesmith", user.Username)
+
+	taken := "janedoe@example.com"
+	_, err = s.q.UpdateUser("janesmith", &UpdateUserParams{Email: &taken}, "")
+	s.ErrorContains(err, "Email already taken")
+
+	user, err = s.q.UpdateUser("nonexistent", &UpdateUserParams{FirstName: &firstName}, "")
+	s.NoError(err)
+	s.Nil(user)
+}
+
+func (s *UsersTestSuite) TestPasswordlessUserKeepsEmail() {
+	_, err := s.q.CreateNewUser(&CreateUserParams{Username: "nopassword", Email: "nopassword@example.com"}, "")
+	s.NoError(err)
+	defer s.q.DeleteUser("nopassword")
+
+	empty := ""
+	_, err = s.q.UpdateUser("nopassword", &UpdateUserParams{Email: &empty}, "")
+	s.ErrorContains(err, "Email is required")
+
+	user, err := s.q.GetUserBy("Email", "nopassword@example.com")
+	s.NoError(err)
+	s.Require().NotNil(user, "The email and its index key are kept")
+	s.Equal("nopassword", user.Username)
+
+	// setting a password at the same time leaves a way to sign in
+	user, err = s.q.UpdateUser("nopassword", &UpdateUserParams{Email: &empty}, "hash")
+	s.NoError(err)
+	s.Empty(user.Email)
+}
+
+func (s *UsersTestSuite) TestPartialUpdates() {
+	lastName := "Doherty"
+
+	user, err := s.q.UpdateUser("johnsmith", &UpdateUserParams{LastName: &lastName}, "newhash")
+	s.NoError(err)
+	s.Equal("John", user.FirstName)
+	s.Equal("Doherty", user.LastName)
+	s.Equal("johnsmith@example.com", user.Email)
+	s.Equal("newhash", user.PasswordHash)
+}
+
+func (s *UsersTestSuite) TestSetPasswordHash() {
+	s.NoError(s.q.SetPasswordHash("janedoe", "rehashed"))
+
+	user, err := s.q.GetUserBy("Email", "janedoe@example.com")
+	s.NoError(err)
+	s.Require().NotNil(user, "The email index still points at the user")
+	s.Equal("rehashed", user.PasswordHash)
+	s.Equal("Jane", user.FirstName)
+
+	err = s.q.SetPasswordHash("nonexistent", "rehashed")
+	s.ErrorIs(err, badger.ErrKeyNotFound)
+}
+
+func (s *UsersTestSuite) TestDeleteUser() {
+	session, err := s.q.CreateSession(CreateSessionParams{Username: "jsmith"}, time.Hour)
+	s.NoError(err)
+	token, err := s.q.CreateToken(TokenPurposeMagicLink, "jsmith", time.Hour)
+	s.NoError(err)
+
+	deleted, err := s.q.DeleteUser("jsmith")
+	s.NoError(err)
+	s.True(deleted)
+
+	user, err := s.q.GetUser("jsmith")
+	s.NoError(err)
+	s.Nil(user)
+
+	user, err = s.q.GetUserBy("Email", "jsmith@example.com")
+	s.NoError(err)
+	s.Nil(user)
+
+	deletedSession, err := s.q.GetSession(session.ID)
+	s.NoError(err)
+	s.Nil(deletedSession, "Sessions are deleted with the user")
+
+	deletedToken, err := s.q.ConsumeToken(TokenPurposeMagicLink, token)
+	s.NoError(err)
+	s.Nil(deletedToken, "Tokens are deleted with the user")
+
+	deleted, err = s.q.DeleteUser("jsmith")
+	s.NoError(err)
+	s.False(deleted)
+}
+
+func (s *UsersTestSuite) TestBuildIndexes() {
+	// a user stored as plain gob, before record headers and the email index
+	value, err := utils.GobCodec.Marshal(&User{Username: "legacy", Email: "legacy@example.com"})
+	s.NoError(err)
+	s.NoError(s.conn.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete(userEmailIndex.builtKey()); err != nil {
+			return err
+		}
+		return txn.Set(userKey("legacy"), value)
+	}))
+	defer s.q.DeleteUser("legacy")
+
+	user, err := s.q.GetUserBy("Email", "legacy@example.com")
+	s.NoError(err)
+	s.Nil(user)
+
+	s.NoError(s.q.BuildIndexes())
+
+	user, err = s.q.GetUserBy("Email", "legacy@example.com")
+	s.NoError(err)
+	s.Equal("legacy", user.Username)
+}
+
+func (s *UsersTestSuite) TestBuildIndexesSkipsDuplicates() {
+	s.NoError(s.conn.Update(func(txn *badger.Txn) error {
+		for _, username := range []string{"dupone", "duptwo"} {
+			value, err := userRecord.Marshal(&User{Username: username, Email: "Dup@example.com"})
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(userKey(username), value); err != nil {
+				return err
+			}
+		}
+		return txn.Delete(userEmailIndex.builtKey())
+	}))
+	defer s.q.DeleteUser("dupone")
+	defer s.q.DeleteUser("duptwo")
+
+	s.NoError(s.q.BuildIndexes(), "Duplicates do not stop the build")
+
+	user, err := s.q.GetUserBy("Email", "dup@example.com")
+	s.NoError(err)
+	s.Equal("dupone", user.Username, "The first record keeps the value")
+
+	// resolving the duplicate leaves the other record's index key alone
+	email := "dup2@example.com"
+	_, err = s.q.UpdateUser("duptwo", &UpdateUserParams{Email: &email}, "")
+	s.NoError(err)
+
+	user, err = s.q.GetUserBy("Email", "dup@example.com")
+	s.NoError(err)
+	s.Equal("dupone", user.Username)
+	user, err = s.q.GetUserBy("Email", email)
+	s.NoError(err)
+	s.Equal("duptwo", user.Username)
+}
+
+func (s *UsersTestSuite) TestBuildIndexesInBatches() {
+	s.NoError(s.conn.Update(func(txn *badger.Txn) error {
+		for i := 1; i <= 5; i++ {
+			value, err := userRecord.Marshal(&User{
+				Username: fmt.Sprintf("batch%d", i),
+				Email:    fmt.Sprintf("batch%d@example.com", i),
+			})
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(userKey(fmt.Sprintf("batch%d", i)), value); err != nil {
+				return err
+			}
+		}
+		return txn.Delete(userEmailIndex.builtKey())
+	}))
+	defer func() {
+		for i := 1; i <= 5; i++ {
+			s.q.DeleteUser(fmt.Sprintf("batch%d", i))
+		}
+	}()
+
+	crash := true
+	decode := func(value []byte) (User, error) {
+		user, err := userRecord.Unmarshal(value)
+		if crash && user.Username == "batch4" {
+			return user, errors.New("crash")
+		}
+		return user, err
+	}
+
+	s.ErrorContains(userIndexes.build(s.conn, []byte("user/"), decode, 2), "crash")
+
+	user, err := s.q.GetUserBy("Email", "batch1@example.com")
+	s.NoError(err)
+	s.NotNil(user, "Batches before the crash are kept")
+	s.NoError(s.conn.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(userEmailIndex.builtKey())
+		s.ErrorIs(err, badger.ErrKeyNotFound, "The index is not marked built")
+		return nil
+	}))
+
+	crash = false
+	s.NoError(userIndexes.build(s.conn, []byte("user/"), decode, 2))
+
+	for i := 1; i <= 5; i++ {
+		user, err := s.q.GetUserBy("Email", fmt.Sprintf("batch%d@example.com", i))
+		s.NoError(err)
+		s.Require().NotNil(user)
+		s.Equal(fmt.Sprintf("batch%d", i), user.Username)
+	}
+	s.NoError(s.conn.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(userEmailIndex.builtKey())
+		s.NoError(err)
+		_, err = txn.Get(userEmailIndex.progressKey())
+		s.ErrorIs(err, badger.ErrKeyNotFound)
+		return nil
+	}))
+}
+
+func TestUsers(t *testing.T) {
+	suite.Run(t, new(UsersTestSuite))
+}
+
+func (s *UsersTestSuite) insertUser(userParams CreateUserParams) *User {
+	s.T().Helper()
+	user, err := s.q.CreateNewUser(&userParams, "hash")
+	s.NoError(err)
+
+	s.Equal(userParams.Username, user.Username)
+	s.Equal(userParams.Email, user.Email)
+	return user
+}
@@ -2,16 +2,24 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ashwins93/fiber-badger/db"
+	"github.com/ashwins93/fiber-badger/mail"
 	"github.com/ashwins93/fiber-badger/routes"
+	"github.com/ashwins93/fiber-badger/utils"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
@@ -20,7 +28,20 @@ func main() {
 		log.Fatal(err)
 	}
 	defer badger.Close()
+
+	codec, err := newCodec()
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.SetCodec(codec)
+
 	s := db.NewDb(badger)
+	if err := s.Migrate(); err != nil {
+		log.Fatal(err)
+	}
+	if err := s.BuildIndexes(); err != nil {
+		log.Fatal(err)
+	}
 
 	app := fiber.New(fiber.Config{
 		JSONEncoder:  json.Marshal,
@@ -30,7 +51,26 @@ func main() {
 	app.Use(recover.New())
 	app.Use(logger.New())
 
-	server := routes.NewService(s, app)
+	hasher, err := newPasswordHasher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy, err := newPasswordPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.SetPasswordPolicy(policy)
+	mailer, err := newMailer()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	publicURL, err := newPublicURL()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := routes.NewService(s, app, hasher, mailer, routes.Config{PublicURL: publicURL})
 
 	server.SetupV1Routes()
 
@@ -57,15 +97,161 @@ func gcRunner(db *badger.DB) {
 	}
 }
 
+// newCodec picks the format new records are written in from GO_BADGER_CODEC:
+// "msgpack" (the default), "json" or "gob". Existing records stay readable
+// after a switch.
+func newCodec() (utils.Codec, error) {
+	name := os.Getenv("GO_BADGER_CODEC")
+	if name == "" {
+		return utils.MsgpackCodec, nil
+	}
+	return utils.CodecByName(name)
+}
+
+// newPasswordHasher picks the algorithm from GO_PASSWORD_HASHER. Hashes made
+// by the other algorithm still verify and are upgraded on the next login.
+func newPasswordHasher() (utils.PasswordHasher, error) {
+	switch algorithm := os.Getenv("GO_PASSWORD_HASHER"); algorithm {
+	case "bcrypt":
+		cost, err := envInt("GO_BCRYPT_COST", bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		return utils.NewBcryptHasher(cost), nil
+	case "", "argon2id":
+		params := utils.DefaultArgon2idParams
+		memory, err := envInt("GO_ARGON2_MEMORY", int(params.Memory))
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := envInt("GO_ARGON2_ITERATIONS", int(params.Iterations))
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := envInt("GO_ARGON2_PARALLELISM", int(params.Parallelism))
+		if err != nil {
+			return nil, err
+		}
+		params.Memory = uint32(memory)
+		params.Iterations = uint32(iterations)
+		params.Parallelism = uint8(parallelism)
+		return utils.NewArgon2idHasher(params), nil
+	default:
+		return nil, fmt.Errorf("unknown GO_PASSWORD_HASHER %q", algorithm)
+	}
+}
+
+// newPasswordPolicy adjusts the default policy from the environment.
+// GO_PASSWORD_BREACHED_DIR points at a local k-anonymity range dataset.
+func newPasswordPolicy() (utils.PasswordPolicy, error) {
+	policy := utils.DefaultPasswordPolicy
+
+	minLength, err := envInt("GO_PASSWORD_MIN_LENGTH", policy.MinLength)
+	if err != nil {
+		return policy, err
+	}
+	maxLength, err := envInt("GO_PASSWORD_MAX_LENGTH", policy.MaxLength)
+	if err != nil {
+		return policy, err
+	}
+	minCharClasses, err := envInt("GO_PASSWORD_MIN_CHAR_CLASSES", policy.MinCharClasses)
+	if err != nil {
+		return policy, err
+	}
+	minEntropy, err := envInt("GO_PASSWORD_MIN_ENTROPY", int(policy.MinEntropy))
+	if err != nil {
+		return policy, err
+	}
+
+	policy.MinLength = minLength
+	policy.MaxLength = maxLength
+	policy.MinCharClasses = minCharClasses
+	policy.MinEntropy = float64(minEntropy)
+	policy.DisallowUserInfo = os.Getenv("GO_PASSWORD_ALLOW_USER_INFO") != "true"
+
+	// bcrypt ignores everything past 72 bytes, which a password of 64
+	// characters outside ASCII can pass.
+	if os.Getenv("GO_PASSWORD_HASHER") == "bcrypt" {
+		policy.MaxBytes = utils.BcryptMaxPasswordBytes
+	}
+
+	if dir := os.Getenv("GO_PASSWORD_BREACHED_DIR"); dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return policy, err
+		}
+		policy.Breached = utils.NewBreachedPasswordDir(dir)
+	}
+
+	return policy, nil
+}
+
+func envInt(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s is invalid: %q", name, value)
+	}
+	return n, nil
+}
+
+// newPublicURL reads GO_PUBLIC_URL, the address users reach the API at, which
+// links in emails are built from.
+func newPublicURL() (string, error) {
+	value := os.Getenv("GO_PUBLIC_URL")
+	if value == "" {
+		return "http://localhost:3000", nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("GO_PUBLIC_URL is invalid: %q", value)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+func newMailer() (mail.Mailer, error) {
+	from := os.Getenv("GO_MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := os.Getenv("GO_MAIL_DRIVER"); driver {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("GO_SMTP_PORT"))
+		if err != nil {
+			return nil, fmt.Errorf("GO_SMTP_PORT is invalid: %w", err)
+		}
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     os.Getenv("GO_SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("GO_SMTP_USERNAME"),
+			Password: os.Getenv("GO_SMTP_PASSWORD"),
+			From:     from,
+		}), nil
+	case "file":
+		return mail.NewFileMailer(os.Getenv("GO_MAIL_DIR"), from), nil
+	case "", "log":
+		return mail.NewLogMailer(os.Stdout, from), nil
+	default:
+		return nil, fmt.Errorf("unknown GO_MAIL_DRIVER %q", driver)
+	}
+}
+
 func errorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
+	message := "Something went wrong"
 
 	var e *fiber.Error
 	if errors.As(err, &e) {
 		code = e.Code
+		message = e.Message
 	}
 
 	return c.Status(code).JSON(&fiber.Map{
-		"message": e.Message,
+		"message": message,
 	})
 }
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/ashwins93/fiber-badger/db"
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const sessionDuration = 24 * time.Hour
+
+type LoginParams struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+func (s *Service) setupAuthRoutes(router fiber.Router) {
+	router.Post("/login", s.loginHandler)
+	router.Post("/logout", s.logoutHandler)
+	router.Get("/me", s.requireSession, s.meHandler)
+}
+
+func (s *Service) loginHandler(c *fiber.Ctx) error {
+	loginParams := LoginParams{}
+
+	if err := c.BodyParser(&loginParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(loginParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	if err := s.checkLoginThrottle(c, loginParams.Username); err != nil {
+		return err
+	}
+
+	user, err := s.queries.GetUser(loginParams.Username)
+	if err != nil {
+		return err
+	}
+
+	valid, needsRehash := false, false
+	if user != nil {
+		valid, needsRehash, err = s.hasher.Verify(loginParams.Password, user.PasswordHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !valid {
+		if err := s.recordLoginFailure(c, loginParams.Username); err != nil {
+			return err
+		}
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid username or password")
+	}
+
+	// Upgrade hashes made with an older algorithm or weaker parameters
+	// while the plain password is at hand.
+	if needsRehash {
+		hash, err := s.hasher.Hash(loginParams.Password)
+		if err != nil {
+			return err
+		}
+		if err := s.queries.SetPasswordHash(user.Username, hash); err != nil {
+			return err
+		}
+	}
+
+	if err := s.clearLoginFailures(c, user.Username); err != nil {
+		return err
+	}
+
+	if err := s.startSession(c, user.Username); err != nil {
+		return err
+	}
+
+	return c.JSON(user)
+}
+
+// startSession creates a session for the user and hands it to the browser
+// as an HTTP-only cookie.
+func (s *Service) startSession(c *fiber.Ctx, username string) error {
+	session, err := s.queries.CreateSession(db.CreateSessionParams{
+		Username:  username,
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+	}, sessionDuration)
+	if err != nil {
+		return err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionCookie,
+		Value:    session.ID,
+		Path:     "/",
+		Expires:  time.Unix(session.ExpiresAt, 0),
+		Secure:   c.Secure(),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func (s *Service) logoutHandler(c *fiber.Ctx) error {
+	if sessionID := c.Cookies(sessionCookie); sessionID != "" {
+		if err := s.queries.DeleteSession(sessionID); err != nil {
+			return err
+		}
+	}
+
+	c.ClearCookie(sessionCookie)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (s *Service) meHandler(c *fiber.Ctx) error {
+	user, err := s.queries.GetUser(currentUsername(c))
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	return c.JSON(user)
+}
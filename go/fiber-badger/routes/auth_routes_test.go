@@ -0,0 +1,251 @@
+package routes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ashwins93/fiber-badger/db"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AuthRoutesTestSuite struct {
+	suite.Suite
+	q    *db.Queries
+	conn *badger.DB
+	app  *fiber.App
+}
+
+func (s *AuthRoutesTestSuite) SetupSuite() {
+	conn, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+
+	service := NewService(s.q, s.app, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	if err := seedDataIntoDb(s.q); err != nil {
+		panic(err)
+	}
+}
+
+func (s *AuthRoutesTestSuite) TearDownSuite() {
+	s.conn.Close()
+}
+
+func (s *AuthRoutesTestSuite) TestLogin() {
+	resp, err := s.app.Test(s.request("POST", "/api/v1/auth/login", LoginParams{Username: "johndoe", Password: "password"}, ""), -1)
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	var user db.User
+	body, err := io.ReadAll(resp.Body)
+	s.NoError(err)
+	s.NoError(json.Unmarshal(body, &user))
+	s.Equal("johndoe", user.Username)
+	s.Empty(user.PasswordHash)
+
+	cookie := sessionCookieOf(resp)
+	s.Require().NotNil(cookie)
+	s.True(cookie.HttpOnly)
+	s.Equal(http.SameSiteLaxMode, cookie.SameSite)
+
+	session, err := s.q.GetSession(cookie.Value)
+	s.NoError(err)
+	s.Require().NotNil(session)
+	s.Equal("johndoe", session.Username)
+	s.WithinDuration(time.Now().Add(sessionDuration), time.Unix(session.ExpiresAt, 0), time.Minute)
+}
+
+func (s *AuthRoutesTestSuite) TestLoginUpgradesPasswordHash() {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	s.Require().NoError(err)
+	_, err = s.q.CreateNewUser(&db.CreateUserParams{Username: "legacyhash", Email: "legacyhash@example.com"}, string(hash))
+	s.Require().NoError(err)
+
+	s.login("legacyhash")
+
+	user, err := s.q.GetUser("legacyhash")
+	s.NoError(err)
+	s.True(strings.HasPrefix(user.PasswordHash, "$argon2id$"))
+
+	// the new hash still works
+	s.login("legacyhash")
+}
+
+func (s *AuthRoutesTestSuite) TestWrongPasswordKeepsHash() {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	s.Require().NoError(err)
+	_, err = s.q.CreateNewUser(&db.CreateUserParams{Username: "oldhash", Email: "oldhash@example.com"}, string(hash))
+	s.Require().NoError(err)
+
+	s.checkReqStatus(s.request("POST", "/api/v1/auth/login", LoginParams{Username: "oldhash", Password: "wrong"}, ""), fiber.StatusUnauthorized, nil)
+
+	user, err := s.q.GetUser("oldhash")
+	s.NoError(err)
+	s.Equal(string(hash), user.PasswordHash, "Only a verified password is rehashed")
+}
+
+func (s *AuthRoutesTestSuite) TestLoginWithWrongPassword() {
+	s.checkReqStatus(s.request("POST", "/api/v1/auth/login", LoginParams{Username: "janedoe", Password: "wrong"}, ""), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.request("POST", "/api/v1/auth/login", LoginParams{Username: "nobody1", Password: "password"}, ""), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.request("POST", "/api/v1/auth/login", LoginParams{Username: "janedoe"}, ""), fiber.StatusBadRequest, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestLoginThrottle() {
+	for i := 0; i < maxUserLoginFailures; i++ {
+		s.checkReqStatus(s.request("POST", "/api/v1/auth/login", LoginParams{Username: "ashwin", Password: "wrong"}, ""), fiber.StatusUnauthorized, nil)
+	}
+
+	// locked out even with the right password
+	s.checkReqStatus(s.request("POST", "/api/v1/auth/login", LoginParams{Username: "ashwin", Password: "password"}, ""), fiber.StatusTooManyRequests, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestConcurrentFailuresLockTheAccount() {
+	hash, err := testHasher.Hash("password")
+	s.Require().NoError(err)
+	_, err = s.q.CreateNewUser(&db.CreateUserParams{Username: "parallel", Email: "parallel@example.com"}, hash)
+	s.Require().NoError(err)
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, maxUserLoginFailures)
+	for i := 0; i < maxUserLoginFailures; i++ {
+		req := s.request("POST", "/api/v1/auth/login", LoginParams{Username: "parallel", Password: "wrong"}, "")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := s.app.Test(req, -1)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	for status := range statuses {
+		s.Equal(fiber.StatusUnauthorized, status)
+	}
+
+	// every parallel failure was counted
+	s.checkReqStatus(s.request("POST", "/api/v1/auth/login", LoginParams{Username: "parallel", Password: "password"}, ""), fiber.StatusTooManyRequests, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestMe() {
+	session := s.login("johndoe")
+
+	var user db.User
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", nil, session), fiber.StatusOK, &user)
+	s.Equal("johndoe", user.Username)
+	s.Empty(user.PasswordHash)
+
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", nil, ""), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", nil, "unknown"), fiber.StatusUnauthorized, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestLogout() {
+	session := s.login("johndoe")
+
+	resp, err := s.app.Test(s.request("POST", "/api/v1/auth/logout", nil, session), -1)
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusNoContent, resp.StatusCode)
+
+	cookie := sessionCookieOf(resp)
+	s.Require().NotNil(cookie)
+	s.Empty(cookie.Value, "The cookie is cleared")
+
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", nil, session), fiber.StatusUnauthorized, nil)
+
+	// logging out without a session is harmless
+	s.checkReqStatus(s.request("POST", "/api/v1/auth/logout", nil, ""), fiber.StatusNoContent, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestExpiredSession() {
+	expired, err := s.q.CreateSession(db.CreateSessionParams{Username: "johndoe"}, -time.Minute)
+	s.Require().NoError(err)
+
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", nil, expired.ID), fiber.StatusUnauthorized, nil)
+
+	sessions, err := s.q.GetUserSessions("johndoe")
+	s.NoError(err)
+	for _, session := range sessions {
+		s.NotEqual(expired.ID, session.ID)
+	}
+}
+
+func TestAuthRoutes(t *testing.T) {
+	suite.Run(t, new(AuthRoutesTestSuite))
+}
+
+func sessionCookieOf(resp *http.Response) *http.Cookie {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// login signs in as a seeded user and returns the session cookie.
+func (s *AuthRoutesTestSuite) login(username string) string {
+	s.T().Helper()
+	resp, err := s.app.Test(s.request("POST", "/api/v1/auth/login", LoginParams{Username: username, Password: "password"}, ""), -1)
+	s.Require().NoError(err)
+	s.Require().Equal(fiber.StatusOK, resp.StatusCode)
+
+	cookie := sessionCookieOf(resp)
+	s.Require().NotNil(cookie)
+	return cookie.Value
+}
+
+func (s *AuthRoutesTestSuite) request(method, path string, params interface{}, session string) *http.Request {
+	var body io.Reader
+	if params != nil {
+		b, _ := json.Marshal(params)
+		body = bytes.NewBuffer(b)
+	}
+
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+	}
+	return req
+}
+
+func (s *AuthRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode, "%s %s", req.Method, req.URL.Path)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, out)
+		s.NoError(err)
+	}
+}
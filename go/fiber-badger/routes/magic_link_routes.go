@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"time"
+
+	"github.com/ashwins93/fiber-badger/db"
+	"github.com/ashwins93/fiber-badger/mail"
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const magicLinkDuration = 15 * time.Minute
+
+type MagicLinkParams struct {
+	Username string `json:"username" validate:"required"`
+}
+
+type MagicLinkCallbackParams struct {
+	Token string `json:"token" form:"token" validate:"required"`
+}
+
+// magicLinkConfirmPage is what the emailed link opens. Mail clients and link
+// scanners fetch links before the user does, so the token is only used up
+// by the POST the page submits.
+var magicLinkConfirmPage = template.Must(template.New("magic_link_confirm").Parse(`<!DOCTYPE html>
+<html>
+  <body>
+    <form method="post" action="/api/v1/auth/magic/callback">
+      <input type="hidden" name="token" value="{{.}}">
+      <button type="submit">Sign in</button>
+    </form>
+  </body>
+</html>
+`))
+
+func (s *Service) setupMagicLinkRoutes(router fiber.Router) {
+	router.Post("", s.magicLinkHandler)
+	router.Get("/callback", s.magicLinkConfirmHandler)
+	router.Post("/callback", s.magicLinkCallbackHandler)
+}
+
+func (s *Service) magicLinkHandler(c *fiber.Ctx) error {
+	magicParams := MagicLinkParams{}
+
+	if err := c.BodyParser(&magicParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(magicParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	user, err := s.queries.GetUser(magicParams.Username)
+	if err != nil {
+		return err
+	}
+
+	// Respond the same way whether or not the user exists so the endpoint
+	// cannot be used to discover accounts.
+	if user == nil || user.Email == "" {
+		return c.Status(fiber.StatusAccepted).Send(nil)
+	}
+
+	token, err := s.queries.CreateToken(db.TokenPurposeMagicLink, user.Username, magicLinkDuration)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/v1/auth/magic/callback?token=%s", s.config.PublicURL, url.QueryEscape(token))
+
+	msg, err := mail.NewMessage(user.Email, "Your sign-in link", "magic_link", fiber.Map{
+		"Link":      link,
+		"ExpiresIn": magicLinkDuration,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(c.Context(), msg); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).Send(nil)
+}
+
+func (s *Service) magicLinkConfirmHandler(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Missing sign-in token")
+	}
+
+	c.Type("html")
+	return magicLinkConfirmPage.Execute(c, token)
+}
+
+func (s *Service) magicLinkCallbackHandler(c *fiber.Ctx) error {
+	callbackParams := MagicLinkCallbackParams{}
+
+	if err := c.BodyParser(&callbackParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(callbackParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	magicToken, err := s.queries.ConsumeToken(db.TokenPurposeMagicLink, callbackParams.Token)
+	if err != nil {
+		return err
+	}
+
+	if magicToken == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired sign-in link")
+	}
+
+	user, err := s.queries.GetUser(magicToken.Username)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired sign-in link")
+	}
+
+	if err := s.startSession(c, user.Username); err != nil {
+		return err
+	}
+
+	// The confirm page's form posts here, so send the browser on with its
+	// new session cookie rather than showing it raw JSON.
+	return c.Redirect("/", fiber.StatusSeeOther)
+}
@@ -0,0 +1,207 @@
+package routes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ashwins93/fiber-badger/db"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type MagicLinkRoutesTestSuite struct {
+	suite.Suite
+	q      *db.Queries
+	conn   *badger.DB
+	app    *fiber.App
+	mailer *recordingMailer
+}
+
+func (s *MagicLinkRoutesTestSuite) SetupSuite() {
+	conn, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	s.mailer = &recordingMailer{}
+
+	service := NewService(s.q, s.app, testHasher, s.mailer, Config{PublicURL: testPublicURL})
+	service.SetupV1Routes()
+
+	if err := seedDataIntoDb(s.q); err != nil {
+		panic(err)
+	}
+}
+
+func (s *MagicLinkRoutesTestSuite) TearDownSuite() {
+	s.conn.Close()
+}
+
+func (s *MagicLinkRoutesTestSuite) TestSignInWithMagicLink() {
+	link := s.requestLink("johndoe", "johndoe@example.com")
+
+	// opening the link only asks for confirmation, so scanners that
+	// prefetch it do not use it up
+	for i := 0; i < 2; i++ {
+		resp, err := s.app.Test(httptest.NewRequest("GET", link, nil), -1)
+		s.Require().NoError(err)
+		s.Equal(fiber.StatusOK, resp.StatusCode)
+		s.Empty(resp.Cookies())
+
+		body, err := io.ReadAll(resp.Body)
+		s.NoError(err)
+		s.Contains(string(body), `method="post"`)
+	}
+
+	resp, err := s.app.Test(s.confirmRequest(link), -1)
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusSeeOther, resp.StatusCode)
+	s.Equal("/", resp.Header.Get(fiber.HeaderLocation))
+
+	var session string
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie {
+			session = cookie.Value
+		}
+	}
+	s.Require().NotEmpty(session)
+
+	req := httptest.NewRequest("GET", "/api/v1/auth/me", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+	s.checkReqStatus(req, fiber.StatusOK, nil)
+
+	// links are single use
+	s.checkReqStatus(s.confirmRequest(link), fiber.StatusUnauthorized, nil)
+}
+
+func (s *MagicLinkRoutesTestSuite) TestLinkIgnoresHostHeader() {
+	req := s.jsonRequest("/api/v1/auth/magic", MagicLinkParams{Username: "ashwin"})
+	req.Host = "evil.example"
+	req.Header.Set("X-Forwarded-Host", "evil.example")
+	s.checkReqStatus(req, fiber.StatusAccepted, nil)
+
+	link := s.lastLink()
+	s.Equal(testPublicURL, link.Scheme+"://"+link.Host)
+	s.Equal("/api/v1/auth/magic/callback", link.Path)
+}
+
+func (s *MagicLinkRoutesTestSuite) TestUnknownUser() {
+	sent := s.mailer.count()
+
+	req := s.jsonRequest("/api/v1/auth/magic", MagicLinkParams{Username: "nobody"})
+	s.checkReqStatus(req, fiber.StatusAccepted, nil)
+
+	s.Equal(sent, s.mailer.count())
+}
+
+func (s *MagicLinkRoutesTestSuite) TestCallbackWithInvalidToken() {
+	s.checkReqStatus(s.confirmRequest("/api/v1/auth/magic/callback?token=invalid"), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.confirmRequest("/api/v1/auth/magic/callback"), fiber.StatusBadRequest, nil)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/auth/magic/callback", nil), fiber.StatusBadRequest, nil)
+}
+
+func (s *MagicLinkRoutesTestSuite) TestDeletedUserLinkIsInvalid() {
+	_, err := s.q.CreateNewUser(&db.CreateUserParams{
+		Username: "leaving",
+		Email:    "leaving@example.com",
+	}, "")
+	s.Require().NoError(err)
+
+	link := s.requestLink("leaving", "leaving@example.com")
+
+	deleted, err := s.q.DeleteUser("leaving")
+	s.NoError(err)
+	s.True(deleted)
+
+	_, err = s.q.CreateNewUser(&db.CreateUserParams{
+		Username: "leaving",
+		Email:    "newcomer@example.com",
+	}, "")
+	s.Require().NoError(err)
+
+	s.checkReqStatus(s.confirmRequest(link), fiber.StatusUnauthorized, nil)
+}
+
+func TestMagicLinkRoutes(t *testing.T) {
+	suite.Run(t, new(MagicLinkRoutesTestSuite))
+}
+
+// requestLink asks for a magic link and returns the path and query of the
+// link from the email.
+func (s *MagicLinkRoutesTestSuite) requestLink(username string, email string) string {
+	s.T().Helper()
+	req := s.jsonRequest("/api/v1/auth/magic", MagicLinkParams{Username: username})
+	s.checkReqStatus(req, fiber.StatusAccepted, nil)
+
+	msg, ok := s.mailer.last()
+	s.True(ok)
+	s.Equal(email, msg.To)
+
+	return s.lastLink().RequestURI()
+}
+
+// lastLink returns the link from the last email, which is the last line of
+// the message body.
+func (s *MagicLinkRoutesTestSuite) lastLink() *url.URL {
+	s.T().Helper()
+	msg, ok := s.mailer.last()
+	s.Require().True(ok)
+
+	lines := strings.Split(strings.TrimSpace(msg.Text), "\n")
+	link, err := url.Parse(lines[len(lines)-1])
+	s.NoError(err)
+	return link
+}
+
+// confirmRequest submits the confirmation form for the token in link.
+func (s *MagicLinkRoutesTestSuite) confirmRequest(link string) *http.Request {
+	s.T().Helper()
+	u, err := url.Parse(link)
+	s.Require().NoError(err)
+
+	form := url.Values{}
+	if token := u.Query().Get("token"); token != "" {
+		form.Set("token", token)
+	}
+	req := httptest.NewRequest("POST", u.Path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", fiber.MIMEApplicationForm)
+	return req
+}
+
+func (s *MagicLinkRoutesTestSuite) jsonRequest(path string, params interface{}) *http.Request {
+	body, _ := json.Marshal(params)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *MagicLinkRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode, "%s %s", req.Method, req.URL.Path)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, out)
+		s.NoError(err)
+	}
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	sessionCookie = "session_id"
+	usernameKey   = "username"
+	sessionIDKey  = "sessionID"
+
+	// sessionTouchInterval limits how often a request rewrites its session to
+	// record the last-seen time.
+	sessionTouchInterval = time.Minute
+)
+
+// requireSession rejects requests without a live session cookie and stores
+// the session owner's username on the context.
+func (s *Service) requireSession(c *fiber.Ctx) error {
+	sessionID := c.Cookies(sessionCookie)
+	if sessionID == "" {
+		return fiber.NewError(fiber.StatusUnauthorized, "Not logged in")
+	}
+
+	session, err := s.queries.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	if session == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Session has expired")
+	}
+
+	// The last-seen time is informational, so failing to record it must not
+	// fail the request.
+	if time.Since(time.Unix(session.LastSeenAt, 0)) > sessionTouchInterval {
+		if err := s.queries.TouchSession(session.ID, c.Get(fiber.HeaderUserAgent), c.IP()); err != nil {
+			log.Printf("touch session %s: %v", session.ID, err)
+		}
+	}
+
+	c.Locals(usernameKey, session.Username)
+	c.Locals(sessionIDKey, session.ID)
+	return c.Next()
+}
+
+func currentUsername(c *fiber.Ctx) string {
+	username, _ := c.Locals(usernameKey).(string)
+	return username
+}
+
+func currentSessionID(c *fiber.Ctx) string {
+	id, _ := c.Locals(sessionIDKey).(string)
+	return id
+}
@@ -2,22 +2,40 @@ package routes
 
 import (
 	"github.com/ashwins93/fiber-badger/db"
+	"github.com/ashwins93/fiber-badger/mail"
+	"github.com/ashwins93/fiber-badger/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
+type Config struct {
+	// PublicURL is the scheme and host that links in emails point at. Links are
+	// never built from the request's Host header, which the client controls.
+	PublicURL string
+}
+
 type Service struct {
 	queries *db.Queries
 	app     *fiber.App
+	hasher  utils.PasswordHasher
+	mailer  mail.Mailer
+	config  Config
 }
 
-func NewService(queries *db.Queries, app *fiber.App) *Service {
-	return &Service{queries, app}
+func NewService(queries *db.Queries, app *fiber.App, hasher utils.PasswordHasher, mailer mail.Mailer, config Config) *Service {
+	return &Service{queries, app, hasher, mailer, config}
 }
 
 func (s *Service) SetupV1Routes() {
 	v1Routes := s.app.Group("/api/v1")
 
+	authRouter := v1Routes.Group("/auth")
+	magicLinkRouter := authRouter.Group("/magic")
 	userRouter := v1Routes.Group("/users")
+	meRouter := v1Routes.Group("/me")
+	sessionRouter := meRouter.Group("/sessions")
 
+	s.setupAuthRoutes(authRouter)
+	s.setupMagicLinkRoutes(magicLinkRouter)
 	s.setupUserRoutes(userRouter)
+	s.setupSessionRoutes(sessionRouter)
 }
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"github.com/ashwins93/fiber-badger/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+type sessionResponse struct {
+	*db.Session
+	Current bool `json:"current"`
+}
+
+func (s *Service) setupSessionRoutes(router fiber.Router) {
+	router.Get("", s.requireSession, s.findSessionsHandler)
+	router.Delete("", s.requireSession, s.deleteSessionsHandler)
+	router.Delete("/:id", s.requireSession, s.deleteSessionHandler)
+}
+
+func (s *Service) findSessionsHandler(c *fiber.Ctx) error {
+	sessions, err := s.queries.GetUserSessions(currentUsername(c))
+	if err != nil {
+		return err
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{session, session.ID == currentSessionID(c)})
+	}
+
+	return c.JSON(response)
+}
+
+func (s *Service) deleteSessionHandler(c *fiber.Ctx) error {
+	revoked, err := s.queries.RevokeSession(c.Params("id"), currentUsername(c))
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return fiber.NewError(fiber.StatusNotFound, "Session not found")
+	}
+
+	if c.Params("id") == currentSessionID(c) {
+		c.ClearCookie(sessionCookie)
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// deleteSessionsHandler logs the user out everywhere, including here.
+func (s *Service) deleteSessionsHandler(c *fiber.Ctx) error {
+	if err := s.queries.DeleteUserSessions(currentUsername(c)); err != nil {
+		return err
+	}
+
+	c.ClearCookie(sessionCookie)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
@@ -0,0 +1,165 @@
+package routes
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ashwins93/fiber-badger/db"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type SessionRoutesTestSuite struct {
+	suite.Suite
+	q    *db.Queries
+	conn *badger.DB
+	app  *fiber.App
+}
+
+func (s *SessionRoutesTestSuite) SetupSuite() {
+	conn, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+
+	service := NewService(s.q, s.app, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	if err := seedDataIntoDb(s.q); err != nil {
+		panic(err)
+	}
+}
+
+func (s *SessionRoutesTestSuite) SetupTest() {
+	s.NoError(s.q.DeleteUserSessions("johndoe"))
+	s.NoError(s.q.DeleteUserSessions("janedoe"))
+}
+
+func (s *SessionRoutesTestSuite) TearDownSuite() {
+	s.conn.Close()
+}
+
+func (s *SessionRoutesTestSuite) TestListSessions() {
+	laptop := s.createSession("johndoe", "laptop")
+	phone := s.createSession("johndoe", "phone")
+	s.createSession("janedoe", "laptop")
+
+	var sessions []sessionResponse
+	s.checkReqStatus(s.request("GET", "/api/v1/me/sessions", laptop), fiber.StatusOK, &sessions)
+	s.Require().Len(sessions, 2, "Only the user's own sessions are listed")
+
+	for _, session := range sessions {
+		s.Equal("johndoe", session.Username)
+		switch session.ID {
+		case laptop:
+			s.True(session.Current)
+			s.Equal("laptop", session.UserAgent)
+		case phone:
+			s.False(session.Current)
+			s.Equal("phone", session.UserAgent)
+		default:
+			s.Failf("unexpected session", "%s", session.ID)
+		}
+	}
+
+	s.checkReqStatus(s.request("GET", "/api/v1/me/sessions", ""), fiber.StatusUnauthorized, nil)
+}
+
+func (s *SessionRoutesTestSuite) TestRevokeSession() {
+	laptop := s.createSession("johndoe", "laptop")
+	phone := s.createSession("johndoe", "phone")
+
+	s.checkReqStatus(s.request("DELETE", "/api/v1/me/sessions/"+phone, laptop), fiber.StatusNoContent, nil)
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", phone), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", laptop), fiber.StatusOK, nil)
+
+	s.checkReqStatus(s.request("DELETE", "/api/v1/me/sessions/"+phone, laptop), fiber.StatusNotFound, nil)
+}
+
+func (s *SessionRoutesTestSuite) TestRevokeCurrentSession() {
+	laptop := s.createSession("johndoe", "laptop")
+
+	resp, err := s.app.Test(s.request("DELETE", "/api/v1/me/sessions/"+laptop, laptop), -1)
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusNoContent, resp.StatusCode)
+	s.True(cookieCleared(resp))
+
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", laptop), fiber.StatusUnauthorized, nil)
+}
+
+func (s *SessionRoutesTestSuite) TestCannotRevokeOtherUsersSession() {
+	john := s.createSession("johndoe", "laptop")
+	jane := s.createSession("janedoe", "laptop")
+
+	s.checkReqStatus(s.request("DELETE", "/api/v1/me/sessions/"+jane, john), fiber.StatusNotFound, nil)
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", jane), fiber.StatusOK, nil)
+}
+
+func (s *SessionRoutesTestSuite) TestRevokeAllSessions() {
+	laptop := s.createSession("johndoe", "laptop")
+	phone := s.createSession("johndoe", "phone")
+	jane := s.createSession("janedoe", "laptop")
+
+	resp, err := s.app.Test(s.request("DELETE", "/api/v1/me/sessions", laptop), -1)
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusNoContent, resp.StatusCode)
+	s.True(cookieCleared(resp))
+
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", laptop), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", phone), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.request("GET", "/api/v1/auth/me", jane), fiber.StatusOK, nil)
+}
+
+func TestSessionRoutes(t *testing.T) {
+	suite.Run(t, new(SessionRoutesTestSuite))
+}
+
+func cookieCleared(resp *http.Response) bool {
+	cookie := sessionCookieOf(resp)
+	return cookie != nil && cookie.Value == ""
+}
+
+func (s *SessionRoutesTestSuite) createSession(username, userAgent string) string {
+	s.T().Helper()
+	session, err := s.q.CreateSession(db.CreateSessionParams{Username: username, UserAgent: userAgent}, time.Hour)
+	s.Require().NoError(err)
+	return session.ID
+}
+
+func (s *SessionRoutesTestSuite) request(method, path string, session string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+	}
+	return req
+}
+
+func (s *SessionRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode, "%s %s", req.Method, req.URL.Path)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, out)
+		s.NoError(err)
+	}
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ashwins93/fiber-badger/db"
+	"github.com/ashwins93/fiber-badger/mail"
+	"github.com/ashwins93/fiber-badger/utils"
+)
+
+const testPublicURL = "https://app.example.com"
+
+// cheap parameters keep the tests fast
+var testHasher = utils.NewArgon2idHasher(utils.Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+})
+
+func seedDataIntoDb(q *db.Queries) error {
+	userList := []db.CreateUserParams{
+		{Username: "johndoe", Email: "johndoe@example.com", FirstName: "John", LastName: "Doe"},
+		{Username: "janedoe", Email: "janedoe@example.com", FirstName: "Jane", LastName: "Doe"},
+		{Username: "ashwin", Email: "ashwin@example.com", FirstName: "Ashwin", LastName: "Kumar"},
+	}
+
+	for _, user := range userList {
+		hash, err := testHasher.Hash("password")
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.CreateNewUser(&user, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *recordingMailer) last() (mail.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.messages) == 0 {
+		return mail.Message{}, false
+	}
+	return m.messages[len(m.messages)-1], true
+}
+
+func (m *recordingMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.messages)
+}
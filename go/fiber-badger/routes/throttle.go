@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	maxUserLoginFailures = 5
+	maxIPLoginFailures   = 20
+	loginFailureWindow   = time.Hour
+	minLockoutDuration   = time.Minute
+	maxLockoutDuration   = time.Hour
+)
+
+type throttleKey struct {
+	key   string
+	limit int64
+}
+
+func loginThrottleKeys(c *fiber.Ctx, username string) []throttleKey {
+	return []throttleKey{
+		{"user:" + strings.ToLower(username), maxUserLoginFailures},
+		{"ip:" + c.IP(), maxIPLoginFailures},
+	}
+}
+
+// lockoutDuration doubles the lockout for every failure past the limit.
+func lockoutDuration(failures int64, limit int64) time.Duration {
+	if failures < limit {
+		return 0
+	}
+
+	d := minLockoutDuration
+	for i := limit; i < failures && d < maxLockoutDuration; i++ {
+		d *= 2
+	}
+	if d > maxLockoutDuration {
+		d = maxLockoutDuration
+	}
+	return d
+}
+
+// checkLoginThrottle fails with 429 while the account or the client IP is
+// locked out.
+func (s *Service) checkLoginThrottle(c *fiber.Ctx, username string) error {
+	now := time.Now().Unix()
+
+	for _, k := range loginThrottleKeys(c, username) {
+		attempt, err := s.queries.GetLoginAttempt(k.key)
+		if err != nil {
+			return err
+		}
+
+		if attempt != nil && attempt.LockedUntil > now {
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(attempt.LockedUntil-now, 10))
+			return fiber.NewError(fiber.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) recordLoginFailure(c *fiber.Ctx, username string) error {
+	for _, k := range loginThrottleKeys(c, username) {
+		limit := k.limit
+		_, err := s.queries.RecordLoginFailure(k.key, loginFailureWindow, func(failures int64) time.Duration {
+			return lockoutDuration(failures, limit)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clearLoginFailures resets the account after a successful login. The IP
+// tally is left alone so one valid account cannot unlock a noisy client.
+func (s *Service) clearLoginFailures(c *fiber.Ctx, username string) error {
+	return s.queries.ClearLoginAttempts(loginThrottleKeys(c, username)[0].key)
+}
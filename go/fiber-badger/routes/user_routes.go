@@ -8,7 +8,10 @@ import (
 
 func (s *Service) setupUserRoutes(router fiber.Router) {
 	router.Post("", s.createUserHandler)
-	router.Get("", s.findUsersHandler)
+	router.Get("", s.requireSession, s.findUsersHandler)
+	router.Get("/:username", s.requireSession, s.findUserHandler)
+	router.Patch("/:username", s.requireSession, s.updateUserHandler)
+	router.Delete("/:username", s.requireSession, s.deleteUserHandler)
 }
 
 func (s *Service) createUserHandler(c *fiber.Ctx) error {
@@ -23,7 +26,18 @@ func (s *Service) createUserHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errors)
 	}
 
-	user, err := s.queries.CreateNewUser(&userParams)
+	// Accounts without a password sign in with magic links. The empty hash
+	// never matches, so password login stays closed to them.
+	passwordHash := ""
+	if userParams.Password != "" {
+		hash, err := s.hasher.Hash(userParams.Password)
+		if err != nil {
+			return err
+		}
+		passwordHash = hash
+	}
+
+	user, err := s.queries.CreateNewUser(&userParams, passwordHash)
 	if err != nil {
 		return err
 	}
@@ -32,10 +46,134 @@ func (s *Service) createUserHandler(c *fiber.Ctx) error {
 }
 
 func (s *Service) findUsersHandler(c *fiber.Ctx) error {
-	users, err := s.queries.GetAllUsers()
+	pageParams := utils.PageParams{}
+
+	if err := c.QueryParser(&pageParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(pageParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	after, err := pageParams.After()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Invalid cursor",
+		})
+	}
+
+	limit := pageParams.PageLimit()
+	users, err := s.queries.GetUsers(after, limit+1)
+	if err != nil {
+		return err
+	}
+
+	for i, user := range users {
+		users[i] = visibleUser(c, user)
+	}
+
+	return c.JSON(utils.NewPage(users, limit, func(user *db.User) string {
+		return user.Username
+	}))
+}
+
+func (s *Service) findUserHandler(c *fiber.Ctx) error {
+	user, err := s.queries.GetUser(c.Params("username"))
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(users)
+	if user == nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	return c.JSON(visibleUser(c, user))
+}
+
+// visibleUser hides the email of anyone but the caller, so that a session
+// cannot be used to collect other users' addresses.
+func visibleUser(c *fiber.Ctx, user *db.User) *db.User {
+	if user.Username == currentUsername(c) {
+		return user
+	}
+
+	public := *user
+	public.Email = ""
+	return &public
+}
+
+func (s *Service) updateUserHandler(c *fiber.Ctx) error {
+	username := c.Params("username")
+	if username != currentUsername(c) {
+		return fiber.NewError(fiber.StatusForbidden, "You are not allowed to do this")
+	}
+
+	existingUser, err := s.queries.GetUser(username)
+	if err != nil {
+		return err
+	}
+
+	if existingUser == nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	userParams := db.UpdateUserParams{}
+
+	if err := c.BodyParser(&userParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(userParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	passwordHash := ""
+	if userParams.Password != nil {
+		errors = utils.ValidatePassword("UpdateUserParams.Password", *userParams.Password,
+			existingUser.Username, existingUser.Email, existingUser.FirstName, existingUser.LastName)
+		if errors != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(errors)
+		}
+
+		passwordHash, err = s.hasher.Hash(*userParams.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	user, err := s.queries.UpdateUser(username, &userParams, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	return c.JSON(user)
+}
+
+// deleteUserHandler closes the user's own account, which also ends all of
+// their sessions.
+func (s *Service) deleteUserHandler(c *fiber.Ctx) error {
+	username := c.Params("username")
+	if username != currentUsername(c) {
+		return fiber.NewError(fiber.StatusForbidden, "You are not allowed to do this")
+	}
+
+	deleted, err := s.queries.DeleteUser(username)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	c.ClearCookie(sessionCookie)
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
 }
@@ -0,0 +1,302 @@
+package routes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ashwins93/fiber-badger/db"
+	"github.com/ashwins93/fiber-badger/utils"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+)
+
+type UserRoutesTestSuite struct {
+	suite.Suite
+	q       *db.Queries
+	conn    *badger.DB
+	app     *fiber.App
+	session string
+}
+
+func (s *UserRoutesTestSuite) SetupSuite() {
+	conn, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+
+	service := NewService(s.q, s.app, testHasher, nil, Config{})
+	service.SetupV1Routes()
+
+	if err := seedDataIntoDb(s.q); err != nil {
+		panic(err)
+	}
+
+	s.session = s.login("johndoe", "password")
+}
+
+func (s *UserRoutesTestSuite) BeforeTest(suite, testName string) {
+	s.T().Log("BeforeTest: ", testName)
+
+	switch testName {
+	case "TestDeleteUser":
+		hash, _ := testHasher.Hash("password")
+		s.q.CreateNewUser(&db.CreateUserParams{Username: "jsmith", Email: "jsmith@example.com"}, hash)
+	}
+}
+
+func (s *UserRoutesTestSuite) AfterTest(suite, testName string) {
+	switch testName {
+	case "TestCreateUserWithLongPassphrase":
+		s.q.DeleteUser("passphrase")
+	case "TestCreateUserPasswordOverByteCap", "TestCreateUserWithUnreadableBreachedList":
+		utils.SetPasswordPolicy(utils.DefaultPasswordPolicy)
+	}
+}
+
+func (s *UserRoutesTestSuite) TearDownSuite() {
+	s.conn.Close()
+}
+
+func (s *UserRoutesTestSuite) TestCreateUserWithLongPassphrase() {
+	body := map[string]string{
+		"username":  "passphrase",
+		"password":  "correct horse battery staple",
+		"firstName": "Pass",
+		"lastName":  "Phrase",
+	}
+	s.checkReqStatus(s.request("POST", "/api/v1/users", body, ""), fiber.StatusCreated, nil)
+}
+
+func (s *UserRoutesTestSuite) TestCreateUserPasswordContainingUsername() {
+	body := map[string]string{
+		"username":  "marigold",
+		"password":  "marigold2023",
+		"firstName": "Mari",
+		"lastName":  "Gold",
+	}
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(s.request("POST", "/api/v1/users", body, ""), fiber.StatusBadRequest, &errors)
+
+	s.Require().Len(errors, 1)
+	s.Equal("password", errors[0].Tag)
+	s.Equal(utils.PasswordRuleUserInfo, errors[0].Value)
+}
+
+func (s *UserRoutesTestSuite) TestCreateUserPasswordOverByteCap() {
+	policy := utils.DefaultPasswordPolicy
+	policy.MaxBytes = utils.BcryptMaxPasswordBytes
+	utils.SetPasswordPolicy(policy)
+
+	// 42 characters, but 76 bytes in UTF-8
+	body := map[string]string{
+		"username":  "bytecap",
+		"password":  "ёлка ёжик ёлка ёжик ёлка ёжик ёлка ёжик ёл",
+		"firstName": "Byte",
+		"lastName":  "Cap",
+	}
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(s.request("POST", "/api/v1/users", body, ""), fiber.StatusBadRequest, &errors)
+
+	s.Require().Len(errors, 1)
+	s.Equal(utils.PasswordRuleMaxLength, errors[0].Value)
+}
+
+func (s *UserRoutesTestSuite) TestCreateUserWithUnreadableBreachedList() {
+	// a directory where a range file should be cannot be read
+	dir := s.T().TempDir()
+	s.Require().NoError(os.Mkdir(filepath.Join(dir, "ABF7A"), 0o755))
+
+	policy := utils.DefaultPasswordPolicy
+	policy.Breached = utils.NewBreachedPasswordDir(dir)
+	utils.SetPasswordPolicy(policy)
+
+	body := map[string]string{
+		"username":  "unchecked",
+		"password":  "correct horse battery staple",
+		"firstName": "Un",
+		"lastName":  "Checked",
+	}
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(s.request("POST", "/api/v1/users", body, ""), fiber.StatusBadRequest, &errors)
+
+	s.Require().Len(errors, 1)
+	s.Equal(utils.PasswordRuleUnchecked, errors[0].Value)
+}
+
+func (s *UserRoutesTestSuite) TestUpdateUserPasswordContainingName() {
+	body := map[string]string{"password": "johndoe2023!"}
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/johndoe", body, s.session), fiber.StatusBadRequest, &errors)
+
+	s.Require().Len(errors, 1)
+	s.Equal(utils.PasswordRuleUserInfo, errors[0].Value)
+}
+
+func (s *UserRoutesTestSuite) TestCreateUserWithSlashInUsername() {
+	body := map[string]string{
+		"username":  "johndoe/phone",
+		"password":  "correct horse battery staple",
+		"firstName": "John",
+		"lastName":  "Doe",
+	}
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(s.request("POST", "/api/v1/users", body, ""), fiber.StatusBadRequest, &errors)
+
+	s.Require().Len(errors, 1)
+	s.Equal("alphanum", errors[0].Tag)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsers() {
+	var page utils.Page[db.User]
+	s.checkReqStatus(s.request("GET", "/api/v1/users", nil, s.session), fiber.StatusOK, &page)
+
+	s.Len(page.Data, 3)
+	s.Nil(page.NextCursor)
+
+	for _, user := range page.Data {
+		if user.Username == "johndoe" {
+			s.Equal("johndoe@example.com", user.Email)
+		} else {
+			s.Empty(user.Email, "Other users' emails are hidden")
+		}
+	}
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersWithoutSession() {
+	s.checkReqStatus(s.request("GET", "/api/v1/users", nil, ""), fiber.StatusUnauthorized, nil)
+}
+
+func (s *UserRoutesTestSuite) TestGetUser() {
+	var user db.User
+	s.checkReqStatus(s.request("GET", "/api/v1/users/janedoe", nil, s.session), fiber.StatusOK, &user)
+
+	s.Equal("janedoe", user.Username)
+	s.Empty(user.Email, "Other users' emails are hidden")
+	s.Empty(user.PasswordHash)
+
+	user = db.User{}
+	s.checkReqStatus(s.request("GET", "/api/v1/users/johndoe", nil, s.session), fiber.StatusOK, &user)
+	s.Equal("johndoe@example.com", user.Email, "Users see their own email")
+}
+
+func (s *UserRoutesTestSuite) TestGetUserNotFound() {
+	s.checkReqStatus(s.request("GET", "/api/v1/users/nonexistent", nil, s.session), fiber.StatusNotFound, nil)
+}
+
+func (s *UserRoutesTestSuite) TestUpdateUser() {
+	body := map[string]string{"firstName": "Johnny"}
+
+	var user db.User
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/johndoe", body, s.session), fiber.StatusOK, &user)
+
+	s.Equal("Johnny", user.FirstName)
+	s.Equal("Doe", user.LastName)
+	s.Equal("johndoe@example.com", user.Email)
+}
+
+func (s *UserRoutesTestSuite) TestUpdateUserPassword() {
+	body := map[string]string{"password": "Correct horse battery staple"}
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/johndoe", body, s.session), fiber.StatusOK, nil)
+
+	s.NotEmpty(s.login("johndoe", "Correct horse battery staple"))
+
+	body = map[string]string{"password": "short"}
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/johndoe", body, s.session), fiber.StatusBadRequest, nil)
+}
+
+func (s *UserRoutesTestSuite) TestUpdateUserWithInvalidBody() {
+	var errors []*utils.ErrorResponse
+	body := map[string]string{"email": "not-an-email", "lastName": "D"}
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/johndoe", body, s.session), fiber.StatusBadRequest, &errors)
+	s.Len(errors, 2)
+
+	body = map[string]string{"email": "janedoe@example.com"}
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/johndoe", body, s.session), fiber.StatusBadRequest, nil)
+}
+
+func (s *UserRoutesTestSuite) TestCannotManageOtherUsers() {
+	body := map[string]string{"firstName": "Janet"}
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/janedoe", body, s.session), fiber.StatusForbidden, nil)
+	s.checkReqStatus(s.request("DELETE", "/api/v1/users/janedoe", nil, s.session), fiber.StatusForbidden, nil)
+}
+
+func (s *UserRoutesTestSuite) TestDeleteUser() {
+	session := s.login("jsmith", "password")
+
+	s.checkReqStatus(s.request("DELETE", "/api/v1/users/jsmith", nil, session), fiber.StatusNoContent, nil)
+
+	s.checkReqStatus(s.request("GET", "/api/v1/users/jsmith", nil, s.session), fiber.StatusNotFound, nil)
+	s.checkReqStatus(s.request("GET", "/api/v1/users", nil, session), fiber.StatusUnauthorized, nil)
+}
+
+func TestUserRoutes(t *testing.T) {
+	suite.Run(t, new(UserRoutesTestSuite))
+}
+
+// login signs in through the API and returns the session cookie.
+func (s *UserRoutesTestSuite) login(username string, password string) string {
+	s.T().Helper()
+	body := LoginParams{Username: username, Password: password}
+	resp, err := s.app.Test(s.request("POST", "/api/v1/auth/login", body, ""), -1)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func (s *UserRoutesTestSuite) request(method, path string, params interface{}, session string) *http.Request {
+	var body io.Reader
+	if params != nil {
+		b, _ := json.Marshal(params)
+		body = bytes.NewBuffer(b)
+	}
+
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session})
+	}
+	return req
+}
+
+func (s *UserRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode, "%s %s", req.Method, req.URL.Path)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, out)
+		s.NoError(err)
+	}
+}
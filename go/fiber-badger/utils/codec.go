@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes record values in one wire format. Its ID is written to each
+// value's header, so values stay readable after the store switches codecs.
+//
+// All codecs name fields by their Go field name. The json tags on the models
+// describe the API and are ignored here.
+type Codec interface {
+	ID() byte
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	GobCodec     Codec = gobCodec{}
+	JSONCodec    Codec = jsonCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+var codecs = map[byte]Codec{
+	GobCodec.ID():     GobCodec,
+	JSONCodec.ID():    JSONCodec,
+	MsgpackCodec.ID(): MsgpackCodec,
+}
+
+// CodecByName returns the codec called "gob", "json" or "msgpack".
+func CodecByName(name string) (Codec, error) {
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte     { return 1 }
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(v)
+	return b.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte     { return 2 }
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte     { return 3 }
+func (jsonCodec) Name() string { return "json" }
+
+// Marshal encodes structs through a copy of their type whose json tags are
+// the Go field names, so fields hidden from the API, like password hashes,
+// are still stored.
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if storage, ok := storageType(value.Type()); ok {
+		return json.Marshal(value.Convert(storage).Interface())
+	}
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer {
+		return json.Unmarshal(data, v)
+	}
+
+	storage, ok := storageType(target.Elem().Type())
+	if !ok {
+		return json.Unmarshal(data, v)
+	}
+
+	decoded := reflect.New(storage)
+	if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+		return err
+	}
+	target.Elem().Set(decoded.Elem().Convert(target.Elem().Type()))
+	return nil
+}
+
+var storageTypes sync.Map
+
+// storageType returns t with every json tag replaced by the field's name. It
+// only handles structs of exported, non-embedded fields; other types are
+// encoded as they are.
+func storageType(t reflect.Type) (reflect.Type, bool) {
+	if cached, ok := storageTypes.Load(t); ok {
+		storage, _ := cached.(reflect.Type)
+		return storage, storage != nil
+	}
+
+	var storage reflect.Type
+	if t.Kind() == reflect.Struct {
+		fields := make([]reflect.StructField, 0, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Anonymous {
+				fields = nil
+				break
+			}
+			field.Tag = reflect.StructTag(fmt.Sprintf(`json:"%s"`, field.Name))
+			fields = append(fields, field)
+		}
+		if fields != nil {
+			storage = reflect.StructOf(fields)
+		}
+	}
+
+	storageTypes.Store(t, storage)
+	return storage, storage != nil
+}
@@ -1,21 +1,146 @@
 package utils
 
 import (
-	"bytes"
-	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
 )
 
-func MarshalStruct(s interface{}) ([]byte, error) {
-	var b bytes.Buffer
-	enc := gob.NewEncoder(&b)
-	err := enc.Encode(s)
-	return b.Bytes(), err
+// Values start with a header of a zero byte, the codec ID and the record's
+// schema version. A gob stream never starts with a zero byte, so values
+// written before the header existed are still told apart and read as
+// version 0 gob.
+const (
+	headerMarker = 0
+	headerLength = 3
+)
+
+var ErrNewerRecord = errors.New("record was written by a newer version")
+
+var codec = MsgpackCodec
+
+// SetCodec picks the codec new values are written with. Values already
+// stored keep the codec named in their header.
+func SetCodec(c Codec) {
+	codec = c
 }
 
-func UnmarshalStruct[T interface{}](data []byte) (T, error) {
+// Upgrade converts a record from the layout it had at one schema version to
+// the layout of the next one.
+type Upgrade struct {
+	from    byte
+	decode  func(Codec, []byte) (interface{}, error)
+	convert func(interface{}) (interface{}, error)
+}
+
+// NewUpgrade registers how records at version from, decoded into Old, turn
+// into New, the layout of version from+1. Versions without an upgrade are
+// assumed to have the layout of the next version.
+func NewUpgrade[Old any, New any](from byte, convert func(Old) (New, error)) Upgrade {
+	return Upgrade{
+		from: from,
+		decode: func(c Codec, data []byte) (interface{}, error) {
+			var old Old
+			err := c.Unmarshal(data, &old)
+			return old, err
+		},
+		convert: func(v interface{}) (interface{}, error) {
+			old, ok := v.(Old)
+			if !ok {
+				return nil, fmt.Errorf("upgrade from version %d expects %T, got %T", from, old, v)
+			}
+			return convert(old)
+		},
+	}
+}
+
+// Record reads and writes values of T at the current schema version.
+type Record[T any] struct {
+	version  byte
+	upgrades []Upgrade
+}
+
+// NewRecord describes a record type whose current layout is version. Older
+// values are brought up to date with the upgrades when they are read.
+func NewRecord[T any](version byte, upgrades ...Upgrade) *Record[T] {
+	sorted := append([]Upgrade(nil), upgrades...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].from < sorted[j].from
+	})
+	return &Record[T]{version, sorted}
+}
+
+func (r *Record[T]) Version() byte {
+	return r.version
+}
+
+func (r *Record[T]) Marshal(v *T) ([]byte, error) {
+	payload, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{headerMarker, codec.ID(), r.version}, payload...), nil
+}
+
+func (r *Record[T]) Unmarshal(data []byte) (T, error) {
 	var result T
-	dec := gob.NewDecoder(bytes.NewBuffer(data))
-	err := dec.Decode(&result)
 
-	return result, err
+	c, version, payload, err := readHeader(data)
+	if err != nil {
+		return result, err
+	}
+	if version > r.version {
+		return result, fmt.Errorf("%w: version %d, expected at most %d", ErrNewerRecord, version, r.version)
+	}
+
+	var value interface{}
+	for _, upgrade := range r.upgrades {
+		if upgrade.from < version || upgrade.from >= r.version {
+			continue
+		}
+		if value == nil {
+			if value, err = upgrade.decode(c, payload); err != nil {
+				return result, err
+			}
+		}
+		if value, err = upgrade.convert(value); err != nil {
+			return result, err
+		}
+	}
+
+	if value == nil {
+		err = c.Unmarshal(payload, &result)
+		return result, err
+	}
+
+	result, ok := value.(T)
+	if !ok {
+		return result, fmt.Errorf("upgrades ended with %T, expected %T", value, result)
+	}
+	return result, nil
+}
+
+// RecordVersion returns the schema version a value was written at.
+func RecordVersion(data []byte) (byte, error) {
+	_, version, _, err := readHeader(data)
+	return version, err
+}
+
+func readHeader(data []byte) (Codec, byte, []byte, error) {
+	if len(data) == 0 {
+		return nil, 0, nil, errors.New("empty record")
+	}
+	if data[0] != headerMarker {
+		return GobCodec, 0, data, nil
+	}
+	if len(data) < headerLength {
+		return nil, 0, nil, errors.New("truncated record header")
+	}
+
+	c, ok := codecs[data[1]]
+	if !ok {
+		return nil, 0, nil, fmt.Errorf("unknown codec %d", data[1])
+	}
+	return c, data[2], data[headerLength:], nil
 }
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type testAccount struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"-"`
+	FullName     string `json:"fullName"`
+	Logins       int64  `json:"logins"`
+}
+
+// testAccountV0 is the layout testAccount had before FullName was split out
+// of Name.
+type testAccountV0 struct {
+	Username     string
+	PasswordHash string
+	Name         string
+}
+
+type SerializationTestSuite struct {
+	suite.Suite
+}
+
+func (s *SerializationTestSuite) TearDownTest() {
+	SetCodec(MsgpackCodec)
+}
+
+func (s *SerializationTestSuite) TestRoundTrip() {
+	record := NewRecord[testAccount](1)
+	account := testAccount{Username: "johndoe", PasswordHash: "hash", FullName: "John Doe", Logins: 3}
+
+	for _, codec := range []Codec{GobCodec, JSONCodec, MsgpackCodec} {
+		SetCodec(codec)
+		data, err := record.Marshal(&account)
+		s.NoError(err)
+		s.Equal([]byte{0, codec.ID(), 1}, data[:3], codec.Name())
+
+		decoded, err := record.Unmarshal(data)
+		s.NoError(err)
+		s.Equal(account, decoded, codec.Name())
+	}
+}
+
+func (s *SerializationTestSuite) TestJSONUsesFieldNames() {
+	SetCodec(JSONCodec)
+	data, err := NewRecord[testAccount](1).Marshal(&testAccount{Username: "johndoe", PasswordHash: "hash"})
+	s.NoError(err)
+
+	s.True(strings.HasPrefix(string(data[3:]), `{"Username":"johndoe","PasswordHash":"hash"`), string(data[3:]))
+}
+
+func (s *SerializationTestSuite) TestReadsOtherCodecs() {
+	record := NewRecord[testAccount](1)
+
+	SetCodec(JSONCodec)
+	data, err := record.Marshal(&testAccount{Username: "johndoe"})
+	s.NoError(err)
+
+	SetCodec(GobCodec)
+	account, err := record.Unmarshal(data)
+	s.NoError(err)
+	s.Equal("johndoe", account.Username)
+}
+
+func (s *SerializationTestSuite) TestUpgradesLegacyGob() {
+	record := NewRecord[testAccount](2,
+		NewUpgrade(0, func(old testAccountV0) (testAccount, error) {
+			return testAccount{Username: old.Username, PasswordHash: old.PasswordHash, FullName: old.Name}, nil
+		}),
+	)
+
+	// values written before the header are plain gob
+	data, err := GobCodec.Marshal(&testAccountV0{Username: "johndoe", PasswordHash: "hash", Name: "John Doe"})
+	s.NoError(err)
+
+	version, err := RecordVersion(data)
+	s.NoError(err)
+	s.Equal(byte(0), version)
+
+	account, err := record.Unmarshal(data)
+	s.NoError(err)
+	s.Equal(testAccount{Username: "johndoe", PasswordHash: "hash", FullName: "John Doe"}, account)
+}
+
+func (s *SerializationTestSuite) TestUpgradeChain() {
+	type v1 struct{ Name string }
+	type v2 struct{ Names []string }
+
+	record := NewRecord[testAccount](3,
+		NewUpgrade(2, func(old v2) (testAccount, error) {
+			return testAccount{FullName: strings.Join(old.Names, " ")}, nil
+		}),
+		NewUpgrade(1, func(old v1) (v2, error) {
+			return v2{Names: strings.Fields(old.Name)}, nil
+		}),
+	)
+
+	data, err := NewRecord[v1](1).Marshal(&v1{Name: "John Doe"})
+	s.NoError(err)
+	account, err := record.Unmarshal(data)
+	s.NoError(err)
+	s.Equal("John Doe", account.FullName)
+
+	// a version 2 value only goes through the last upgrade
+	data, err = NewRecord[v2](2).Marshal(&v2{Names: []string{"Jane", "Doe"}})
+	s.NoError(err)
+	account, err = record.Unmarshal(data)
+	s.NoError(err)
+	s.Equal("Jane Doe", account.FullName)
+}
+
+func (s *SerializationTestSuite) TestRejectsNewerAndUnknownRecords() {
+	data, err := NewRecord[testAccount](2).Marshal(&testAccount{})
+	s.NoError(err)
+
+	_, err = NewRecord[testAccount](1).Unmarshal(data)
+	s.ErrorIs(err, ErrNewerRecord)
+
+	_, err = NewRecord[testAccount](1).Unmarshal([]byte{0, 42, 1})
+	s.ErrorContains(err, "unknown codec")
+
+	_, err = NewRecord[testAccount](1).Unmarshal(nil)
+	s.Error(err)
+}
+
+func TestSerialization(t *testing.T) {
+	suite.Run(t, new(SerializationTestSuite))
+}
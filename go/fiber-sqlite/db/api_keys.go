@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type APIKey struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	SecretHash string    `json:"-"`
+	LastUsedAt NullInt64 `json:"last_used_at"`
+	CreatedAt  int64     `json:"created_at"`
+}
+
+type CreateAPIKeyParams struct {
+	ID         string `json:"-"`
+	UserID     string `json:"-"`
+	Name       string `json:"name" validate:"required,min=1,max=64"`
+	Prefix     string `json:"-"`
+	SecretHash string `json:"-"`
+}
+
+const createAPIKey = `
+INSERT INTO api_keys (id, user_id, name, prefix, secret_hash, created_at)
+VALUES ($1, $2, $3, $4, $5, unixepoch())
+RETURNING id, user_id, name, prefix, secret_hash, last_used_at, created_at
+`
+
+func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (APIKey, error) {
+	row := q.db.QueryRowContext(ctx, createAPIKey, arg.ID, arg.UserID, arg.Name, arg.Prefix, arg.SecretHash)
+	var i APIKey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.SecretHash,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAPIKeyByPrefix = `
+SELECT id, user_id, name, prefix, secret_hash, last_used_at, created_at
+FROM api_keys
+WHERE prefix = $1;
+`
+
+func (q *Queries) GetAPIKeyByPrefix(ctx context.Context, prefix string) (APIKey, error) {
+	row := q.db.QueryRowContext(ctx, getAPIKeyByPrefix, prefix)
+	var i APIKey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.SecretHash,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return APIKey{}, nil
+	}
+	return i, err
+}
+
+const getUserAPIKeys = `
+SELECT id, user_id, name, prefix, secret_hash, last_used_at, created_at
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at, id
+`
+
+func (q *Queries) GetUserAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	rows, err := q.db.QueryContext(ctx, getUserAPIKeys, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []APIKey{}
+	for rows.Next() {
+		var i APIKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.Prefix,
+			&i.SecretHash,
+			&i.LastUsedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const touchAPIKey = `
+UPDATE api_keys
+SET last_used_at = unixepoch()
+WHERE id = $1
+`
+
+func (q *Queries) TouchAPIKey(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, touchAPIKey, id)
+	return err
+}
+
+const deleteAPIKey = `
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2
+`
+
+// DeleteAPIKey reports false when the user has no key with the given ID.
+func (q *Queries) DeleteAPIKey(ctx context.Context, id string, userID string) (bool, error) {
+	result, err := q.db.ExecContext(ctx, deleteAPIKey, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type APIKeysTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *APIKeysTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	_, err = s.q.CreateUser(context.Background(), CreateUserParams{
+		ID:       "ak-1",
+		Email:    "keys@example.com",
+		Password: hashPassword("password"),
+	})
+	s.NoError(err)
+}
+
+func (s *APIKeysTestSuite) TearDownSuite() {
+	// cleanup, API keys are removed by the cascade
+	s.q.db.ExecContext(context.Background(), "DELETE FROM users WHERE id = 'ak-1'")
+	s.conn.Close()
+}
+
+func (s *APIKeysTestSuite) TestCreateAPIKey() {
+	s.insertKey("a", "sk_a")
+
+	key, err := s.q.GetAPIKeyByPrefix(context.Background(), "sk_a")
+	s.NoError(err)
+	s.Equal("a", key.ID)
+	s.Equal("hash-sk_a", key.SecretHash)
+}
+
+func (s *APIKeysTestSuite) TestGetAPIKeyByPrefixNotFound() {
+	key, err := s.q.GetAPIKeyByPrefix(context.Background(), "sk_missing")
+	s.NoError(err)
+	s.Equal("", key.ID)
+}
+
+func (s *APIKeysTestSuite) TestTouchAPIKey() {
+	s.insertKey("b", "sk_b")
+
+	s.NoError(s.q.TouchAPIKey(context.Background(), "b"))
+
+	key, err := s.q.GetAPIKeyByPrefix(context.Background(), "sk_b")
+	s.NoError(err)
+	s.True(key.LastUsedAt.Valid)
+}
+
+func (s *APIKeysTestSuite) TestDeleteAPIKey() {
+	s.insertKey("c", "sk_c")
+
+	deleted, err := s.q.DeleteAPIKey(context.Background(), "c", "someone-else")
+	s.NoError(err)
+	s.False(deleted, "Keys can only be deleted by their owner")
+
+	deleted, err = s.q.DeleteAPIKey(context.Background(), "c", "ak-1")
+	s.NoError(err)
+	s.True(deleted)
+
+	keys, err := s.q.GetUserAPIKeys(context.Background(), "ak-1")
+	s.NoError(err)
+	for _, key := range keys {
+		s.NotEqual("c", key.ID)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	suite.Run(t, new(APIKeysTestSuite))
+}
+
+func (s *APIKeysTestSuite) insertKey(id, prefix string) APIKey {
+	s.T().Helper()
+	key, err := s.q.CreateAPIKey(context.Background(), CreateAPIKeyParams{
+		ID:         id,
+		UserID:     "ak-1",
+		Name:       id,
+		Prefix:     prefix,
+		SecretHash: "hash-" + prefix,
+	})
+	s.NoError(err)
+	s.NotEqual(0, key.CreatedAt)
+	return key
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+)
+
+const (
+	AuditImpersonationStart   = "impersonation.start"
+	AuditImpersonationRequest = "impersonation.request"
+	AuditImpersonationStop    = "impersonation.stop"
+)
+
+// AuditEvent records something ActorID did to or as UserID. Events are kept
+// when either user is deleted.
+type AuditEvent struct {
+	ID              string     `json:"id"`
+	ActorID         string     `json:"actor_id"`
+	UserID          string     `json:"user_id"`
+	ImpersonationID NullString `json:"impersonation_id"`
+	Action          string     `json:"action"`
+	Detail          string     `json:"detail"`
+	IP              string     `json:"ip"`
+	CreatedAt       int64      `json:"created_at"`
+}
+
+type CreateAuditEventParams struct {
+	ID              string
+	ActorID         string
+	UserID          string
+	ImpersonationID NullString
+	Action          string
+	Detail          string
+	IP              string
+}
+
+const createAuditEvent = `
+INSERT INTO audit_events (id, actor_id, user_id, impersonation_id, action, detail, ip, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, unixepoch())
+RETURNING id, actor_id, user_id, impersonation_id, action, detail, ip, created_at
+`
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error) {
+	row := q.db.QueryRowContext(ctx, createAuditEvent, arg.ID, arg.ActorID, arg.UserID, arg.ImpersonationID, arg.Action, arg.Detail, arg.IP)
+	var i AuditEvent
+	err := row.Scan(
+		&i.ID,
+		&i.ActorID,
+		&i.UserID,
+		&i.ImpersonationID,
+		&i.Action,
+		&i.Detail,
+		&i.IP,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getImpersonationAuditEvents = `
+SELECT id, actor_id, user_id, impersonation_id, action, detail, ip, created_at
+FROM audit_events
+WHERE impersonation_id = $1
+ORDER BY created_at, rowid
+`
+
+func (q *Queries) GetImpersonationAuditEvents(ctx context.Context, impersonationID string) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, getImpersonationAuditEvents, impersonationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditEvent{}
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorID,
+			&i.UserID,
+			&i.ImpersonationID,
+			&i.Action,
+			&i.Detail,
+			&i.IP,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
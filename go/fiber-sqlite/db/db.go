@@ -21,7 +21,6 @@ type Queries struct {
 	db DBTX
 }
 
-
 func NewDb(db *sql.DB) *Queries {
 	return &Queries{
 		db: db,
@@ -45,5 +44,5 @@ func (s *NullString) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 	s.Valid = true
-  return json.Unmarshal(data, &s.String)
-}
\ No newline at end of file
+	return json.Unmarshal(data, &s.String)
+}
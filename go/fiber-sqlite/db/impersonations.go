@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Impersonation struct {
+	ID        string    `json:"id"`
+	AdminID   string    `json:"admin_id"`
+	UserID    string    `json:"user_id"`
+	Reason    string    `json:"reason"`
+	ExpiresAt int64     `json:"expires_at"`
+	StartedAt int64     `json:"started_at"`
+	EndedAt   NullInt64 `json:"ended_at"`
+}
+
+type CreateImpersonationParams struct {
+	ID        string
+	AdminID   string
+	UserID    string
+	Reason    string
+	ExpiresAt int64
+}
+
+const createImpersonation = `
+INSERT INTO impersonations (id, admin_id, user_id, reason, expires_at, started_at)
+VALUES ($1, $2, $3, $4, $5, unixepoch())
+RETURNING id, admin_id, user_id, reason, expires_at, started_at, ended_at
+`
+
+func (q *Queries) CreateImpersonation(ctx context.Context, arg CreateImpersonationParams) (Impersonation, error) {
+	row := q.db.QueryRowContext(ctx, createImpersonation, arg.ID, arg.AdminID, arg.UserID, arg.Reason, arg.ExpiresAt)
+	var i Impersonation
+	err := row.Scan(
+		&i.ID,
+		&i.AdminID,
+		&i.UserID,
+		&i.Reason,
+		&i.ExpiresAt,
+		&i.StartedAt,
+		&i.EndedAt,
+	)
+	return i, err
+}
+
+const getActiveImpersonation = `
+SELECT id, admin_id, user_id, reason, expires_at, started_at, ended_at
+FROM impersonations
+WHERE id = $1 AND ended_at IS NULL AND expires_at > unixepoch()
+`
+
+// GetActiveImpersonation returns an empty Impersonation when the
+// impersonation does not exist, has been stopped or has expired.
+func (q *Queries) GetActiveImpersonation(ctx context.Context, id string) (Impersonation, error) {
+	row := q.db.QueryRowContext(ctx, getActiveImpersonation, id)
+	var i Impersonation
+	err := row.Scan(
+		&i.ID,
+		&i.AdminID,
+		&i.UserID,
+		&i.Reason,
+		&i.ExpiresAt,
+		&i.StartedAt,
+		&i.EndedAt,
+	)
+	if err == sql.ErrNoRows {
+		return Impersonation{}, nil
+	}
+	return i, err
+}
+
+const getImpersonation = `
+SELECT id, admin_id, user_id, reason, expires_at, started_at, ended_at
+FROM impersonations
+WHERE id = $1
+`
+
+// GetImpersonation returns the impersonation whether or not it is still
+// active, or an empty Impersonation when it does not exist.
+func (q *Queries) GetImpersonation(ctx context.Context, id string) (Impersonation, error) {
+	row := q.db.QueryRowContext(ctx, getImpersonation, id)
+	var i Impersonation
+	err := row.Scan(
+		&i.ID,
+		&i.AdminID,
+		&i.UserID,
+		&i.Reason,
+		&i.ExpiresAt,
+		&i.StartedAt,
+		&i.EndedAt,
+	)
+	if err == sql.ErrNoRows {
+		return Impersonation{}, nil
+	}
+	return i, err
+}
+
+const endImpersonation = `
+UPDATE impersonations
+SET ended_at = unixepoch()
+WHERE id = $1 AND ended_at IS NULL
+`
+
+// EndImpersonation reports false when the impersonation had already ended.
+func (q *Queries) EndImpersonation(ctx context.Context, id string) (bool, error) {
+	result, err := q.db.ExecContext(ctx, endImpersonation, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+const endAdminImpersonations = `
+UPDATE impersonations
+SET ended_at = unixepoch()
+WHERE admin_id = $1 AND ended_at IS NULL AND expires_at > unixepoch()
+RETURNING id, admin_id, user_id, reason, expires_at, started_at, ended_at
+`
+
+// EndAdminImpersonations ends every active impersonation started by the
+// admin and returns them.
+func (q *Queries) EndAdminImpersonations(ctx context.Context, adminID string) ([]Impersonation, error) {
+	rows, err := q.db.QueryContext(ctx, endAdminImpersonations, adminID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Impersonation{}
+	for rows.Next() {
+		var i Impersonation
+		if err := rows.Scan(
+			&i.ID,
+			&i.AdminID,
+			&i.UserID,
+			&i.Reason,
+			&i.ExpiresAt,
+			&i.StartedAt,
+			&i.EndedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type ImpersonationsTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *ImpersonationsTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	for _, id := range []string{"im-1", "im-2"} {
+		_, err = s.q.CreateUser(context.Background(), CreateUserParams{
+			ID:       id,
+			Email:    id + "@example.com",
+			Password: hashPassword("password"),
+		})
+		s.NoError(err)
+	}
+}
+
+func (s *ImpersonationsTestSuite) TearDownSuite() {
+	// cleanup, impersonations are removed by the cascade but audit events are kept
+	s.q.db.ExecContext(context.Background(), "DELETE FROM users WHERE id IN ('im-1', 'im-2')")
+	s.q.db.ExecContext(context.Background(), "DELETE FROM audit_events WHERE actor_id = 'im-1'")
+	s.conn.Close()
+}
+
+func (s *ImpersonationsTestSuite) TestEndImpersonation() {
+	s.insertImpersonation("a", time.Hour)
+
+	impersonation, err := s.q.GetActiveImpersonation(context.Background(), "a")
+	s.NoError(err)
+	s.Equal("im-1", impersonation.AdminID)
+	s.Equal("im-2", impersonation.UserID)
+	s.False(impersonation.EndedAt.Valid)
+
+	ended, err := s.q.EndImpersonation(context.Background(), "a")
+	s.NoError(err)
+	s.True(ended)
+
+	impersonation, err = s.q.GetActiveImpersonation(context.Background(), "a")
+	s.NoError(err)
+	s.Equal("", impersonation.ID)
+
+	ended, err = s.q.EndImpersonation(context.Background(), "a")
+	s.NoError(err)
+	s.False(ended, "An impersonation can only be ended once")
+}
+
+func (s *ImpersonationsTestSuite) TestExpiredImpersonationIsNotActive() {
+	s.insertImpersonation("b", -time.Minute)
+
+	impersonation, err := s.q.GetActiveImpersonation(context.Background(), "b")
+	s.NoError(err)
+	s.Equal("", impersonation.ID)
+}
+
+func (s *ImpersonationsTestSuite) TestGetImpersonation() {
+	s.insertImpersonation("d", -time.Minute)
+
+	impersonation, err := s.q.GetImpersonation(context.Background(), "d")
+	s.NoError(err)
+	s.Equal("d", impersonation.ID, "Expired impersonations are still returned")
+
+	impersonation, err = s.q.GetImpersonation(context.Background(), "missing")
+	s.NoError(err)
+	s.Equal("", impersonation.ID)
+}
+
+func (s *ImpersonationsTestSuite) TestEndAdminImpersonations() {
+	s.insertImpersonation("e", time.Hour)
+	s.insertImpersonation("f", time.Hour)
+	s.insertImpersonation("g", -time.Minute)
+
+	ended, err := s.q.EndAdminImpersonations(context.Background(), "im-1")
+	s.NoError(err)
+
+	var ids []string
+	for _, impersonation := range ended {
+		s.True(impersonation.EndedAt.Valid)
+		ids = append(ids, impersonation.ID)
+	}
+	s.Subset(ids, []string{"e", "f"})
+	s.NotContains(ids, "g", "Expired impersonations are left alone")
+
+	ended, err = s.q.EndAdminImpersonations(context.Background(), "im-1")
+	s.NoError(err)
+	s.Empty(ended)
+}
+
+func (s *ImpersonationsTestSuite) TestAuditEvents() {
+	s.insertImpersonation("c", time.Hour)
+
+	var impersonationID NullString
+	impersonationID.String = "c"
+	impersonationID.Valid = true
+
+	for i, action := range []string{AuditImpersonationStart, AuditImpersonationRequest, AuditImpersonationStop} {
+		_, err := s.q.CreateAuditEvent(context.Background(), CreateAuditEventParams{
+			ID:              "event-" + string(rune('a'+i)),
+			ActorID:         "im-1",
+			UserID:          "im-2",
+			ImpersonationID: impersonationID,
+			Action:          action,
+			IP:              "10.0.0.1",
+		})
+		s.NoError(err)
+	}
+
+	events, err := s.q.GetImpersonationAuditEvents(context.Background(), "c")
+	s.NoError(err)
+	s.Len(events, 3)
+	s.Equal(AuditImpersonationStart, events[0].Action)
+	s.Equal(AuditImpersonationRequest, events[1].Action)
+	s.Equal(AuditImpersonationStop, events[2].Action)
+	s.Equal("10.0.0.1", events[2].IP)
+}
+
+func TestImpersonations(t *testing.T) {
+	suite.Run(t, new(ImpersonationsTestSuite))
+}
+
+func (s *ImpersonationsTestSuite) insertImpersonation(id string, ttl time.Duration) Impersonation {
+	s.T().Helper()
+	impersonation, err := s.q.CreateImpersonation(context.Background(), CreateImpersonationParams{
+		ID:        id,
+		AdminID:   "im-1",
+		UserID:    "im-2",
+		Reason:    "testing",
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	})
+	s.NoError(err)
+	s.NotEqual(0, impersonation.StartedAt)
+	return impersonation
+}
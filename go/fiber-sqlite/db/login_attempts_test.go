@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoginAttemptsTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *LoginAttemptsTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+}
+
+func (s *LoginAttemptsTestSuite) TearDownSuite() {
+	// cleanup
+	s.q.db.ExecContext(context.Background(), "DELETE FROM login_attempts WHERE key LIKE 'test:%'")
+	s.conn.Close()
+}
+
+func (s *LoginAttemptsTestSuite) TestRecordLoginFailure() {
+	ctx := context.Background()
+	windowStart := time.Now().Add(-time.Hour).Unix()
+
+	attempt, err := s.q.RecordLoginFailure(ctx, "test:a", windowStart)
+	s.NoError(err)
+	s.Equal(int64(1), attempt.Failures)
+
+	attempt, err = s.q.RecordLoginFailure(ctx, "test:a", windowStart)
+	s.NoError(err)
+	s.Equal(int64(2), attempt.Failures)
+	s.False(attempt.LockedUntil.Valid)
+
+	s.NoError(s.q.LockLogin(ctx, "test:a", 42))
+
+	attempt, err = s.q.GetLoginAttempt(ctx, "test:a")
+	s.NoError(err)
+	s.Equal(int64(42), attempt.LockedUntil.Int64)
+
+	s.NoError(s.q.ClearLoginAttempts(ctx, "test:a"))
+
+	attempt, err = s.q.GetLoginAttempt(ctx, "test:a")
+	s.NoError(err)
+	s.Equal("", attempt.Key)
+}
+
+func (s *LoginAttemptsTestSuite) TestFailuresOutsideWindowReset() {
+	ctx := context.Background()
+
+	_, err := s.q.RecordLoginFailure(ctx, "test:b", 0)
+	s.NoError(err)
+	_, err = s.q.RecordLoginFailure(ctx, "test:b", 0)
+	s.NoError(err)
+
+	// a window starting in the future makes every earlier failure stale
+	attempt, err := s.q.RecordLoginFailure(ctx, "test:b", time.Now().Add(time.Hour).Unix())
+	s.NoError(err)
+	s.Equal(int64(1), attempt.Failures)
+}
+
+func TestLoginAttempts(t *testing.T) {
+	suite.Run(t, new(LoginAttemptsTestSuite))
+}
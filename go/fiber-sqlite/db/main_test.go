@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testDB is the database the suites share. Builds with search keep their
+// own, since a build without FTS5 cannot write to users once the search
+// index exists.
+var testDB = "test.db"
+
+// TestMain brings testDB up to date before the suites open it.
+func TestMain(m *testing.M) {
+	if SearchEnabled {
+		testDB = "test_search.db"
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1&_txlock=immediate")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := CheckSearch(conn); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := MigrateUp(context.Background(), conn); err != nil {
+		log.Fatal(err)
+	}
+	conn.Close()
+
+	os.Exit(m.Run())
+}
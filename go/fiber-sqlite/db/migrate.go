@@ -0,0 +1,264 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations use dbmate's file format and schema_migrations table, so
+// databases migrated with dbmate keep working.
+const createSchemaMigrations = `
+CREATE TABLE IF NOT EXISTS "schema_migrations" (version varchar(255) primary key)
+`
+
+type Migration struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	Down    string `json:"-"`
+	// UpNoTransaction and DownNoTransaction are set by dbmate's
+	// "transaction:false" option on each section, for statements that
+	// cannot run inside a transaction.
+	UpNoTransaction   bool `json:"-"`
+	DownNoTransaction bool `json:"-"`
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied bool `json:"applied"`
+}
+
+// Migrations returns the embedded migrations, oldest first. The user search
+// migrations are only included when search is built in.
+func Migrations() ([]Migration, error) {
+	migrations, err := readMigrations(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	search, err := readMigrations(searchMigrationFiles, "migrations/search/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations = append(migrations, search...)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func readMigrations(files embed.FS, pattern string) ([]Migration, error) {
+	names, err := fs.Glob(files, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		contents, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, err := parseMigration(path.Base(name), string(contents))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration)
+	}
+	return migrations, nil
+}
+
+func parseMigration(filename string, contents string) (Migration, error) {
+	version, name, ok := strings.Cut(strings.TrimSuffix(filename, ".sql"), "_")
+	if !ok || version == "" {
+		return Migration{}, fmt.Errorf("migration %s: name must look like <version>_<name>.sql", filename)
+	}
+
+	migration := Migration{Version: version, Name: name}
+	var section *string
+	for _, line := range strings.SplitAfter(contents, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "-- migrate:up"):
+			section = &migration.Up
+			migration.UpNoTransaction = noTransaction(trimmed)
+		case strings.HasPrefix(trimmed, "-- migrate:down"):
+			section = &migration.Down
+			migration.DownNoTransaction = noTransaction(trimmed)
+		case section != nil:
+			*section += line
+		}
+	}
+
+	if strings.TrimSpace(migration.Up) == "" {
+		return Migration{}, fmt.Errorf("migration %s: missing -- migrate:up section", filename)
+	}
+	return migration, nil
+}
+
+// noTransaction reads the options after a section marker, such as
+// "-- migrate:down transaction:false".
+func noTransaction(marker string) bool {
+	for _, option := range strings.Fields(marker)[2:] {
+		if option == "transaction:false" {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrationStatuses lists every embedded migration and whether it has been
+// applied.
+func MigrationStatuses(ctx context.Context, conn *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		statuses = append(statuses, MigrationStatus{migration, applied[migration.Version]})
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies every pending migration in order and returns the ones
+// it applied. Each migration runs in its own transaction together with its
+// schema_migrations row.
+func MigrateUp(ctx context.Context, conn *sql.DB) ([]Migration, error) {
+	statuses, err := MigrationStatuses(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+
+		applied, err := runMigration(ctx, conn, status.Migration, true)
+		if err != nil {
+			return ran, err
+		}
+		if applied {
+			ran = append(ran, status.Migration)
+		}
+	}
+	return ran, nil
+}
+
+// MigrateDown rolls back the most recently applied migration. It returns
+// nil when no migration has been applied.
+func MigrateDown(ctx context.Context, conn *sql.DB) (*Migration, error) {
+	statuses, err := MigrationStatuses(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+
+		migration := statuses[i].Migration
+		if _, err := runMigration(ctx, conn, migration, false); err != nil {
+			return nil, err
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.DB) (map[string]bool, error) {
+	if _, err := conn.ExecContext(ctx, createSchemaMigrations); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// runMigration applies a migration, or rolls it back when up is false. The
+// schema_migrations row is checked again inside the transaction, so with
+// _txlock=immediate two processes migrating the same database do not both
+// run a migration; it returns false when the other one got there first.
+func runMigration(ctx context.Context, conn *sql.DB, migration Migration, up bool) (bool, error) {
+	wrap := func(err error) error {
+		return fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	statements, record := migration.Up, "INSERT INTO schema_migrations (version) VALUES ($1)"
+	noTransaction := migration.UpNoTransaction
+	if !up {
+		statements, record = migration.Down, "DELETE FROM schema_migrations WHERE version = $1"
+		noTransaction = migration.DownNoTransaction
+	}
+
+	if noTransaction {
+		if _, err := conn.ExecContext(ctx, statements); err != nil {
+			return false, wrap(err)
+		}
+		if _, err := conn.ExecContext(ctx, record, migration.Version); err != nil {
+			return false, wrap(err)
+		}
+		return true, nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, wrap(err)
+	}
+	defer tx.Rollback()
+
+	var applied bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", migration.Version,
+	).Scan(&applied)
+	if err != nil {
+		return false, wrap(err)
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return false, wrap(err)
+	}
+	if _, err := tx.ExecContext(ctx, record, migration.Version); err != nil {
+		return false, wrap(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, wrap(err)
+	}
+	return true, nil
+}
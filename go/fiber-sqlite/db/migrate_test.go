@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrateTestSuite struct {
+	suite.Suite
+	conn *sql.DB
+}
+
+func (s *MigrateTestSuite) SetupTest() {
+	path := filepath.Join(s.T().TempDir(), "migrate.db")
+	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_fk=1&_txlock=immediate", path))
+	s.Require().NoError(err)
+	s.conn = conn
+}
+
+func (s *MigrateTestSuite) TearDownTest() {
+	s.conn.Close()
+}
+
+func (s *MigrateTestSuite) tableExists(name string) bool {
+	var count int
+	err := s.conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = $1", name).Scan(&count)
+	s.Require().NoError(err)
+	return count > 0
+}
+
+func (s *MigrateTestSuite) TestMigrateUp() {
+	ctx := context.Background()
+	migrations, err := Migrations()
+	s.Require().NoError(err)
+	s.Require().NotEmpty(migrations)
+
+	statuses, err := MigrationStatuses(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Len(statuses, len(migrations))
+	for _, status := range statuses {
+		s.False(status.Applied)
+	}
+
+	ran, err := MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Equal(migrations, ran)
+	s.True(s.tableExists("users"))
+	s.Equal(SearchEnabled, s.tableExists("users_fts"))
+
+	ran, err = MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Empty(ran)
+
+	statuses, err = MigrationStatuses(ctx, s.conn)
+	s.Require().NoError(err)
+	for _, status := range statuses {
+		s.True(status.Applied)
+	}
+}
+
+func (s *MigrateTestSuite) TestMigrateDown() {
+	ctx := context.Background()
+	migration, err := MigrateDown(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Nil(migration)
+
+	_, err = MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+
+	migrations, err := Migrations()
+	s.Require().NoError(err)
+	latest := migrations[len(migrations)-1]
+
+	migration, err = MigrateDown(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Require().NotNil(migration)
+	s.Equal(latest.Version, migration.Version)
+	s.True(s.tableExists("users"))
+
+	statuses, err := MigrationStatuses(ctx, s.conn)
+	s.Require().NoError(err)
+	s.False(statuses[len(statuses)-1].Applied)
+	s.True(statuses[len(statuses)-2].Applied)
+
+	ran, err := MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Require().Len(ran, 1)
+	s.Equal(latest.Version, ran[0].Version)
+}
+
+func (s *MigrateTestSuite) TestMigrateDownEverything() {
+	ctx := context.Background()
+	ran, err := MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+
+	for range ran {
+		migration, err := MigrateDown(ctx, s.conn)
+		s.Require().NoError(err)
+		s.Require().NotNil(migration)
+	}
+	s.False(s.tableExists("users"))
+	s.False(s.tableExists("users_fts"))
+
+	migration, err := MigrateDown(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Nil(migration)
+}
+
+func (s *MigrateTestSuite) TestSectionsWithoutTransaction() {
+	ctx := context.Background()
+	_, err := MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+
+	// VACUUM fails inside a transaction
+	migration := Migration{Version: "29990101000000", Name: "vacuum", Up: "VACUUM;", Down: "VACUUM;", UpNoTransaction: true}
+	applied, err := runMigration(ctx, s.conn, migration, true)
+	s.Require().NoError(err)
+	s.True(applied)
+
+	_, err = runMigration(ctx, s.conn, migration, false)
+	s.ErrorContains(err, "within a transaction")
+
+	migration.DownNoTransaction = true
+	applied, err = runMigration(ctx, s.conn, migration, false)
+	s.NoError(err)
+	s.True(applied)
+}
+
+func (s *MigrateTestSuite) TestParseMigration() {
+	migration, err := parseMigration("20230101000000_create_table_things.sql",
+		"-- migrate:up transaction:false\nCREATE TABLE things (id text);\n\n-- migrate:down\nDROP TABLE things;")
+	s.Require().NoError(err)
+	s.Equal("20230101000000", migration.Version)
+	s.Equal("create_table_things", migration.Name)
+	s.Equal("CREATE TABLE things (id text);\n\n", migration.Up)
+	s.Equal("DROP TABLE things;", migration.Down)
+	s.True(migration.UpNoTransaction)
+	s.False(migration.DownNoTransaction)
+
+	migration, err = parseMigration("20230101000000_drop_index.sql",
+		"-- migrate:up\nCREATE INDEX a ON things (id);\n-- migrate:down transaction:false\nDROP INDEX a;")
+	s.Require().NoError(err)
+	s.False(migration.UpNoTransaction)
+	s.True(migration.DownNoTransaction)
+
+	_, err = parseMigration("things.sql", "-- migrate:up\nSELECT 1;")
+	s.Error(err)
+
+	_, err = parseMigration("20230101000000_empty.sql", "-- migrate:down\nSELECT 1;")
+	s.Error(err)
+}
+
+func TestMigrateTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrateTestSuite))
+}
@@ -0,0 +1,15 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id TEXT NOT NULL PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  family_id TEXT NOT NULL,
+  token_hash TEXT UNIQUE NOT NULL,
+  expires_at INTEGER NOT NULL,
+  revoked_at INTEGER,
+  replaced_by TEXT,
+  created_at INTEGER NOT NULL DEFAULT (UNIXEPOCH())
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+-- migrate:down
+DROP INDEX IF EXISTS refresh_tokens_family_id_idx;
+DROP TABLE IF EXISTS refresh_tokens;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- migrate:up
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member'));
+-- migrate:down
+ALTER TABLE users DROP COLUMN role;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- migrate:up
+ALTER TABLE users ADD COLUMN email_verified_at INTEGER;
+-- migrate:down
+ALTER TABLE users DROP COLUMN email_verified_at;
\ No newline at end of file
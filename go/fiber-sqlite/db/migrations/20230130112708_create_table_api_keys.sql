@@ -0,0 +1,14 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS api_keys (
+  id TEXT NOT NULL PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  prefix TEXT UNIQUE NOT NULL,
+  secret_hash TEXT NOT NULL,
+  last_used_at INTEGER,
+  created_at INTEGER NOT NULL DEFAULT (UNIXEPOCH())
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
+-- migrate:down
+DROP INDEX IF EXISTS api_keys_user_id_idx;
+DROP TABLE IF EXISTS api_keys;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS login_attempts (
+  key TEXT NOT NULL PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_failed_at INTEGER NOT NULL,
+  locked_until INTEGER
+) WITHOUT ROWID;
+-- migrate:down
+DROP TABLE IF EXISTS login_attempts;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS sessions (
+  id TEXT NOT NULL PRIMARY KEY,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  user_agent TEXT NOT NULL DEFAULT '',
+  ip TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL DEFAULT (UNIXEPOCH()),
+  last_seen_at INTEGER NOT NULL DEFAULT (UNIXEPOCH())
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+INSERT OR IGNORE INTO sessions (id, user_id, created_at, last_seen_at)
+SELECT family_id, user_id, MIN(created_at), MAX(created_at)
+FROM refresh_tokens
+GROUP BY family_id;
+-- migrate:down
+DROP INDEX IF EXISTS sessions_user_id_idx;
+DROP TABLE IF EXISTS sessions;
\ No newline at end of file
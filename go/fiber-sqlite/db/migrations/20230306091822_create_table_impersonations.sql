@@ -0,0 +1,25 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS impersonations (
+  id TEXT NOT NULL PRIMARY KEY,
+  admin_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  reason TEXT NOT NULL,
+  expires_at INTEGER NOT NULL,
+  started_at INTEGER NOT NULL DEFAULT (UNIXEPOCH()),
+  ended_at INTEGER
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS audit_events (
+  id TEXT NOT NULL PRIMARY KEY,
+  actor_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  impersonation_id TEXT,
+  action TEXT NOT NULL,
+  detail TEXT NOT NULL DEFAULT '',
+  ip TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL DEFAULT (UNIXEPOCH())
+);
+CREATE INDEX IF NOT EXISTS audit_events_impersonation_id_idx ON audit_events (impersonation_id);
+-- migrate:down
+DROP INDEX IF EXISTS audit_events_impersonation_id_idx;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS impersonations;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- migrate:up
+ALTER TABLE users ADD COLUMN totp_last_counter INTEGER;
+-- migrate:down
+ALTER TABLE users DROP COLUMN totp_last_counter;
\ No newline at end of file
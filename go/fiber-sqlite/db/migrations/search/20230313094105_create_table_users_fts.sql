@@ -0,0 +1,43 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS users_fts_rowids (
+  fts_rowid INTEGER PRIMARY KEY,
+  user_id TEXT UNIQUE NOT NULL
+);
+INSERT INTO users_fts_rowids (user_id) SELECT id FROM users;
+CREATE VIEW IF NOT EXISTS users_fts_content AS
+SELECT r.fts_rowid, u.name, u.email
+FROM users_fts_rowids r
+JOIN users u ON u.id = r.user_id;
+CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts5(
+  name,
+  email,
+  content = 'users_fts_content',
+  content_rowid = 'fts_rowid',
+  tokenize = 'unicode61 remove_diacritics 2',
+  prefix = '2 3'
+);
+INSERT INTO users_fts (users_fts) VALUES ('rebuild');
+CREATE TRIGGER IF NOT EXISTS users_fts_insert AFTER INSERT ON users BEGIN
+  INSERT INTO users_fts_rowids (user_id) VALUES (new.id);
+  INSERT INTO users_fts (rowid, name, email)
+  SELECT fts_rowid, new.name, new.email FROM users_fts_rowids WHERE user_id = new.id;
+END;
+CREATE TRIGGER IF NOT EXISTS users_fts_update AFTER UPDATE OF id, name, email ON users BEGIN
+  INSERT INTO users_fts (users_fts, rowid, name, email)
+  SELECT 'delete', fts_rowid, old.name, old.email FROM users_fts_rowids WHERE user_id = old.id;
+  UPDATE users_fts_rowids SET user_id = new.id WHERE user_id = old.id;
+  INSERT INTO users_fts (rowid, name, email)
+  SELECT fts_rowid, new.name, new.email FROM users_fts_rowids WHERE user_id = new.id;
+END;
+CREATE TRIGGER IF NOT EXISTS users_fts_delete AFTER DELETE ON users BEGIN
+  INSERT INTO users_fts (users_fts, rowid, name, email)
+  SELECT 'delete', fts_rowid, old.name, old.email FROM users_fts_rowids WHERE user_id = old.id;
+  DELETE FROM users_fts_rowids WHERE user_id = old.id;
+END;
+-- migrate:down
+DROP TRIGGER IF EXISTS users_fts_delete;
+DROP TRIGGER IF EXISTS users_fts_update;
+DROP TRIGGER IF EXISTS users_fts_insert;
+DROP TABLE IF EXISTS users_fts;
+DROP VIEW IF EXISTS users_fts_content;
+DROP TABLE IF EXISTS users_fts_rowids;
\ No newline at end of file
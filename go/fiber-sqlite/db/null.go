@@ -0,0 +1,28 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// NullInt64 is the nullable integer counterpart of NullString, encoded as a
+// JSON number or null.
+type NullInt64 struct {
+	sql.NullInt64
+}
+
+func (n NullInt64) MarshalJSON() ([]byte, error) {
+	if n.Valid {
+		return json.Marshal(n.Int64)
+	}
+	return []byte(`null`), nil
+}
+
+func (n *NullInt64) UnmarshalJSON(data []byte) error {
+	if string(data) == `null` {
+		n.Valid = false
+		return nil
+	}
+	n.Valid = true
+	return json.Unmarshal(data, &n.Int64)
+}
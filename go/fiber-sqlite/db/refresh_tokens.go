@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type RefreshToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	FamilyID   string     `json:"family_id"`
+	TokenHash  string     `json:"-"`
+	ExpiresAt  int64      `json:"expires_at"`
+	RevokedAt  NullInt64  `json:"-"`
+	ReplacedBy NullString `json:"-"`
+	CreatedAt  int64      `json:"created_at"`
+}
+
+type CreateRefreshTokenParams struct {
+	ID        string
+	UserID    string
+	FamilyID  string
+	TokenHash string
+	ExpiresAt int64
+}
+
+const createRefreshToken = `
+INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, unixepoch())
+RETURNING id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, created_at
+`
+
+func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.ID, arg.UserID, arg.FamilyID, arg.TokenHash, arg.ExpiresAt)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.FamilyID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ReplacedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRefreshTokenByHash = `
+SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, replaced_by, created_at
+FROM refresh_tokens
+WHERE token_hash = $1;
+`
+
+func (q *Queries) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshTokenByHash, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.FamilyID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ReplacedBy,
+		&i.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return RefreshToken{}, nil
+	}
+	return i, err
+}
+
+const rotateRefreshToken = `
+UPDATE refresh_tokens
+SET revoked_at = unixepoch(), replaced_by = $1
+WHERE id = $2 AND revoked_at IS NULL
+`
+
+// RotateRefreshToken revokes the token and records its successor. It reports
+// false when the token had already been revoked, which means it is being reused.
+func (q *Queries) RotateRefreshToken(ctx context.Context, id string, replacedBy string) (bool, error) {
+	result, err := q.db.ExecContext(ctx, rotateRefreshToken, replacedBy, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+const revokeRefreshTokenFamily = `
+UPDATE refresh_tokens
+SET revoked_at = unixepoch()
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	return err
+}
+
+const revokeUserRefreshTokens = `
+UPDATE refresh_tokens
+SET revoked_at = unixepoch()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
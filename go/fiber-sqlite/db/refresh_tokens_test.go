@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type RefreshTokensTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *RefreshTokensTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	_, err = s.q.CreateUser(context.Background(), CreateUserParams{
+		ID:       "rt-1",
+		Email:    "refresh@example.com",
+		Password: hashPassword("password"),
+	})
+	s.NoError(err)
+}
+
+func (s *RefreshTokensTestSuite) TearDownSuite() {
+	// cleanup, refresh tokens are removed by the cascade
+	s.q.db.ExecContext(context.Background(), "DELETE FROM users WHERE id = 'rt-1'")
+	s.conn.Close()
+}
+
+func (s *RefreshTokensTestSuite) TestCreateRefreshToken() {
+	token := s.insertToken("a", "family-a", "hash-a")
+
+	found, err := s.q.GetRefreshTokenByHash(context.Background(), "hash-a")
+	s.NoError(err)
+	s.Equal(token.ID, found.ID)
+	s.Equal("rt-1", found.UserID)
+	s.False(found.RevokedAt.Valid)
+}
+
+func (s *RefreshTokensTestSuite) TestGetRefreshTokenByHashNotFound() {
+	token, err := s.q.GetRefreshTokenByHash(context.Background(), "missing")
+	s.NoError(err)
+	s.Equal("", token.ID)
+}
+
+func (s *RefreshTokensTestSuite) TestRotateRefreshToken() {
+	s.insertToken("b", "family-b", "hash-b")
+
+	rotated, err := s.q.RotateRefreshToken(context.Background(), "b", "c")
+	s.NoError(err)
+	s.True(rotated)
+
+	token, err := s.q.GetRefreshTokenByHash(context.Background(), "hash-b")
+	s.NoError(err)
+	s.True(token.RevokedAt.Valid)
+	s.Equal("c", token.ReplacedBy.String)
+
+	rotated, err = s.q.RotateRefreshToken(context.Background(), "b", "d")
+	s.NoError(err)
+	s.False(rotated, "A revoked token cannot be rotated again")
+}
+
+func (s *RefreshTokensTestSuite) TestRevokeRefreshTokenFamily() {
+	s.insertToken("e", "family-e", "hash-e")
+	s.insertToken("f", "family-e", "hash-f")
+
+	err := s.q.RevokeRefreshTokenFamily(context.Background(), "family-e")
+	s.NoError(err)
+
+	for _, hash := range []string{"hash-e", "hash-f"} {
+		token, err := s.q.GetRefreshTokenByHash(context.Background(), hash)
+		s.NoError(err)
+		s.True(token.RevokedAt.Valid)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	suite.Run(t, new(RefreshTokensTestSuite))
+}
+
+func (s *RefreshTokensTestSuite) insertToken(id, familyID, hash string) RefreshToken {
+	s.T().Helper()
+	token, err := s.q.CreateRefreshToken(context.Background(), CreateRefreshTokenParams{
+		ID:        id,
+		UserID:    "rt-1",
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	s.NoError(err)
+	s.NotEqual(0, token.CreatedAt)
+	return token
+}
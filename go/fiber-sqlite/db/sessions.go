@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+)
+
+// Session describes one login on one device. Its ID is the family ID shared
+// by every refresh token rotated from that login, so a session stays active
+// for as long as the family has a usable refresh token.
+type Session struct {
+	ID         string `json:"id"`
+	UserID     string `json:"user_id"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  int64  `json:"created_at"`
+	LastSeenAt int64  `json:"last_seen_at"`
+}
+
+type CreateSessionParams struct {
+	ID        string
+	UserID    string
+	UserAgent string
+	IP        string
+}
+
+const createSession = `
+INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at)
+VALUES ($1, $2, $3, $4, unixepoch(), unixepoch())
+RETURNING id, user_id, user_agent, ip, created_at, last_seen_at
+`
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, createSession, arg.ID, arg.UserID, arg.UserAgent, arg.IP)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.UserAgent,
+		&i.IP,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+	)
+	return i, err
+}
+
+const getUserSessions = `
+SELECT id, user_id, user_agent, ip, created_at, last_seen_at
+FROM sessions
+WHERE user_id = $1 AND EXISTS (
+  SELECT 1 FROM refresh_tokens
+  WHERE family_id = sessions.id AND revoked_at IS NULL AND expires_at > unixepoch()
+)
+ORDER BY last_seen_at DESC, id
+`
+
+// GetUserSessions returns the user's active sessions, most recently used
+// first.
+func (q *Queries) GetUserSessions(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Session{}
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.UserAgent,
+			&i.IP,
+			&i.CreatedAt,
+			&i.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isSessionActive = `
+SELECT EXISTS (
+  SELECT 1 FROM refresh_tokens
+  WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > unixepoch()
+)
+`
+
+// IsSessionActive reports whether the user's session still has a usable
+// refresh token.
+func (q *Queries) IsSessionActive(ctx context.Context, id string, userID string) (bool, error) {
+	var active bool
+	err := q.db.QueryRowContext(ctx, isSessionActive, id, userID).Scan(&active)
+	return active, err
+}
+
+const touchSession = `
+UPDATE sessions
+SET user_agent = $1, ip = $2, last_seen_at = unixepoch()
+WHERE id = $3
+`
+
+func (q *Queries) TouchSession(ctx context.Context, id string, userAgent string, ip string) error {
+	_, err := q.db.ExecContext(ctx, touchSession, userAgent, ip, id)
+	return err
+}
+
+const revokeSession = `
+UPDATE refresh_tokens
+SET revoked_at = unixepoch()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > unixepoch()
+`
+
+// RevokeSession revokes the session's refresh tokens. It reports false when
+// the user has no active session with the given ID.
+func (q *Queries) RevokeSession(ctx context.Context, id string, userID string) (bool, error) {
+	result, err := q.db.ExecContext(ctx, revokeSession, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
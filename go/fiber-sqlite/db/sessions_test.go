@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type SessionsTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *SessionsTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	for _, id := range []string{"se-1", "se-2"} {
+		_, err = s.q.CreateUser(context.Background(), CreateUserParams{
+			ID:       id,
+			Email:    id + "@example.com",
+			Password: hashPassword("password"),
+		})
+		s.NoError(err)
+	}
+}
+
+func (s *SessionsTestSuite) TearDownSuite() {
+	// cleanup, sessions and refresh tokens are removed by the cascade
+	s.q.db.ExecContext(context.Background(), "DELETE FROM users WHERE id IN ('se-1', 'se-2')")
+	s.conn.Close()
+}
+
+func (s *SessionsTestSuite) TestCreateSession() {
+	session := s.insertSession("session-a", "se-1", time.Hour)
+	s.Equal("Firefox", session.UserAgent)
+	s.Equal("10.0.0.1", session.IP)
+	s.NotEqual(0, session.CreatedAt)
+	s.Equal(session.CreatedAt, session.LastSeenAt)
+
+	sessions, err := s.q.GetUserSessions(context.Background(), "se-1")
+	s.NoError(err)
+	s.Contains(sessions, session)
+}
+
+func (s *SessionsTestSuite) TestExpiredSessionIsNotListed() {
+	s.insertSession("session-b", "se-2", -time.Minute)
+
+	sessions, err := s.q.GetUserSessions(context.Background(), "se-2")
+	s.NoError(err)
+	for _, session := range sessions {
+		s.NotEqual("session-b", session.ID)
+	}
+}
+
+func (s *SessionsTestSuite) TestTouchSession() {
+	s.insertSession("session-c", "se-1", time.Hour)
+
+	err := s.q.TouchSession(context.Background(), "session-c", "Safari", "10.0.0.2")
+	s.NoError(err)
+
+	sessions, err := s.q.GetUserSessions(context.Background(), "se-1")
+	s.NoError(err)
+	for _, session := range sessions {
+		if session.ID == "session-c" {
+			s.Equal("Safari", session.UserAgent)
+			s.Equal("10.0.0.2", session.IP)
+		}
+	}
+}
+
+func (s *SessionsTestSuite) TestRevokeSession() {
+	s.insertSession("session-d", "se-1", time.Hour)
+
+	revoked, err := s.q.RevokeSession(context.Background(), "session-d", "se-2")
+	s.NoError(err)
+	s.False(revoked, "Sessions can only be revoked by their owner")
+
+	revoked, err = s.q.RevokeSession(context.Background(), "session-d", "se-1")
+	s.NoError(err)
+	s.True(revoked)
+
+	sessions, err := s.q.GetUserSessions(context.Background(), "se-1")
+	s.NoError(err)
+	for _, session := range sessions {
+		s.NotEqual("session-d", session.ID)
+	}
+
+	revoked, err = s.q.RevokeSession(context.Background(), "session-d", "se-1")
+	s.NoError(err)
+	s.False(revoked)
+}
+
+func (s *SessionsTestSuite) TestIsSessionActive() {
+	s.insertSession("session-e", "se-2", time.Hour)
+	s.insertSession("session-f", "se-2", -time.Minute)
+
+	active, err := s.q.IsSessionActive(context.Background(), "session-e", "se-2")
+	s.NoError(err)
+	s.True(active)
+
+	active, err = s.q.IsSessionActive(context.Background(), "session-e", "se-1")
+	s.NoError(err)
+	s.False(active, "Sessions only belong to their owner")
+
+	active, err = s.q.IsSessionActive(context.Background(), "session-f", "se-2")
+	s.NoError(err)
+	s.False(active, "Expired sessions are not active")
+
+	_, err = s.q.RevokeSession(context.Background(), "session-e", "se-2")
+	s.NoError(err)
+
+	active, err = s.q.IsSessionActive(context.Background(), "session-e", "se-2")
+	s.NoError(err)
+	s.False(active, "Revoked sessions are not active")
+}
+
+func TestSessions(t *testing.T) {
+	suite.Run(t, new(SessionsTestSuite))
+}
+
+func (s *SessionsTestSuite) insertSession(id, userID string, ttl time.Duration) Session {
+	s.T().Helper()
+	session, err := s.q.CreateSession(context.Background(), CreateSessionParams{
+		ID:        id,
+		UserID:    userID,
+		UserAgent: "Firefox",
+		IP:        "10.0.0.1",
+	})
+	s.NoError(err)
+
+	_, err = s.q.CreateRefreshToken(context.Background(), CreateRefreshTokenParams{
+		ID:        id + "-token",
+		UserID:    userID,
+		FamilyID:  id,
+		TokenHash: id + "-hash",
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	})
+	s.NoError(err)
+	return session
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type TwoFactorTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *TwoFactorTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	_, err = s.q.CreateUser(context.Background(), CreateUserParams{
+		ID:       "tf-1",
+		Email:    "twofactor@example.com",
+		Password: hashPassword("password"),
+	})
+	s.NoError(err)
+}
+
+func (s *TwoFactorTestSuite) TearDownSuite() {
+	// cleanup, recovery codes are removed by the cascade
+	s.q.db.ExecContext(context.Background(), "DELETE FROM users WHERE id = 'tf-1'")
+	s.conn.Close()
+}
+
+func (s *TwoFactorTestSuite) TestEnableTOTP() {
+	ctx := context.Background()
+
+	enabled, err := s.q.EnableTOTP(ctx, "tf-1")
+	s.NoError(err)
+	s.False(enabled, "Cannot enable without a secret")
+
+	stored, err := s.q.SetTOTPSecret(ctx, "tf-1", "SECRET")
+	s.NoError(err)
+	s.True(stored)
+
+	enabled, err = s.q.EnableTOTP(ctx, "tf-1")
+	s.NoError(err)
+	s.True(enabled)
+
+	user, err := s.q.GetUserByID(ctx, "tf-1")
+	s.NoError(err)
+	s.Equal("SECRET", user.TOTPSecret.String)
+	s.True(user.TOTPEnabledAt.Valid)
+
+	stored, err = s.q.SetTOTPSecret(ctx, "tf-1", "OTHER")
+	s.NoError(err)
+	s.False(stored, "The secret cannot change once enabled")
+}
+
+func (s *TwoFactorTestSuite) TestUseTOTPCounter() {
+	ctx := context.Background()
+
+	used, err := s.q.UseTOTPCounter(ctx, "tf-1", 100)
+	s.NoError(err)
+	s.True(used)
+
+	used, err = s.q.UseTOTPCounter(ctx, "tf-1", 100)
+	s.NoError(err)
+	s.False(used, "A counter is only accepted once")
+
+	used, err = s.q.UseTOTPCounter(ctx, "tf-1", 99)
+	s.NoError(err)
+	s.False(used, "Older counters are refused")
+
+	used, err = s.q.UseTOTPCounter(ctx, "tf-1", 101)
+	s.NoError(err)
+	s.True(used)
+}
+
+func (s *TwoFactorTestSuite) TestConsumeRecoveryCode() {
+	ctx := context.Background()
+
+	s.NoError(s.q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{ID: "rc-1", UserID: "tf-1", CodeHash: "hash-1"}))
+
+	consumed, err := s.q.ConsumeRecoveryCode(ctx, "tf-1", "hash-1")
+	s.NoError(err)
+	s.True(consumed)
+
+	consumed, err = s.q.ConsumeRecoveryCode(ctx, "tf-1", "hash-1")
+	s.NoError(err)
+	s.False(consumed)
+
+	s.NoError(s.q.DeleteRecoveryCodes(ctx, "tf-1"))
+}
+
+func TestTwoFactor(t *testing.T) {
+	suite.Run(t, new(TwoFactorTestSuite))
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type TxTestSuite struct {
+	suite.Suite
+	path string
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *TxTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "tx.db")
+	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_fk=1&_txlock=immediate&_busy_timeout=1", s.path))
+	s.Require().NoError(err)
+	_, err = MigrateUp(context.Background(), conn)
+	s.Require().NoError(err)
+
+	s.q = NewDb(conn)
+	s.conn = conn
+}
+
+func (s *TxTestSuite) TearDownTest() {
+	s.conn.Close()
+}
+
+func (s *TxTestSuite) createUser(q *Queries, id string) error {
+	_, err := q.CreateUser(context.Background(), CreateUserParams{
+		ID:    id,
+		Email: id + "@example.com",
+	})
+	return err
+}
+
+func (s *TxTestSuite) userExists(id string) bool {
+	user, err := s.q.GetUserByID(context.Background(), id)
+	s.Require().NoError(err)
+	return user.Email != ""
+}
+
+func (s *TxTestSuite) TestCommit() {
+	err := s.q.RunInTx(context.Background(), func(q *Queries) error {
+		return s.createUser(q, "committed")
+	})
+	s.Require().NoError(err)
+	s.True(s.userExists("committed"))
+}
+
+func (s *TxTestSuite) TestRollback() {
+	failure := errors.New("failed")
+	err := s.q.RunInTx(context.Background(), func(q *Queries) error {
+		s.Require().NoError(s.createUser(q, "rolledback"))
+		return failure
+	})
+	s.ErrorIs(err, failure)
+	s.False(s.userExists("rolledback"))
+}
+
+func (s *TxTestSuite) TestNestedJoinsTransaction() {
+	err := s.q.RunInTx(context.Background(), func(q *Queries) error {
+		s.Require().NoError(s.createUser(q, "outer"))
+		return q.RunInTx(context.Background(), func(inner *Queries) error {
+			s.Same(q, inner)
+			return errors.New("failed")
+		})
+	})
+	s.Error(err)
+	s.False(s.userExists("outer"))
+}
+
+func (s *TxTestSuite) TestRetriesWhenBusy() {
+	locker, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_txlock=immediate", s.path))
+	s.Require().NoError(err)
+	defer locker.Close()
+
+	lock, err := locker.Begin()
+	s.Require().NoError(err)
+	go func() {
+		time.Sleep(3 * txRetryDelay)
+		lock.Rollback()
+	}()
+
+	err = s.q.RunInTx(context.Background(), func(q *Queries) error {
+		return s.createUser(q, "retried")
+	})
+	s.Require().NoError(err)
+	s.True(s.userExists("retried"))
+}
+
+func (s *TxTestSuite) TestGivesUpWhenBusy() {
+	locker, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_txlock=immediate", s.path))
+	s.Require().NoError(err)
+	defer locker.Close()
+
+	lock, err := locker.Begin()
+	s.Require().NoError(err)
+	defer lock.Rollback()
+
+	err = s.q.RunInTx(context.Background(), func(q *Queries) error {
+		return s.createUser(q, "busy")
+	})
+	var sqliteErr sqlite3.Error
+	s.Require().ErrorAs(err, &sqliteErr)
+	s.Equal(sqlite3.ErrBusy, sqliteErr.Code)
+}
+
+func TestTxTestSuite(t *testing.T) {
+	suite.Run(t, new(TxTestSuite))
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type UserIdentity struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Provider  string     `json:"provider"`
+	Subject   string     `json:"subject"`
+	Email     NullString `json:"email"`
+	CreatedAt int64      `json:"created_at"`
+}
+
+type CreateUserIdentityParams struct {
+	ID       string
+	UserID   string
+	Provider string
+	Subject  string
+	Email    NullString
+}
+
+const createUserIdentity = `
+INSERT INTO user_identities (id, user_id, provider, subject, email, created_at)
+VALUES ($1, $2, $3, $4, $5, unixepoch())
+RETURNING id, user_id, provider, subject, email, created_at
+`
+
+func (q *Queries) CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error) {
+	row := q.db.QueryRowContext(ctx, createUserIdentity, arg.ID, arg.UserID, arg.Provider, arg.Subject, arg.Email)
+	var i UserIdentity
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUserIdentity = `
+SELECT id, user_id, provider, subject, email, created_at
+FROM user_identities
+WHERE provider = $1 AND subject = $2
+`
+
+func (q *Queries) GetUserIdentity(ctx context.Context, provider string, subject string) (UserIdentity, error) {
+	row := q.db.QueryRowContext(ctx, getUserIdentity, provider, subject)
+	var i UserIdentity
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return UserIdentity{}, nil
+	}
+	return i, err
+}
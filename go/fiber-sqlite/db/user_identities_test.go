@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type UserIdentitiesTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *UserIdentitiesTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	_, err = s.q.CreateUser(context.Background(), CreateUserParams{
+		ID:       "ui-1",
+		Email:    "identities@example.com",
+		Password: hashPassword("password"),
+	})
+	s.NoError(err)
+}
+
+func (s *UserIdentitiesTestSuite) TearDownSuite() {
+	// cleanup, identities are removed by the cascade
+	s.q.db.ExecContext(context.Background(), "DELETE FROM users WHERE id = 'ui-1'")
+	s.conn.Close()
+}
+
+func (s *UserIdentitiesTestSuite) TestCreateUserIdentity() {
+	ctx := context.Background()
+
+	_, err := s.q.CreateUserIdentity(ctx, CreateUserIdentityParams{
+		ID:       "a",
+		UserID:   "ui-1",
+		Provider: "mock",
+		Subject:  "subject-a",
+	})
+	s.NoError(err)
+
+	identity, err := s.q.GetUserIdentity(ctx, "mock", "subject-a")
+	s.NoError(err)
+	s.Equal("ui-1", identity.UserID)
+	s.False(identity.Email.Valid)
+
+	identity, err = s.q.GetUserIdentity(ctx, "other", "subject-a")
+	s.NoError(err)
+	s.Equal("", identity.ID, "Subjects are scoped to their provider")
+}
+
+func (s *UserIdentitiesTestSuite) TestSubjectIsUniquePerProvider() {
+	ctx := context.Background()
+
+	params := CreateUserIdentityParams{ID: "b", UserID: "ui-1", Provider: "mock", Subject: "subject-b"}
+	_, err := s.q.CreateUserIdentity(ctx, params)
+	s.NoError(err)
+
+	params.ID = "c"
+	_, err = s.q.CreateUserIdentity(ctx, params)
+	s.Error(err)
+}
+
+func TestUserIdentities(t *testing.T) {
+	suite.Run(t, new(UserIdentitiesTestSuite))
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeTwoFactorLogin    = "two_factor_login"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+type UserToken struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Purpose   string    `json:"purpose"`
+	TokenHash string    `json:"-"`
+	ExpiresAt int64     `json:"expires_at"`
+	UsedAt    NullInt64 `json:"-"`
+	CreatedAt int64     `json:"created_at"`
+}
+
+type CreateUserTokenParams struct {
+	ID        string
+	UserID    string
+	Purpose   string
+	TokenHash string
+	ExpiresAt int64
+}
+
+const createUserToken = `
+INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5, unixepoch())
+RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+`
+
+func (q *Queries) CreateUserToken(ctx context.Context, arg CreateUserTokenParams) (UserToken, error) {
+	row := q.db.QueryRowContext(ctx, createUserToken, arg.ID, arg.UserID, arg.Purpose, arg.TokenHash, arg.ExpiresAt)
+	var i UserToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUserToken = `
+SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+FROM user_tokens
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > unixepoch()
+`
+
+// GetUserToken returns an unused, unexpired token without consuming it, or an
+// empty UserToken when there is none.
+func (q *Queries) GetUserToken(ctx context.Context, purpose string, tokenHash string) (UserToken, error) {
+	row := q.db.QueryRowContext(ctx, getUserToken, tokenHash, purpose)
+	var i UserToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return UserToken{}, nil
+	}
+	return i, err
+}
+
+const consumeUserToken = `
+UPDATE user_tokens
+SET used_at = unixepoch()
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > unixepoch()
+RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+`
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it.
+// An empty UserToken is returned when no such token exists, so every token
+// can be consumed at most once.
+func (q *Queries) ConsumeUserToken(ctx context.Context, purpose string, tokenHash string) (UserToken, error) {
+	row := q.db.QueryRowContext(ctx, consumeUserToken, tokenHash, purpose)
+	var i UserToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return UserToken{}, nil
+	}
+	return i, err
+}
+
+const deleteUserTokens = `
+DELETE FROM user_tokens
+WHERE user_id = $1 AND purpose = $2
+`
+
+func (q *Queries) DeleteUserTokens(ctx context.Context, userID string, purpose string) error {
+	_, err := q.db.ExecContext(ctx, deleteUserTokens, userID, purpose)
+	return err
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type UserTokensTestSuite struct {
+	suite.Suite
+	q    *Queries
+	conn *sql.DB
+}
+
+func (s *UserTokensTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = NewDb(conn)
+	s.conn = conn
+
+	_, err = s.q.CreateUser(context.Background(), CreateUserParams{
+		ID:       "ut-1",
+		Email:    "tokens@example.com",
+		Password: hashPassword("password"),
+	})
+	s.NoError(err)
+}
+
+func (s *UserTokensTestSuite) TearDownSuite() {
+	// cleanup, user tokens are removed by the cascade
+	s.q.db.ExecContext(context.Background(), "DELETE FROM users WHERE id = 'ut-1'")
+	s.conn.Close()
+}
+
+func (s *UserTokensTestSuite) TestConsumeUserToken() {
+	s.insertToken("a", "hash-a", time.Hour)
+
+	token, err := s.q.ConsumeUserToken(context.Background(), TokenPurposePasswordReset, "hash-a")
+	s.NoError(err)
+	s.Equal("a", token.ID)
+	s.Equal("ut-1", token.UserID)
+	s.True(token.UsedAt.Valid)
+
+	token, err = s.q.ConsumeUserToken(context.Background(), TokenPurposePasswordReset, "hash-a")
+	s.NoError(err)
+	s.Equal("", token.ID, "A token can only be consumed once")
+}
+
+func (s *UserTokensTestSuite) TestConsumeExpiredUserToken() {
+	s.insertToken("b", "hash-b", -time.Minute)
+
+	token, err := s.q.ConsumeUserToken(context.Background(), TokenPurposePasswordReset, "hash-b")
+	s.NoError(err)
+	s.Equal("", token.ID)
+}
+
+func (s *UserTokensTestSuite) TestConsumeUserTokenWithWrongPurpose() {
+	s.insertToken("c", "hash-c", time.Hour)
+
+	token, err := s.q.ConsumeUserToken(context.Background(), "other", "hash-c")
+	s.NoError(err)
+	s.Equal("", token.ID)
+}
+
+func (s *UserTokensTestSuite) TestGetUserTokenDoesNotConsume() {
+	s.insertToken("e", "hash-e", time.Hour)
+
+	token, err := s.q.GetUserToken(context.Background(), TokenPurposePasswordReset, "hash-e")
+	s.NoError(err)
+	s.Equal("e", token.ID)
+	s.False(token.UsedAt.Valid)
+
+	token, err = s.q.ConsumeUserToken(context.Background(), TokenPurposePasswordReset, "hash-e")
+	s.NoError(err)
+	s.Equal("e", token.ID)
+
+	token, err = s.q.GetUserToken(context.Background(), TokenPurposePasswordReset, "hash-e")
+	s.NoError(err)
+	s.Equal("", token.ID)
+}
+
+func (s *UserTokensTestSuite) TestDeleteUserTokens() {
+	s.insertToken("d", "hash-d", time.Hour)
+
+	err := s.q.DeleteUserTokens(context.Background(), "ut-1", TokenPurposePasswordReset)
+	s.NoError(err)
+
+	token, err := s.q.ConsumeUserToken(context.Background(), TokenPurposePasswordReset, "hash-d")
+	s.NoError(err)
+	s.Equal("", token.ID)
+}
+
+func TestUserTokens(t *testing.T) {
+	suite.Run(t, new(UserTokensTestSuite))
+}
+
+func (s *UserTokensTestSuite) insertToken(id, hash string, ttl time.Duration) UserToken {
+	s.T().Helper()
+	token, err := s.q.CreateUserToken(context.Background(), CreateUserTokenParams{
+		ID:        id,
+		UserID:    "ut-1",
+		Purpose:   TokenPurposePasswordReset,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	})
+	s.NoError(err)
+	s.False(token.UsedAt.Valid)
+	return token
+}
@@ -6,19 +6,28 @@ import (
 )
 
 type User struct {
-	ID        string     `json:"id"`
-	Name      NullString `json:"name"`
-	Email     string     `json:"email"`
-	Password  string     `json:"-"`
-	CreatedAt int64      `json:"created_at"`
-	UpdatedAt int64      `json:"updated_at"`
+	ID              string     `json:"id"`
+	Name            NullString `json:"name"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	CreatedAt       int64      `json:"created_at"`
+	UpdatedAt       int64      `json:"updated_at"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt NullInt64  `json:"email_verified_at"`
+	TOTPSecret      NullString `json:"-"`
+	TOTPEnabledAt   NullInt64  `json:"totp_enabled_at"`
 }
 
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
 type CreateUserParams struct {
 	ID       string     `json:"id" validate:"required,min=1,max=36"`
 	Name     NullString `json:"name"`
 	Email    string     `json:"email" validate:"required,email"`
-	Password string     `json:"password" validate:"required,min=8,max=15"`
+	Password string     `json:"password" validate:"omitempty,password=Email Name"`
 }
 
 type UpdateUserParams struct {
@@ -27,12 +36,13 @@ type UpdateUserParams struct {
 }
 
 const getUsers = `
-SELECT name, email, password, created_at, updated_at
+SELECT id, name, email, password, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at
 FROM users
 `
 
-func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.QueryContext(ctx, getUsers)
+func (q *Queries) GetUsers(ctx context.Context, arg ListQuery) ([]User, error) {
+	query, args := arg.build(getUsers)
+	rows, err := q.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -41,11 +51,16 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	for rows.Next() {
 		var i User
 		if err := rows.Scan(
+			&i.ID,
 			&i.Name,
 			&i.Email,
 			&i.Password,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Role,
+			&i.EmailVerifiedAt,
+			&i.TOTPSecret,
+			&i.TOTPEnabledAt,
 		); err != nil {
 			return nil, err
 		}
@@ -63,7 +78,7 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 const createUser = `
 INSERT INTO users (id, name, email, password, created_at, updated_at)
 VALUES ($1, $2, $3, $4, unixepoch(), unixepoch())
-RETURNING id, name, email, password, created_at, updated_at
+RETURNING id, name, email, password, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at
 `
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -76,12 +91,16 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TOTPSecret,
+		&i.TOTPEnabledAt,
 	)
 	return i, err
 }
 
 const getUserByEmail = `
-SELECT id, name, email, password, created_at, updated_at
+SELECT id, name, email, password, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at
 FROM users
 WHERE email = $1;
 `
@@ -96,6 +115,10 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TOTPSecret,
+		&i.TOTPEnabledAt,
 	)
 	if err == sql.ErrNoRows {
 		return User{}, nil
@@ -104,7 +127,7 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 }
 
 const getUserById = `
-SELECT id, name, email, password, created_at, updated_at
+SELECT id, name, email, password, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at
 FROM users
 WHERE id = $1;
 `
@@ -119,6 +142,10 @@ func (q *Queries) GetUserByID(ctx context.Context, id string) (User, error) {
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TOTPSecret,
+		&i.TOTPEnabledAt,
 	)
 	if err == sql.ErrNoRows {
 		return User{}, nil
@@ -130,7 +157,7 @@ const updateUser = `
 UPDATE users
 SET name = coalesce($1, name), password = coalesce($2, password), updated_at = unixepoch()
 WHERE id = $3
-RETURNING id, name, email, password, created_at, updated_at
+RETURNING id, name, email, password, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at
 `
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams, id string) (User, error) {
@@ -143,6 +170,10 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams, id strin
 		&i.Password,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TOTPSecret,
+		&i.TOTPEnabledAt,
 	)
 	return i, err
 }
@@ -156,3 +187,58 @@ func (q *Queries) DeleteUser(ctx context.Context, id string) error {
 	_, err := q.db.ExecContext(ctx, deleteUser, id)
 	return err
 }
+
+const markEmailVerified = `
+UPDATE users
+SET email_verified_at = coalesce(email_verified_at, unixepoch()), updated_at = unixepoch()
+WHERE id = $1
+RETURNING id, name, email, password, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at
+`
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, id string) (User, error) {
+	row := q.db.QueryRowContext(ctx, markEmailVerified, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TOTPSecret,
+		&i.TOTPEnabledAt,
+	)
+	return i, err
+}
+
+const setUserRole = `
+UPDATE users
+SET role = $1, updated_at = unixepoch()
+WHERE email = $2
+RETURNING id, name, email, password, created_at, updated_at, role, email_verified_at, totp_secret, totp_enabled_at
+`
+
+// SetUserRole changes the role of the user with the given email. It returns
+// an empty User when there is no such user.
+func (q *Queries) SetUserRole(ctx context.Context, email string, role string) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, role, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Password,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.TOTPSecret,
+		&i.TOTPEnabledAt,
+	)
+	if err == sql.ErrNoRows {
+		return User{}, nil
+	}
+	return i, err
+}
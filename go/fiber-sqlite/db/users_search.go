@@ -0,0 +1,11 @@
+package db
+
+const SearchUsersLimit = 20
+
+type UserSearchResult struct {
+	User
+	// Snippet is the best matching name or email as safe HTML: the text is
+	// escaped and only the matched terms are wrapped in <mark> tags.
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
@@ -0,0 +1,116 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// SearchEnabled reports whether user search is built in. It needs the
+// sqlite_fts5 build tag.
+const SearchEnabled = true
+
+//go:embed migrations/search/*.sql
+var searchMigrationFiles embed.FS
+
+// FTS5 marks matches with these control characters, which are swapped for
+// <mark> tags once the rest of the snippet has been escaped.
+const (
+	snippetMatchStart = "\x02"
+	snippetMatchEnd   = "\x03"
+)
+
+var snippetMarks = strings.NewReplacer(snippetMatchStart, "<mark>", snippetMatchEnd, "</mark>")
+
+const searchUsers = `
+SELECT u.id, u.name, u.email, u.password, u.created_at, u.updated_at, u.role, u.email_verified_at, u.totp_secret, u.totp_enabled_at,
+  snippet(users_fts, -1, char(2), char(3), '…', 8), users_fts.rank
+FROM users_fts
+JOIN users_fts_rowids r ON r.fts_rowid = users_fts.rowid
+JOIN users u ON u.id = r.user_id
+WHERE users_fts MATCH $1
+ORDER BY users_fts.rank
+LIMIT $2
+`
+
+// SearchUsers finds users whose name or email has words starting with each
+// word of q, best matches first.
+func (q *Queries) SearchUsers(ctx context.Context, query string) ([]UserSearchResult, error) {
+	items := []UserSearchResult{}
+	match := prefixMatchQuery(query)
+	if match == "" {
+		return items, nil
+	}
+
+	rows, err := q.db.QueryContext(ctx, searchUsers, match, SearchUsersLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i UserSearchResult
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Password,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Role,
+			&i.EmailVerifiedAt,
+			&i.TOTPSecret,
+			&i.TOTPEnabledAt,
+			&i.Snippet,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		i.Snippet = snippetHTML(i.Snippet)
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CheckSearch fails when the driver was built without FTS5, which the user
+// search index needs.
+func CheckSearch(conn *sql.DB) error {
+	var enabled bool
+	err := conn.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	if err == nil && !enabled {
+		return errors.New("SQLite was built without FTS5, build with -tags sqlite_fts5")
+	}
+	return err
+}
+
+// snippetHTML escapes the user-controlled text of a snippet and marks the
+// matched terms.
+func snippetHTML(snippet string) string {
+	return snippetMarks.Replace(html.EscapeString(snippet))
+}
+
+// prefixMatchQuery turns free text into an FTS5 query that matches every
+// word as a prefix. It splits words the way the unicode61 tokenizer does, so
+// no FTS5 syntax from the input survives.
+func prefixMatchQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,33 @@
+//go:build !sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+)
+
+const SearchEnabled = false
+
+// searchMigrationFiles is empty, so the search index is never created.
+var searchMigrationFiles embed.FS
+
+var errSearchDisabled = errors.New("user search needs the sqlite_fts5 build tag")
+
+// SearchUsers always fails, search is not built in.
+func (q *Queries) SearchUsers(ctx context.Context, query string) ([]UserSearchResult, error) {
+	return nil, errSearchDisabled
+}
+
+// CheckSearch fails when the database already has the user search index.
+// Its triggers need FTS5, so every write to users would fail.
+func CheckSearch(conn *sql.DB) error {
+	var indexed bool
+	err := conn.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE name = 'users_fts')").Scan(&indexed)
+	if err == nil && indexed {
+		return errors.New("the database has a user search index, build with -tags sqlite_fts5")
+	}
+	return err
+}
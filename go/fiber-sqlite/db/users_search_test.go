@@ -0,0 +1,100 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"context"
+)
+
+func (s *UsersTestSuite) TestSearchUsers() {
+	for _, user := range []struct {
+		id    string
+		name  string
+		email string
+	}{
+		{"s-1", "Zelda Quartermaine", "zelda@search.test"},
+		{"s-2", "Quincy Adams", "quartz@search.test"},
+		{"s-3", `<script>alert("x")</script> Xavier`, "xavier@search.test"},
+	} {
+		var name NullString
+		name.String = user.name
+		name.Valid = true
+		s.insertUser(CreateUserParams{ID: user.id, Name: name, Email: user.email, Password: hashPassword("password")})
+	}
+	defer s.q.db.ExecContext(context.Background(), "DELETE FROM users WHERE id IN ('s-1', 's-2', 's-3')")
+
+	results, err := s.q.SearchUsers(context.Background(), "quart")
+	s.NoError(err)
+	s.Len(results, 2)
+
+	results, err = s.q.SearchUsers(context.Background(), "zel QUART")
+	s.NoError(err)
+	s.Len(results, 1)
+	s.Equal("s-1", results[0].ID)
+	s.Equal("<mark>Zelda</mark> <mark>Quartermaine</mark>", results[0].Snippet)
+
+	results, err = s.q.SearchUsers(context.Background(), `"quartz@search`)
+	s.NoError(err)
+	s.Len(results, 1)
+	s.Equal("s-2", results[0].ID)
+
+	results, err = s.q.SearchUsers(context.Background(), "*")
+	s.NoError(err)
+	s.Empty(results)
+
+	// names are escaped, only the <mark> tags are markup
+	results, err = s.q.SearchUsers(context.Background(), "xavier")
+	s.NoError(err)
+	s.Require().Len(results, 1)
+	s.Equal("&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; <mark>Xavier</mark>", results[0].Snippet)
+
+	results, err = s.q.SearchUsers(context.Background(), "script")
+	s.NoError(err)
+	s.Require().Len(results, 1)
+	s.NotContains(results[0].Snippet, "<script>")
+	s.Contains(results[0].Snippet, "&lt;<mark>script</mark>&gt;")
+
+	// the index follows updates and deletes
+	var name NullString
+	name.String = "Zelda Fitzgerald"
+	name.Valid = true
+	_, err = s.q.UpdateUser(context.Background(), UpdateUserParams{Name: name}, "s-1")
+	s.NoError(err)
+	s.NoError(s.q.DeleteUser(context.Background(), "s-2"))
+
+	results, err = s.q.SearchUsers(context.Background(), "quart")
+	s.NoError(err)
+	s.Empty(results)
+
+	results, err = s.q.SearchUsers(context.Background(), "fitz")
+	s.NoError(err)
+	s.Len(results, 1)
+}
+
+func (s *MigrateTestSuite) TestSearchIndexCoversExistingUsers() {
+	ctx := context.Background()
+	_, err := MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+
+	// roll back to before the search index
+	for {
+		migration, err := MigrateDown(ctx, s.conn)
+		s.Require().NoError(err)
+		s.Require().NotNil(migration)
+		if migration.Version == "20230313094105" {
+			break
+		}
+	}
+	s.False(s.tableExists("users_fts_rowids"))
+
+	q := NewDb(s.conn)
+	_, err = q.CreateUser(ctx, CreateUserParams{ID: "m-1", Email: "marjorie@example.com"})
+	s.Require().NoError(err)
+
+	_, err = MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+
+	results, err := q.SearchUsers(ctx, "marj")
+	s.NoError(err)
+	s.Len(results, 1)
+}
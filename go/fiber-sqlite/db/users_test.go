@@ -17,7 +17,7 @@ type UsersTestSuite struct {
 }
 
 func (s *UsersTestSuite) SetupSuite() {
-	conn, err := sql.Open("sqlite3", "file:test.db?_fk=1")
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
 	if err != nil {
 		panic(err)
 	}
@@ -83,11 +83,24 @@ func (s *UsersTestSuite) TestCreateUser() {
 }
 
 func (s *UsersTestSuite) TestGetUsers() {
-	users, err := s.q.GetUsers(context.Background())
+	users, err := s.q.GetUsers(context.Background(), ListQuery{Limit: 10})
 	s.NoError(err)
 	s.Len(users, 4)
 }
 
+func (s *UsersTestSuite) TestGetUsersWithListQuery() {
+	users, err := s.q.GetUsers(context.Background(), ListQuery{
+		Conditions: []string{"id > ?", "email LIKE ?"},
+		Args:       []interface{}{"1", "%smith%"},
+		OrderBy:    []string{"id DESC"},
+		Limit:      2,
+	})
+	s.NoError(err)
+	s.Len(users, 2)
+	s.Equal("3", users[0].ID)
+	s.Equal("2", users[1].ID)
+}
+
 func (s *UsersTestSuite) TestGetUserByEmail() {
 	email := "johndoe@example.com"
 	user, err := s.q.GetUserByEmail(context.Background(), email)
@@ -159,6 +172,38 @@ func (s *UsersTestSuite) TestPartialUpdates() {
 	s.True(isPasswordSame("password", updatedUser.Password))
 }
 
+func (s *UsersTestSuite) TestMarkEmailVerified() {
+	id := "3"
+
+	user, err := s.q.MarkEmailVerified(context.Background(), id)
+	s.NoError(err)
+	s.True(user.EmailVerifiedAt.Valid)
+
+	verifiedAt := user.EmailVerifiedAt.Int64
+	user, err = s.q.MarkEmailVerified(context.Background(), id)
+	s.NoError(err)
+	s.Equal(verifiedAt, user.EmailVerifiedAt.Int64, "Verification time is kept")
+}
+
+func (s *UsersTestSuite) TestSetUserRole() {
+	email := "johnsmith@example.com"
+
+	user, err := s.q.SetUserRole(context.Background(), email, RoleAdmin)
+	s.NoError(err)
+	s.Equal(RoleAdmin, user.Role)
+
+	user, err = s.q.SetUserRole(context.Background(), email, RoleMember)
+	s.NoError(err)
+	s.Equal(RoleMember, user.Role)
+
+	user, err = s.q.SetUserRole(context.Background(), "nobody@example.com", RoleAdmin)
+	s.NoError(err)
+	s.Empty(user.ID)
+
+	_, err = s.q.SetUserRole(context.Background(), email, "owner")
+	s.Error(err, "The role must be one the CHECK constraint allows")
+}
+
 func TestUsers(t *testing.T) {
 	suite.Run(t, new(UsersTestSuite))
 }
@@ -173,6 +218,8 @@ func (s *UsersTestSuite) insertUser(userParams CreateUserParams) (User, error) {
 	s.Assert().Equal(userParams.Name.String, user.Name.String)
 	s.Assert().NotEqual(0, user.CreatedAt)
 	s.Assert().NotEqual(0, user.UpdatedAt)
+	s.Assert().False(user.EmailVerifiedAt.Valid)
+	s.Assert().Equal(RoleMember, user.Role)
 
 	return user, err
 }
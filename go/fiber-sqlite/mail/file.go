@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer returns a Mailer that drops every message into dir as an
+// .eml file instead of delivering it.
+func NewFileMailer(dir string, from string) Mailer {
+	return &fileMailer{
+		dir,
+		from,
+	}
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	data, err := encode(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	suffix, err := randomBoundary()
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), suffix[:8])
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o644)
+}
+
+type logMailer struct {
+	mu   sync.Mutex
+	out  io.Writer
+	from string
+}
+
+// NewLogMailer returns a Mailer that writes every message to out, which is
+// enough for local development.
+func NewLogMailer(out io.Writer, from string) Mailer {
+	return &logMailer{
+		out:  out,
+		from: from,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	data, err := encode(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err = m.out.Write(append(data, '\r', '\n'))
+	return err
+}
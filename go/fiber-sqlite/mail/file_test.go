@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FileMailerTestSuite struct {
+	suite.Suite
+}
+
+func (s *FileMailerTestSuite) TestFileMailer() {
+	dir := s.T().TempDir()
+	mailer := NewFileMailer(dir, "no-reply@example.com")
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "janedoe@example.com",
+		Subject: "Hello",
+		Text:    "Hello Jane",
+	})
+	s.NoError(err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	s.NoError(err)
+	s.Len(files, 1)
+
+	data, err := os.ReadFile(files[0])
+	s.NoError(err)
+	s.Contains(string(data), "To: janedoe@example.com")
+	s.Contains(string(data), "Hello Jane")
+}
+
+func (s *FileMailerTestSuite) TestLogMailer() {
+	var out bytes.Buffer
+	mailer := NewLogMailer(&out, "no-reply@example.com")
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "janedoe@example.com",
+		Subject: "Hello",
+		Text:    "Hello Jane",
+	})
+	s.NoError(err)
+	s.Contains(out.String(), "Subject: Hello")
+}
+
+func (s *FileMailerTestSuite) TestUnknownTemplate() {
+	_, err := NewMessage("janedoe@example.com", "Hello", "missing", nil)
+	s.Error(err)
+}
+
+func TestFileMailer(t *testing.T) {
+	suite.Run(t, new(FileMailerTestSuite))
+}
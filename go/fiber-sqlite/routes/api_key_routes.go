@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type createAPIKeyResponse struct {
+	db.APIKey
+	Key string `json:"key"`
+}
+
+func (s *Service) setupAPIKeyRoutes(router fiber.Router) {
+	router.Post("", s.requireAuth, forbidImpersonation, s.createAPIKeyHandler)
+	router.Get("", s.requireAuth, s.findAPIKeysHandler)
+	router.Delete("/:keyId", s.requireAuth, forbidImpersonation, s.deleteAPIKeyHandler)
+}
+
+// createAPIKeyHandler is limited to the account owner. Admins may list and
+// revoke other users' keys, but a key they made for someone else would let
+// them act as that user without the impersonation audit trail.
+func (s *Service) createAPIKeyHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if currentUserID(c) != id {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "Only the account owner can create API keys",
+		})
+	}
+
+	existingUser, err := s.queries.GetUserByID(c.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	if existingUser.Email == "" {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"message": "User not found",
+		})
+	}
+
+	keyParams := db.CreateAPIKeyParams{}
+
+	if err := c.BodyParser(&keyParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(keyParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	key, prefix, secretHash, err := utils.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+
+	keyParams.ID = s.idGen.Generate()
+	keyParams.UserID = id
+	keyParams.Prefix = prefix
+	keyParams.SecretHash = secretHash
+
+	apiKey, err := s.queries.CreateAPIKey(c.Context(), keyParams)
+	if err != nil {
+		return err
+	}
+
+	// The plain key is only ever shown in this response.
+	return c.Status(fiber.StatusCreated).JSON(createAPIKeyResponse{apiKey, key})
+}
+
+func (s *Service) findAPIKeysHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if !canManageUser(c, id) {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "You are not allowed to do this",
+		})
+	}
+
+	keys, err := s.queries.GetUserAPIKeys(c.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(keys)
+}
+
+func (s *Service) deleteAPIKeyHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if !canManageUser(c, id) {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "You are not allowed to do this",
+		})
+	}
+
+	deleted, err := s.queries.DeleteAPIKey(c.Context(), c.Params("keyId"), id)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"message": "API key not found",
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
@@ -0,0 +1,178 @@
+package routes
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type APIKeyRoutesTestSuite struct {
+	suite.Suite
+	q          *db.Queries
+	conn       *sql.DB
+	app        *fiber.App
+	token      string
+	adminToken string
+}
+
+func (s *APIKeyRoutesTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	idGen := utils.NewNanoIDGenerator(21)
+	tokens := utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, tokens, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+
+	s.token, err = createSessionToken(s.q, tokens, "2", db.RoleMember)
+	if err != nil {
+		panic(err)
+	}
+
+	s.conn.Exec("UPDATE users SET role = 'admin' WHERE id = '1'")
+	s.adminToken, err = createSessionToken(s.q, tokens, "1", db.RoleAdmin)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *APIKeyRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+}
+
+func (s *APIKeyRoutesTestSuite) TestCreateAndUseAPIKey() {
+	key := s.createKey("2", "ci")
+	s.NotEmpty(key.Key)
+	s.Equal("ci", key.Name)
+	s.False(key.LastUsedAt.Valid)
+
+	req := httptest.NewRequest("GET", "/api/v1/users/2", nil)
+	req.Header.Set("Authorization", "Bearer "+key.Key)
+
+	var user db.User
+	s.checkReqStatus(req, fiber.StatusOK, &user)
+	s.Equal("2", user.ID)
+
+	var keys []db.APIKey
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users/2/keys", nil), fiber.StatusOK, &keys)
+
+	s.NotEmpty(keys)
+	for _, k := range keys {
+		if k.ID == key.ID {
+			s.True(k.LastUsedAt.Valid, "Last used time is tracked")
+		}
+	}
+}
+
+func (s *APIKeyRoutesTestSuite) TestAPIKeyWithWrongSecret() {
+	key := s.createKey("2", "wrong secret")
+
+	prefix, _, _ := utils.SplitAPIKey(key.Key)
+	req := httptest.NewRequest("GET", "/api/v1/users/2", nil)
+	req.Header.Set("Authorization", "Bearer "+prefix+".not-the-secret")
+
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *APIKeyRoutesTestSuite) TestRevokeAPIKey() {
+	key := s.createKey("2", "revoke me")
+
+	req := httptest.NewRequest("DELETE", "/api/v1/users/2/keys/"+key.ID, nil)
+	s.checkReqStatus(req, fiber.StatusNoContent, nil)
+
+	req = httptest.NewRequest("GET", "/api/v1/users/2", nil)
+	req.Header.Set("Authorization", "Bearer "+key.Key)
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+
+	req = httptest.NewRequest("DELETE", "/api/v1/users/2/keys/"+key.ID, nil)
+	s.checkReqStatus(req, fiber.StatusNotFound, nil)
+}
+
+func (s *APIKeyRoutesTestSuite) TestCannotManageOtherUsersKeys() {
+	req := httptest.NewRequest("POST", "/api/v1/users/1/keys", bytes.NewBufferString(`{"name": "stolen"}`))
+	req.Header.Set("Content-Type", "application/json")
+	s.checkReqStatus(req, fiber.StatusForbidden, nil)
+
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users/1/keys", nil), fiber.StatusForbidden, nil)
+}
+
+func (s *APIKeyRoutesTestSuite) TestAdminCannotCreateOtherUsersKeys() {
+	req := httptest.NewRequest("POST", "/api/v1/users/2/keys", bytes.NewBufferString(`{"name": "support"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.adminToken)
+	s.checkReqStatus(req, fiber.StatusForbidden, nil)
+
+	// admins can still see and revoke them
+	req = httptest.NewRequest("GET", "/api/v1/users/2/keys", nil)
+	req.Header.Set("Authorization", "Bearer "+s.adminToken)
+	s.checkReqStatus(req, fiber.StatusOK, nil)
+}
+
+func (s *APIKeyRoutesTestSuite) TestCreateAPIKeyWithInvalidBody() {
+	req := httptest.NewRequest("POST", "/api/v1/users/2/keys", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(req, fiber.StatusBadRequest, &errors)
+	s.Equal(1, len(errors))
+}
+
+func TestAPIKeyRoutes(t *testing.T) {
+	suite.Run(t, new(APIKeyRoutesTestSuite))
+}
+
+func (s *APIKeyRoutesTestSuite) createKey(userID, name string) createAPIKeyResponse {
+	s.T().Helper()
+	body, _ := json.Marshal(db.CreateAPIKeyParams{Name: name})
+	req := httptest.NewRequest("POST", "/api/v1/users/"+userID+"/keys", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	var key createAPIKeyResponse
+	s.checkReqStatus(req, fiber.StatusCreated, &key)
+	return key
+}
+
+func (s *APIKeyRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	if req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
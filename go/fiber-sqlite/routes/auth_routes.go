@@ -0,0 +1,244 @@
+package routes
+
+import (
+	"context"
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	accessTokenDuration  = 15 * time.Minute
+	refreshTokenDuration = 30 * 24 * time.Hour
+)
+
+type LoginParams struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshParams struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (s *Service) setupAuthRoutes(router fiber.Router) {
+	router.Post("/login", s.loginHandler)
+	router.Post("/refresh", s.refreshHandler)
+	router.Post("/logout", s.logoutHandler)
+}
+
+func (s *Service) loginHandler(c *fiber.Ctx) error {
+	loginParams := LoginParams{}
+
+	if err := c.BodyParser(&loginParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(loginParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	if err := s.checkLoginThrottle(c, loginParams.Email); err != nil {
+		return err
+	}
+
+	user, err := s.queries.GetUserByEmail(c.Context(), loginParams.Email)
+	if err != nil {
+		return err
+	}
+
+	valid, needsRehash := false, false
+	if user.Email != "" {
+		valid, needsRehash, err = s.hasher.Verify(loginParams.Password, user.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !valid {
+		if err := s.recordLoginFailure(c, loginParams.Email); err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid email or password",
+		})
+	}
+
+	// Upgrade hashes made with an older algorithm or weaker parameters
+	// while the plain password is at hand.
+	if needsRehash {
+		if err := s.setPassword(c, user.ID, loginParams.Password); err != nil {
+			return err
+		}
+	}
+
+	return s.completeLogin(c, user)
+}
+
+// completeLogin runs the checks shared by every way of signing in and then
+// either starts the two-factor step or issues tokens.
+func (s *Service) completeLogin(c *fiber.Ctx, user db.User) error {
+	if s.config.RequireVerifiedEmail && !user.EmailVerifiedAt.Valid {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "Email address is not verified",
+		})
+	}
+
+	if user.TOTPEnabledAt.Valid {
+		challenge, err := s.createTwoFactorChallenge(c, user.ID)
+		if err != nil {
+			return err
+		}
+		return c.JSON(challenge)
+	}
+
+	if err := s.clearLoginFailures(c, user.Email); err != nil {
+		return err
+	}
+
+	tokens, err := s.startSession(c, user)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(tokens)
+}
+
+func (s *Service) refreshHandler(c *fiber.Ctx) error {
+	refreshParams := RefreshParams{}
+
+	if err := c.BodyParser(&refreshParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(refreshParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	existingToken, err := s.queries.GetRefreshTokenByHash(c.Context(), utils.HashOpaqueToken(refreshParams.RefreshToken))
+	if err != nil {
+		return err
+	}
+
+	if existingToken.ID == "" || existingToken.ExpiresAt <= time.Now().Unix() {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid refresh token",
+		})
+	}
+
+	nextID := s.idGen.Generate()
+	rotated, err := s.queries.RotateRefreshToken(c.Context(), existingToken.ID, nextID)
+	if err != nil {
+		return err
+	}
+
+	if !rotated {
+		// A rotated token was presented again, so it may have been stolen.
+		// Revoke every token issued from the same login.
+		if err := s.queries.RevokeRefreshTokenFamily(c.Context(), existingToken.FamilyID); err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid refresh token",
+		})
+	}
+
+	user, err := s.queries.GetUserByID(c.Context(), existingToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := s.issueTokens(c.Context(), nextID, user, existingToken.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.queries.TouchSession(c.Context(), existingToken.FamilyID, c.Get(fiber.HeaderUserAgent), c.IP()); err != nil {
+		return err
+	}
+
+	return c.JSON(tokens)
+}
+
+func (s *Service) logoutHandler(c *fiber.Ctx) error {
+	refreshParams := RefreshParams{}
+
+	if err := c.BodyParser(&refreshParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(refreshParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	existingToken, err := s.queries.GetRefreshTokenByHash(c.Context(), utils.HashOpaqueToken(refreshParams.RefreshToken))
+	if err != nil {
+		return err
+	}
+
+	if existingToken.ID != "" {
+		if err := s.queries.RevokeRefreshTokenFamily(c.Context(), existingToken.FamilyID); err != nil {
+			return err
+		}
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// startSession records the device a login came from and issues its first
+// tokens. The session ID doubles as the refresh token family ID.
+func (s *Service) startSession(c *fiber.Ctx, user db.User) (tokenResponse, error) {
+	session, err := s.queries.CreateSession(c.Context(), db.CreateSessionParams{
+		ID:        s.idGen.Generate(),
+		UserID:    user.ID,
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+	})
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	return s.issueTokens(c.Context(), s.idGen.Generate(), user, session.ID)
+}
+
+func (s *Service) issueTokens(ctx context.Context, refreshTokenID string, user db.User, familyID string) (tokenResponse, error) {
+	accessToken, err := s.tokens.CreateToken(user.ID, user.Role, familyID, accessTokenDuration)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	refreshToken, refreshTokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	_, err = s.queries.CreateRefreshToken(ctx, db.CreateRefreshTokenParams{
+		ID:        refreshTokenID,
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: refreshTokenHash,
+		ExpiresAt: time.Now().Add(refreshTokenDuration).Unix(),
+	})
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	return tokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenDuration.Seconds()),
+	}, nil
+}
@@ -0,0 +1,210 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthRoutesTestSuite struct {
+	suite.Suite
+	q      *db.Queries
+	conn   *sql.DB
+	app    *fiber.App
+	tokens utils.TokenMaker
+}
+
+func (s *AuthRoutesTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	idGen := utils.NewNanoIDGenerator(21)
+	s.tokens = utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, s.tokens, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+}
+
+func (s *AuthRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+}
+
+func (s *AuthRoutesTestSuite) TestLogin() {
+	req := s.loginRequest(`{"email": "johndoe@example.com", "password": "password"}`)
+
+	var tokens tokenResponse
+	s.checkReqStatus(req, fiber.StatusOK, &tokens)
+
+	s.Equal("Bearer", tokens.TokenType)
+	s.NotEmpty(tokens.AccessToken)
+	s.NotEmpty(tokens.RefreshToken)
+
+	claims, err := s.tokens.VerifyToken(tokens.AccessToken)
+	s.NoError(err)
+	s.Equal("1", claims.Subject)
+}
+
+func (s *AuthRoutesTestSuite) TestLoginUpgradesPasswordHash() {
+	before, err := s.q.GetUserByID(context.Background(), "3")
+	s.NoError(err)
+	s.True(strings.HasPrefix(before.Password, "$2"), "Seeded users have bcrypt hashes")
+
+	s.login("ashwin@example.com", "password")
+
+	after, err := s.q.GetUserByID(context.Background(), "3")
+	s.NoError(err)
+	s.True(strings.HasPrefix(after.Password, "$argon2id$"))
+
+	s.login("ashwin@example.com", "password")
+}
+
+func (s *AuthRoutesTestSuite) TestLoginWithWrongPassword() {
+	req := s.loginRequest(`{"email": "johndoe@example.com", "password": "wrongpassword"}`)
+
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestLoginWithUnknownEmail() {
+	req := s.loginRequest(`{"email": "nobody@example.com", "password": "password"}`)
+
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestLoginWithInvalidBody() {
+	req := s.loginRequest(`{"email": "johndoe"}`)
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(req, fiber.StatusBadRequest, &errors)
+
+	s.Equal(2, len(errors))
+}
+
+func (s *AuthRoutesTestSuite) TestAccessProtectedRoute() {
+	req := s.loginRequest(`{"email": "janedoe@example.com", "password": "password"}`)
+
+	var tokens tokenResponse
+	s.checkReqStatus(req, fiber.StatusOK, &tokens)
+
+	req = httptest.NewRequest("GET", "/api/v1/users/2", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+	var user db.User
+	s.checkReqStatus(req, fiber.StatusOK, &user)
+	s.Equal("janedoe@example.com", user.Email)
+}
+
+func (s *AuthRoutesTestSuite) TestAccessProtectedRouteWithInvalidToken() {
+	req := httptest.NewRequest("GET", "/api/v1/users/2", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestRefresh() {
+	tokens := s.login("ashwin@example.com", "password")
+
+	req := s.refreshRequest("/api/v1/auth/refresh", tokens.RefreshToken)
+
+	var refreshed tokenResponse
+	s.checkReqStatus(req, fiber.StatusOK, &refreshed)
+
+	s.NotEmpty(refreshed.AccessToken)
+	s.NotEqual(tokens.RefreshToken, refreshed.RefreshToken, "Refresh token is rotated")
+
+	claims, err := s.tokens.VerifyToken(refreshed.AccessToken)
+	s.NoError(err)
+	s.Equal("3", claims.Subject)
+}
+
+func (s *AuthRoutesTestSuite) TestRefreshTokenReuseRevokesFamily() {
+	tokens := s.login("ashwin@example.com", "password")
+
+	var refreshed tokenResponse
+	s.checkReqStatus(s.refreshRequest("/api/v1/auth/refresh", tokens.RefreshToken), fiber.StatusOK, &refreshed)
+
+	// replaying the rotated token is treated as theft
+	s.checkReqStatus(s.refreshRequest("/api/v1/auth/refresh", tokens.RefreshToken), fiber.StatusUnauthorized, nil)
+
+	// so the token handed out by the legitimate rotation is revoked too
+	s.checkReqStatus(s.refreshRequest("/api/v1/auth/refresh", refreshed.RefreshToken), fiber.StatusUnauthorized, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestRefreshWithUnknownToken() {
+	s.checkReqStatus(s.refreshRequest("/api/v1/auth/refresh", "unknown"), fiber.StatusUnauthorized, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestLogout() {
+	tokens := s.login("johndoe@example.com", "password")
+
+	s.checkReqStatus(s.refreshRequest("/api/v1/auth/logout", tokens.RefreshToken), fiber.StatusNoContent, nil)
+	s.checkReqStatus(s.refreshRequest("/api/v1/auth/refresh", tokens.RefreshToken), fiber.StatusUnauthorized, nil)
+}
+
+func TestAuthRoutes(t *testing.T) {
+	suite.Run(t, new(AuthRoutesTestSuite))
+}
+
+func (s *AuthRoutesTestSuite) loginRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/v1/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *AuthRoutesTestSuite) refreshRequest(path string, refreshToken string) *http.Request {
+	body, _ := json.Marshal(RefreshParams{RefreshToken: refreshToken})
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *AuthRoutesTestSuite) login(email, password string) tokenResponse {
+	s.T().Helper()
+	body, _ := json.Marshal(LoginParams{Email: email, Password: password})
+
+	var tokens tokenResponse
+	s.checkReqStatus(s.loginRequest(string(body)), fiber.StatusOK, &tokens)
+	return tokens
+}
+
+func (s *AuthRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
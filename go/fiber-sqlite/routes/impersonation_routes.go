@@ -0,0 +1,157 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const impersonationDuration = 30 * time.Minute
+
+type StartImpersonationParams struct {
+	Reason string `json:"reason" validate:"required,min=1,max=500"`
+}
+
+type impersonationResponse struct {
+	ImpersonationID string `json:"impersonation_id"`
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+}
+
+func (s *Service) setupImpersonationRoutes(router fiber.Router) {
+	router.Post("", s.requireAuth, requireRole(db.RoleAdmin), s.startImpersonationHandler)
+	router.Delete("", s.requireAuth, s.stopImpersonationHandler)
+}
+
+// startImpersonationHandler hands an admin a short-lived access token for the
+// target user. No refresh token is issued, so the impersonation cannot
+// outlive impersonationDuration.
+func (s *Service) startImpersonationHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == currentUserID(c) {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "You cannot impersonate yourself",
+		})
+	}
+
+	impersonationParams := StartImpersonationParams{}
+
+	if err := c.BodyParser(&impersonationParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(impersonationParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	user, err := s.queries.GetUserByID(c.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	if user.Email == "" {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"message": "User not found",
+		})
+	}
+
+	if user.Role == db.RoleAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "Admins cannot be impersonated",
+		})
+	}
+
+	impersonation, err := s.queries.CreateImpersonation(c.Context(), db.CreateImpersonationParams{
+		ID:        s.idGen.Generate(),
+		AdminID:   currentUserID(c),
+		UserID:    user.ID,
+		Reason:    impersonationParams.Reason,
+		ExpiresAt: time.Now().Add(impersonationDuration).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.recordImpersonationEvent(c, s.queries, impersonation, db.AuditImpersonationStart, impersonation.Reason); err != nil {
+		return err
+	}
+
+	accessToken, err := s.tokens.CreateImpersonationToken(user.ID, user.Role, impersonation.ID, impersonationDuration)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(impersonationResponse{
+		ImpersonationID: impersonation.ID,
+		AccessToken:     accessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(impersonationDuration.Seconds()),
+	})
+}
+
+// stopImpersonationHandler ends the impersonation the request is made under,
+// which invalidates its access token at once.
+func (s *Service) stopImpersonationHandler(c *fiber.Ctx) error {
+	impersonation := currentImpersonation(c)
+	if impersonation.ID == "" || impersonation.UserID != c.Params("id") {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Not impersonating this user",
+		})
+	}
+
+	if err := s.endImpersonation(c, impersonation, ""); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// endImpersonation ends the impersonation and records a stop event giving
+// the reason in detail. Only the request that ends it records the event.
+func (s *Service) endImpersonation(c *fiber.Ctx, impersonation db.Impersonation, detail string) error {
+	return s.queries.RunInTx(c.Context(), func(q *db.Queries) error {
+		ended, err := q.EndImpersonation(c.Context(), impersonation.ID)
+		if err != nil || !ended {
+			return err
+		}
+
+		return s.recordImpersonationEvent(c, q, impersonation, db.AuditImpersonationStop, detail)
+	})
+}
+
+// endAdminImpersonations ends every active impersonation the admin started,
+// for when they lose the admin role or their account.
+func (s *Service) endAdminImpersonations(c *fiber.Ctx, q *db.Queries, adminID string, detail string) error {
+	impersonations, err := q.EndAdminImpersonations(c.Context(), adminID)
+	if err != nil {
+		return err
+	}
+
+	for _, impersonation := range impersonations {
+		if err := s.recordImpersonationEvent(c, q, impersonation, db.AuditImpersonationStop, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) recordImpersonationEvent(c *fiber.Ctx, q *db.Queries, impersonation db.Impersonation, action string, detail string) error {
+	var impersonationID db.NullString
+	impersonationID.String = impersonation.ID
+	impersonationID.Valid = true
+
+	_, err := q.CreateAuditEvent(c.Context(), db.CreateAuditEventParams{
+		ID:              s.idGen.Generate(),
+		ActorID:         impersonation.AdminID,
+		UserID:          impersonation.UserID,
+		ImpersonationID: impersonationID,
+		Action:          action,
+		Detail:          detail,
+		IP:              c.IP(),
+	})
+	return err
+}
@@ -0,0 +1,251 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type ImpersonationRoutesTestSuite struct {
+	suite.Suite
+	q           *db.Queries
+	conn        *sql.DB
+	app         *fiber.App
+	adminToken  string
+	memberToken string
+}
+
+func (s *ImpersonationRoutesTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	idGen := utils.NewNanoIDGenerator(21)
+	tokens := utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, tokens, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+
+	if _, err := s.q.SetUserRole(context.Background(), "johndoe@example.com", db.RoleAdmin); err != nil {
+		panic(err)
+	}
+
+	s.adminToken, err = createSessionToken(s.q, tokens, "1", db.RoleAdmin)
+	if err != nil {
+		panic(err)
+	}
+
+	s.memberToken, err = createSessionToken(s.q, tokens, "2", db.RoleMember)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *ImpersonationRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM audit_events")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+}
+
+func (s *ImpersonationRoutesTestSuite) TestImpersonateUser() {
+	impersonation := s.start("2")
+	s.NotEmpty(impersonation.ImpersonationID)
+	s.Equal(int64(impersonationDuration.Seconds()), impersonation.ExpiresIn)
+
+	resp := s.checkReqStatus(s.request("GET", "/api/v1/users/2", nil, impersonation.AccessToken), fiber.StatusOK)
+	s.Equal("1", resp.Header.Get(headerImpersonatedBy), "Requests are marked with the admin")
+
+	// the admin only gets the user's own permissions
+	s.checkReqStatus(s.request("GET", "/api/v1/users/3", nil, impersonation.AccessToken), fiber.StatusForbidden)
+	s.checkReqStatus(s.request("GET", "/api/v1/users", nil, impersonation.AccessToken), fiber.StatusForbidden)
+
+	resp = s.checkReqStatus(s.request("GET", "/api/v1/users/1", nil, s.adminToken), fiber.StatusOK)
+	s.Empty(resp.Header.Get(headerImpersonatedBy))
+}
+
+func (s *ImpersonationRoutesTestSuite) TestSensitiveActionsAreForbidden() {
+	token := s.start("2").AccessToken
+
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/2", map[string]string{"password": "newpassword"}, token), fiber.StatusForbidden)
+	s.checkReqStatus(s.request("DELETE", "/api/v1/users/2", nil, token), fiber.StatusForbidden)
+	s.checkReqStatus(s.request("POST", "/api/v1/users/2/keys", db.CreateAPIKeyParams{Name: "ci"}, token), fiber.StatusForbidden)
+	s.checkReqStatus(s.request("POST", "/api/v1/auth/2fa/enroll", nil, token), fiber.StatusForbidden)
+	s.checkReqStatus(s.request("DELETE", "/api/v1/me/sessions", nil, token), fiber.StatusForbidden)
+
+	s.checkReqStatus(s.request("PATCH", "/api/v1/users/2", map[string]string{"name": "Jane Q"}, token), fiber.StatusOK)
+
+	user, err := s.q.GetUserByID(context.Background(), "2")
+	s.NoError(err)
+	s.Equal("Jane Q", user.Name.String)
+}
+
+func (s *ImpersonationRoutesTestSuite) TestStopImpersonation() {
+	impersonation := s.start("3")
+	token := impersonation.AccessToken
+
+	s.checkReqStatus(s.request("GET", "/api/v1/users/3", nil, token), fiber.StatusOK)
+	s.checkReqStatus(s.request("DELETE", "/api/v1/users/2/impersonation", nil, token), fiber.StatusBadRequest)
+	s.checkReqStatus(s.request("DELETE", "/api/v1/users/3/impersonation", nil, token), fiber.StatusNoContent)
+
+	s.checkReqStatus(s.request("GET", "/api/v1/users/3", nil, token), fiber.StatusUnauthorized)
+
+	events, err := s.q.GetImpersonationAuditEvents(context.Background(), impersonation.ImpersonationID)
+	s.NoError(err)
+
+	var actions []string
+	for _, event := range events {
+		s.Equal("1", event.ActorID)
+		s.Equal("3", event.UserID)
+		actions = append(actions, event.Action+" "+event.Detail)
+	}
+	s.Equal([]string{
+		db.AuditImpersonationStart + " Reproduce a support ticket",
+		db.AuditImpersonationRequest + " GET /api/v1/users/3",
+		db.AuditImpersonationRequest + " DELETE /api/v1/users/2/impersonation",
+		db.AuditImpersonationRequest + " DELETE /api/v1/users/3/impersonation",
+		db.AuditImpersonationStop + " ",
+	}, actions)
+}
+
+func (s *ImpersonationRoutesTestSuite) TestExpiredImpersonation() {
+	impersonation := s.start("3")
+	s.conn.Exec("UPDATE impersonations SET expires_at = unixepoch() - 1 WHERE id = ?", impersonation.ImpersonationID)
+
+	s.checkReqStatus(s.request("GET", "/api/v1/users/3", nil, impersonation.AccessToken), fiber.StatusUnauthorized)
+	s.checkReqStatus(s.request("GET", "/api/v1/users/3", nil, impersonation.AccessToken), fiber.StatusUnauthorized)
+
+	s.Equal([]string{
+		db.AuditImpersonationStart + " Reproduce a support ticket",
+		db.AuditImpersonationStop + " expired",
+	}, s.auditTrail(impersonation.ImpersonationID), "The stop is recorded once")
+}
+
+func (s *ImpersonationRoutesTestSuite) TestDemotedAdminLosesImpersonations() {
+	first := s.start("2")
+	second := s.start("3")
+
+	s.conn.Exec("UPDATE users SET role = 'member' WHERE id = '1'")
+	defer s.conn.Exec("UPDATE users SET role = 'admin' WHERE id = '1'")
+
+	s.checkReqStatus(s.request("GET", "/api/v1/users/2", nil, first.AccessToken), fiber.StatusUnauthorized)
+	s.checkReqStatus(s.request("GET", "/api/v1/users/3", nil, second.AccessToken), fiber.StatusUnauthorized)
+
+	for _, impersonation := range []impersonationResponse{first, second} {
+		s.Equal([]string{
+			db.AuditImpersonationStart + " Reproduce a support ticket",
+			db.AuditImpersonationStop + " admin role revoked",
+		}, s.auditTrail(impersonation.ImpersonationID))
+	}
+}
+
+func (s *ImpersonationRoutesTestSuite) TestDeletedAdminLosesImpersonations() {
+	s.conn.Exec("INSERT INTO users (id, email, password, role) VALUES ('9', 'oldadmin@example.com', '', 'admin')")
+	token, err := createSessionToken(s.q, utils.NewJWTMaker(testJWTSecret), "9", db.RoleAdmin)
+	s.Require().NoError(err)
+
+	body := StartImpersonationParams{Reason: "Reproduce a support ticket"}
+	resp := s.checkReqStatus(s.request("POST", "/api/v1/users/2/impersonation", body, token), fiber.StatusCreated)
+	var impersonation impersonationResponse
+	s.NoError(json.NewDecoder(resp.Body).Decode(&impersonation))
+
+	s.checkReqStatus(s.request("DELETE", "/api/v1/users/9", nil, token), fiber.StatusNoContent)
+
+	s.checkReqStatus(s.request("GET", "/api/v1/users/2", nil, impersonation.AccessToken), fiber.StatusUnauthorized)
+	s.Equal([]string{
+		db.AuditImpersonationStart + " Reproduce a support ticket",
+		db.AuditImpersonationStop + " admin deleted",
+	}, s.auditTrail(impersonation.ImpersonationID))
+}
+
+func (s *ImpersonationRoutesTestSuite) TestOnlyAdminsCanImpersonate() {
+	body := StartImpersonationParams{Reason: "Curious"}
+	s.checkReqStatus(s.request("POST", "/api/v1/users/3/impersonation", body, s.memberToken), fiber.StatusForbidden)
+}
+
+func (s *ImpersonationRoutesTestSuite) TestCannotImpersonateAdminsOrSelf() {
+	body := StartImpersonationParams{Reason: "Reproduce a support ticket"}
+	s.checkReqStatus(s.request("POST", "/api/v1/users/1/impersonation", body, s.adminToken), fiber.StatusBadRequest)
+
+	s.conn.Exec("UPDATE users SET role = 'admin' WHERE id = '3'")
+	defer s.conn.Exec("UPDATE users SET role = 'member' WHERE id = '3'")
+
+	s.checkReqStatus(s.request("POST", "/api/v1/users/3/impersonation", body, s.adminToken), fiber.StatusForbidden)
+}
+
+func (s *ImpersonationRoutesTestSuite) TestImpersonationRequiresReason() {
+	s.checkReqStatus(s.request("POST", "/api/v1/users/2/impersonation", StartImpersonationParams{}, s.adminToken), fiber.StatusBadRequest)
+	s.checkReqStatus(s.request("POST", "/api/v1/users/missing/impersonation", StartImpersonationParams{Reason: "Why"}, s.adminToken), fiber.StatusNotFound)
+}
+
+func TestImpersonationRoutes(t *testing.T) {
+	suite.Run(t, new(ImpersonationRoutesTestSuite))
+}
+
+func (s *ImpersonationRoutesTestSuite) start(userID string) impersonationResponse {
+	s.T().Helper()
+	body := StartImpersonationParams{Reason: "Reproduce a support ticket"}
+	resp := s.checkReqStatus(s.request("POST", "/api/v1/users/"+userID+"/impersonation", body, s.adminToken), fiber.StatusCreated)
+
+	var impersonation impersonationResponse
+	raw, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	s.NoError(err)
+	s.NoError(json.Unmarshal(raw, &impersonation))
+	return impersonation
+}
+
+func (s *ImpersonationRoutesTestSuite) auditTrail(impersonationID string) []string {
+	s.T().Helper()
+	events, err := s.q.GetImpersonationAuditEvents(context.Background(), impersonationID)
+	s.NoError(err)
+
+	actions := []string{}
+	for _, event := range events {
+		actions = append(actions, event.Action+" "+event.Detail)
+	}
+	return actions
+}
+
+func (s *ImpersonationRoutesTestSuite) request(method, path string, params interface{}, token string) *http.Request {
+	var body io.Reader
+	if params != nil {
+		b, _ := json.Marshal(params)
+		body = bytes.NewBuffer(b)
+	}
+
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func (s *ImpersonationRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int) *http.Response {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode, "%s %s", req.Method, req.URL.Path)
+	return resp
+}
@@ -0,0 +1,169 @@
+package routes
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+)
+
+// listFilter compiles one query parameter into a condition with a single ?
+// placeholder for its parsed value.
+type listFilter struct {
+	condition string
+	parse     func(string) (interface{}, error)
+}
+
+// listSchema whitelists the query parameters a listing accepts. Sort keys are
+// column names and must be NOT NULL columns so that cursors can compare them.
+// Rows are always ordered by id last, which keeps pages stable.
+type listSchema[T any] struct {
+	filters     map[string]listFilter
+	sorts       map[string]func(T) interface{}
+	id          func(T) string
+	defaultSort string
+}
+
+// listCursor is the key of the last row on a page, along with the sort it
+// was taken from.
+type listCursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    string      `json:"id"`
+}
+
+// compile turns the request's query string into a db.ListQuery. Unknown
+// parameters, malformed values and cursors from another sort are reported
+// as validation errors.
+func (l *listSchema[T]) compile(c *fiber.Ctx, page utils.PageParams) (db.ListQuery, string, []*utils.ErrorResponse) {
+	var errors []*utils.ErrorResponse
+	query := db.ListQuery{Limit: page.PageLimit() + 1}
+
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		name := string(key)
+		switch name {
+		case "limit", "cursor", "sort":
+			return
+		}
+
+		filter, ok := l.filters[name]
+		if !ok {
+			errors = append(errors, &utils.ErrorResponse{FailedField: name, Tag: "unknown"})
+			return
+		}
+		if len(value) == 0 {
+			return
+		}
+
+		arg, err := filter.parse(string(value))
+		if err != nil {
+			errors = append(errors, &utils.ErrorResponse{FailedField: name, Tag: "format", Value: string(value)})
+			return
+		}
+		query.Conditions = append(query.Conditions, filter.condition)
+		query.Args = append(query.Args, arg)
+	})
+
+	sortBy := c.Query("sort", l.defaultSort)
+	column := strings.TrimPrefix(sortBy, "-")
+	desc := column != sortBy
+	if _, ok := l.sorts[column]; !ok {
+		errors = append(errors, &utils.ErrorResponse{FailedField: "sort", Tag: "oneof", Value: l.sortNames()})
+		return query, sortBy, errors
+	}
+
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+	query.OrderBy = []string{column + " " + direction}
+	if column != "id" {
+		query.OrderBy = append(query.OrderBy, "id "+direction)
+	}
+
+	if page.Cursor != "" {
+		cursor, err := decodeListCursor(page.Cursor)
+		if err != nil || cursor.Sort != sortBy {
+			errors = append(errors, &utils.ErrorResponse{FailedField: "cursor", Tag: "cursor"})
+		} else if column == "id" {
+			query.Conditions = append(query.Conditions, "id "+comparison+" ?")
+			query.Args = append(query.Args, cursor.ID)
+		} else {
+			query.Conditions = append(query.Conditions, fmt.Sprintf("(%s, id) %s (?, ?)", column, comparison))
+			query.Args = append(query.Args, cursor.Value, cursor.ID)
+		}
+	}
+
+	return query, sortBy, errors
+}
+
+// cursor returns the key function for utils.NewPage.
+func (l *listSchema[T]) cursor(sortBy string) func(T) string {
+	value := l.sorts[strings.TrimPrefix(sortBy, "-")]
+	return func(item T) string {
+		key, _ := json.Marshal(listCursor{Sort: sortBy, Value: value(item), ID: l.id(item)})
+		return string(key)
+	}
+}
+
+func (l *listSchema[T]) sortNames() string {
+	names := make([]string, 0, len(l.sorts))
+	for name := range l.sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func decodeListCursor(encoded string) (listCursor, error) {
+	var cursor listCursor
+	key, err := utils.DecodeCursor(encoded)
+	if err != nil {
+		return cursor, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewBufferString(key))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil || cursor.ID == "" {
+		return cursor, utils.ErrInvalidCursor
+	}
+	if number, ok := cursor.Value.(json.Number); ok {
+		if cursor.Value, err = number.Int64(); err != nil {
+			return cursor, utils.ErrInvalidCursor
+		}
+	}
+	return cursor, nil
+}
+
+func parseString(value string) (interface{}, error) {
+	return value, nil
+}
+
+// parseContains matches the value anywhere in the column with LIKE, so LIKE's
+// wildcards in the value are escaped. Conditions using it need ESCAPE '\'.
+func parseContains(value string) (interface{}, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	return "%" + escaped + "%", nil
+}
+
+func parseBool(value string) (interface{}, error) {
+	return strconv.ParseBool(value)
+}
+
+// parseTime accepts RFC 3339 timestamps, dates and unix seconds.
+func parseTime(value string) (interface{}, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.Unix(), nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
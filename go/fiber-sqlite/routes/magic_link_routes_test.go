@@ -0,0 +1,202 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type MagicLinkRoutesTestSuite struct {
+	suite.Suite
+	q      *db.Queries
+	conn   *sql.DB
+	app    *fiber.App
+	tokens utils.TokenMaker
+	mailer *recordingMailer
+}
+
+func (s *MagicLinkRoutesTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	s.mailer = &recordingMailer{}
+	idGen := utils.NewNanoIDGenerator(21)
+	s.tokens = utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, s.tokens, testHasher, s.mailer, Config{PublicURL: testPublicURL})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+}
+
+func (s *MagicLinkRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+}
+
+func (s *MagicLinkRoutesTestSuite) TestSignInWithMagicLink() {
+	link := s.requestLink("johndoe@example.com")
+	u, err := url.Parse(link)
+	s.Require().NoError(err)
+
+	// opening the link only asks for confirmation, so scanners that
+	// prefetch it do not use it up
+	for i := 0; i < 2; i++ {
+		resp, err := s.app.Test(httptest.NewRequest("GET", link, nil), -1)
+		s.Require().NoError(err)
+		s.Equal(fiber.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		s.NoError(err)
+		s.Contains(string(body), `method: "POST"`)
+		s.Contains(string(body), `token: "`+u.Query().Get("token")+`"`)
+	}
+
+	var tokens tokenResponse
+	s.checkReqStatus(s.confirmRequest(link), fiber.StatusOK, &tokens)
+
+	claims, err := s.tokens.VerifyToken(tokens.AccessToken)
+	s.NoError(err)
+	s.Equal("1", claims.Subject)
+
+	user, err := s.q.GetUserByID(context.Background(), "1")
+	s.NoError(err)
+	s.True(user.EmailVerifiedAt.Valid, "Using the link verifies the email")
+
+	// links are single use
+	s.checkReqStatus(s.confirmRequest(link), fiber.StatusUnauthorized, nil)
+}
+
+func (s *MagicLinkRoutesTestSuite) TestNewLinkReplacesOldOne() {
+	first := s.requestLink("janedoe@example.com")
+	second := s.requestLink("janedoe@example.com")
+
+	s.checkReqStatus(s.confirmRequest(first), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.confirmRequest(second), fiber.StatusOK, nil)
+}
+
+func (s *MagicLinkRoutesTestSuite) TestPasswordlessAccount() {
+	req := s.jsonRequest("/api/v1/users", db.CreateUserParams{Email: "passwordless@example.com"})
+	s.checkReqStatus(req, fiber.StatusCreated, nil)
+
+	// there is no password that unlocks the account
+	req = s.jsonRequest("/api/v1/auth/login", LoginParams{Email: "passwordless@example.com", Password: "password"})
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+
+	link := s.requestLink("passwordless@example.com")
+	s.checkReqStatus(s.confirmRequest(link), fiber.StatusOK, nil)
+}
+
+func (s *MagicLinkRoutesTestSuite) TestUnknownEmail() {
+	before := len(s.mailer.messages)
+
+	req := s.jsonRequest("/api/v1/auth/magic", MagicLinkParams{Email: "nobody@example.com"})
+	s.checkReqStatus(req, fiber.StatusAccepted, nil)
+
+	s.Len(s.mailer.messages, before, "No email is sent for unknown addresses")
+}
+
+func (s *MagicLinkRoutesTestSuite) TestCallbackWithInvalidToken() {
+	s.checkReqStatus(s.confirmRequest("/api/v1/auth/magic/callback?token=nope"), fiber.StatusUnauthorized, nil)
+	s.checkReqStatus(s.confirmRequest("/api/v1/auth/magic/callback"), fiber.StatusBadRequest, nil)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/auth/magic/callback", nil), fiber.StatusBadRequest, nil)
+}
+
+func (s *MagicLinkRoutesTestSuite) TestLinkIgnoresHostHeader() {
+	req := s.jsonRequest("/api/v1/auth/magic", MagicLinkParams{Email: "johndoe@example.com"})
+	req.Host = "evil.example"
+	req.Header.Set("X-Forwarded-Host", "evil.example")
+	s.checkReqStatus(req, fiber.StatusAccepted, nil)
+
+	link := s.lastLink()
+	s.Equal(testPublicURL, link.Scheme+"://"+link.Host)
+	s.Equal("/api/v1/auth/magic/callback", link.Path)
+}
+
+func TestMagicLinkRoutes(t *testing.T) {
+	suite.Run(t, new(MagicLinkRoutesTestSuite))
+}
+
+// requestLink asks for a magic link and returns the path and query of the
+// link from the email.
+func (s *MagicLinkRoutesTestSuite) requestLink(email string) string {
+	s.T().Helper()
+	req := s.jsonRequest("/api/v1/auth/magic", MagicLinkParams{Email: email})
+	s.checkReqStatus(req, fiber.StatusAccepted, nil)
+
+	msg, ok := s.mailer.last()
+	s.True(ok)
+	s.Equal(email, msg.To)
+
+	return s.lastLink().RequestURI()
+}
+
+// lastLink returns the link from the last email, which is the last line of
+// the message body.
+func (s *MagicLinkRoutesTestSuite) lastLink() *url.URL {
+	s.T().Helper()
+	msg, ok := s.mailer.last()
+	s.Require().True(ok)
+
+	lines := strings.Split(strings.TrimSpace(msg.Text), "\n")
+	link, err := url.Parse(lines[len(lines)-1])
+	s.NoError(err)
+	return link
+}
+
+// confirmRequest sends the POST the confirm page makes for the token in link.
+func (s *MagicLinkRoutesTestSuite) confirmRequest(link string) *http.Request {
+	s.T().Helper()
+	u, err := url.Parse(link)
+	s.Require().NoError(err)
+
+	return s.jsonRequest(u.Path, MagicLinkCallbackParams{Token: u.Query().Get("token")})
+}
+
+func (s *MagicLinkRoutesTestSuite) jsonRequest(path string, params interface{}) *http.Request {
+	body, _ := json.Marshal(params)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *MagicLinkRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testDB is the database the suites share. Builds with search keep their
+// own, since a build without FTS5 cannot write to users once the search
+// index exists.
+var testDB = "../db/test.db"
+
+// TestMain brings testDB up to date before the suites open it.
+func TestMain(m *testing.M) {
+	if db.SearchEnabled {
+		testDB = "../db/test_search.db"
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1&_txlock=immediate")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := db.CheckSearch(conn); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := db.MigrateUp(context.Background(), conn); err != nil {
+		log.Fatal(err)
+	}
+	conn.Close()
+
+	os.Exit(m.Run())
+}
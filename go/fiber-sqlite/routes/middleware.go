@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	userIDKey        = "userID"
+	userRoleKey      = "userRole"
+	sessionIDKey     = "sessionID"
+	impersonationKey = "impersonation"
+
+	// headerImpersonatedBy marks responses to requests made under an
+	// impersonation with the admin's user ID.
+	headerImpersonatedBy = "X-Impersonated-By"
+)
+
+// requireAuth rejects requests without a valid bearer access token or API key
+// and stores the caller's user ID and role on the context for the handlers
+// that follow.
+func (s *Service) requireAuth(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderAuthorization)
+	if !strings.HasPrefix(header, "Bearer ") {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Missing access token",
+		})
+	}
+
+	token := strings.TrimPrefix(header, "Bearer ")
+	if prefix, secret, ok := utils.SplitAPIKey(token); ok {
+		return s.authenticateAPIKey(c, prefix, secret)
+	}
+
+	claims, err := s.tokens.VerifyToken(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid access token",
+		})
+	}
+
+	if claims.ImpersonationID != "" {
+		return s.authenticateImpersonation(c, claims)
+	}
+
+	// Access tokens are only as good as the session they were issued to,
+	// so logging out or revoking the session takes effect at once.
+	active, err := s.queries.IsSessionActive(c.Context(), claims.SessionID, claims.Subject)
+	if err != nil {
+		return err
+	}
+
+	if !active {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Session has ended",
+		})
+	}
+
+	c.Locals(userIDKey, claims.Subject)
+	c.Locals(userRoleKey, claims.Role)
+	c.Locals(sessionIDKey, claims.SessionID)
+	return c.Next()
+}
+
+func (s *Service) authenticateAPIKey(c *fiber.Ctx, prefix string, secret string) error {
+	key, err := s.queries.GetAPIKeyByPrefix(c.Context(), prefix)
+	if err != nil {
+		return err
+	}
+
+	secretHash := utils.HashOpaqueToken(secret)
+	if key.ID == "" || subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(secretHash)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid API key",
+		})
+	}
+
+	user, err := s.queries.GetUserByID(c.Context(), key.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.queries.TouchAPIKey(c.Context(), key.ID); err != nil {
+		return err
+	}
+
+	c.Locals(userIDKey, user.ID)
+	c.Locals(userRoleKey, user.Role)
+	return c.Next()
+}
+
+// authenticateImpersonation accepts an impersonation token for as long as the
+// impersonation has not been stopped or expired and the admin who started it
+// is still an admin. Every request made with it is recorded in the audit
+// trail, and so is the end of an impersonation noticed here.
+func (s *Service) authenticateImpersonation(c *fiber.Ctx, claims *utils.TokenClaims) error {
+	impersonation, err := s.queries.GetImpersonation(c.Context(), claims.ImpersonationID)
+	if err != nil {
+		return err
+	}
+
+	if impersonation.ID == "" || impersonation.UserID != claims.Subject || impersonation.EndedAt.Valid {
+		return impersonationEnded(c)
+	}
+
+	if impersonation.ExpiresAt <= time.Now().Unix() {
+		if err := s.endImpersonation(c, impersonation, "expired"); err != nil {
+			return err
+		}
+		return impersonationEnded(c)
+	}
+
+	admin, err := s.queries.GetUserByID(c.Context(), impersonation.AdminID)
+	if err != nil {
+		return err
+	}
+
+	if admin.Role != db.RoleAdmin {
+		err := s.queries.RunInTx(c.Context(), func(q *db.Queries) error {
+			return s.endAdminImpersonations(c, q, impersonation.AdminID, "admin role revoked")
+		})
+		if err != nil {
+			return err
+		}
+		return impersonationEnded(c)
+	}
+
+	if err := s.recordImpersonationEvent(c, s.queries, impersonation, db.AuditImpersonationRequest, c.Method()+" "+c.Path()); err != nil {
+		return err
+	}
+
+	c.Set(headerImpersonatedBy, impersonation.AdminID)
+	c.Locals(userIDKey, claims.Subject)
+	c.Locals(userRoleKey, claims.Role)
+	c.Locals(impersonationKey, impersonation)
+	return c.Next()
+}
+
+func impersonationEnded(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+		"message": "Impersonation has ended",
+	})
+}
+
+// forbidImpersonation blocks sensitive actions for admins acting as another
+// user. It must be attached after requireAuth.
+func forbidImpersonation(c *fiber.Ctx) error {
+	if isImpersonating(c) {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "Not allowed while impersonating a user",
+		})
+	}
+	return c.Next()
+}
+
+// requireRole only lets callers with one of the given roles through. It must
+// be attached after requireAuth.
+func requireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role := currentUserRole(c)
+		for _, allowed := range roles {
+			if role == allowed {
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "You are not allowed to do this",
+		})
+	}
+}
+
+// currentUserID returns the ID of the authenticated caller, or an empty
+// string when the route is not behind requireAuth.
+func currentUserID(c *fiber.Ctx) string {
+	id, _ := c.Locals(userIDKey).(string)
+	return id
+}
+
+// currentSessionID returns the session the caller's access token was issued
+// to, or an empty string for API keys and impersonation tokens.
+func currentSessionID(c *fiber.Ctx) string {
+	id, _ := c.Locals(sessionIDKey).(string)
+	return id
+}
+
+func currentUserRole(c *fiber.Ctx) string {
+	role, _ := c.Locals(userRoleKey).(string)
+	return role
+}
+
+// currentImpersonation returns the impersonation the request is made under,
+// or an empty Impersonation when the caller is acting as themselves.
+func currentImpersonation(c *fiber.Ctx) db.Impersonation {
+	impersonation, _ := c.Locals(impersonationKey).(db.Impersonation)
+	return impersonation
+}
+
+func isImpersonating(c *fiber.Ctx) bool {
+	return currentImpersonation(c).ID != ""
+}
+
+// canManageUser reports whether the caller may act on the user with the given
+// ID. Admins can manage everyone, members only themselves.
+func canManageUser(c *fiber.Ctx, id string) bool {
+	return currentUserRole(c) == db.RoleAdmin || currentUserID(c) == id
+}
@@ -0,0 +1,158 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/mail"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const resetTokenDuration = time.Hour
+
+type ForgotPasswordParams struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordParams struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+func (s *Service) setupPasswordRoutes(router fiber.Router) {
+	router.Post("/forgot", s.forgotPasswordHandler)
+	router.Post("/reset", s.resetPasswordHandler)
+}
+
+func (s *Service) forgotPasswordHandler(c *fiber.Ctx) error {
+	forgotParams := ForgotPasswordParams{}
+
+	if err := c.BodyParser(&forgotParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(forgotParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	user, err := s.queries.GetUserByEmail(c.Context(), forgotParams.Email)
+	if err != nil {
+		return err
+	}
+
+	// Respond the same way whether or not the email is registered so the
+	// endpoint cannot be used to discover accounts.
+	if user.Email == "" {
+		return c.Status(fiber.StatusAccepted).Send(nil)
+	}
+
+	if err := s.queries.DeleteUserTokens(c.Context(), user.ID, db.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.queries.CreateUserToken(c.Context(), db.CreateUserTokenParams{
+		ID:        s.idGen.Generate(),
+		UserID:    user.ID,
+		Purpose:   db.TokenPurposePasswordReset,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(resetTokenDuration).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := mail.NewMessage(user.Email, "Reset your password", "password_reset", fiber.Map{
+		"Token":     token,
+		"ExpiresIn": resetTokenDuration,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.mailer.Send(c.Context(), msg); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).Send(nil)
+}
+
+func (s *Service) resetPasswordHandler(c *fiber.Ctx) error {
+	resetParams := ResetPasswordParams{}
+
+	if err := c.BodyParser(&resetParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(resetParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	tokenHash := utils.HashOpaqueToken(resetParams.Token)
+
+	// Check the password against the user's details before spending the
+	// token, so a rejected password does not need a new reset email.
+	token, err := s.queries.GetUserToken(c.Context(), db.TokenPurposePasswordReset, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	if token.ID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Invalid or expired reset token",
+		})
+	}
+
+	user, err := s.queries.GetUserByID(c.Context(), token.UserID)
+	if err != nil {
+		return err
+	}
+
+	errors = utils.ValidatePassword("ResetPasswordParams.Password", resetParams.Password, user.Email, user.Name.String)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	token, err = s.queries.ConsumeUserToken(c.Context(), db.TokenPurposePasswordReset, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	if token.ID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Invalid or expired reset token",
+		})
+	}
+
+	if err := s.setPassword(c, token.UserID, resetParams.Password); err != nil {
+		return err
+	}
+
+	// Sign the user out everywhere so a stolen session does not survive the reset.
+	if err := s.queries.RevokeUserRefreshTokens(c.Context(), token.UserID); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+func (s *Service) setPassword(c *fiber.Ctx, userID string, plain string) error {
+	hash, err := s.hasher.Hash(plain)
+	if err != nil {
+		return err
+	}
+
+	var password db.NullString
+	password.String = hash
+	password.Valid = true
+
+	_, err = s.queries.UpdateUser(c.Context(), db.UpdateUserParams{Password: password}, userID)
+	return err
+}
@@ -2,24 +2,58 @@ package routes
 
 import (
 	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/mail"
+	"github.com/ashwins93/fiber-sql/sso"
 	"github.com/ashwins93/fiber-sql/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
+type Config struct {
+	// RequireVerifiedEmail blocks login until the user has verified their email address.
+	RequireVerifiedEmail bool
+	// SSOProviders are the OpenID Connect providers users can sign in with.
+	SSOProviders []*sso.Provider
+	// PublicURL is the scheme and host that links in emails point at. Links are
+	// never built from the request's Host header, which the client controls.
+	PublicURL string
+}
+
 type Service struct {
 	queries *db.Queries
 	app     *fiber.App
 	idGen   utils.IDGenerator
+	tokens  utils.TokenMaker
+	hasher  utils.PasswordHasher
+	mailer  mail.Mailer
+	config  Config
 }
 
-func NewService(queries *db.Queries, app *fiber.App, idGen utils.IDGenerator) *Service {
-	return &Service{queries, app, idGen}
+func NewService(queries *db.Queries, app *fiber.App, idGen utils.IDGenerator, tokens utils.TokenMaker, hasher utils.PasswordHasher, mailer mail.Mailer, config Config) *Service {
+	return &Service{queries, app, idGen, tokens, hasher, mailer, config}
 }
 
 func (s *Service) SetupV1Routes() {
 	v1Routes := s.app.Group("/api/v1")
 
+	authRouter := v1Routes.Group("/auth")
+	passwordRouter := authRouter.Group("/password")
+	twoFactorRouter := authRouter.Group("/2fa")
+	ssoRouter := authRouter.Group("/sso")
+	magicLinkRouter := authRouter.Group("/magic")
 	userRouter := v1Routes.Group("/users")
+	apiKeyRouter := userRouter.Group("/:id/keys")
+	impersonationRouter := userRouter.Group("/:id/impersonation")
+	meRouter := v1Routes.Group("/me")
+	sessionRouter := meRouter.Group("/sessions")
 
+	s.setupAuthRoutes(authRouter)
+	s.setupPasswordRoutes(passwordRouter)
+	s.setupVerificationRoutes(authRouter)
+	s.setupTwoFactorRoutes(twoFactorRouter)
+	s.setupSSORoutes(ssoRouter)
+	s.setupMagicLinkRoutes(magicLinkRouter)
 	s.setupUserRoutes(userRouter)
+	s.setupAPIKeyRoutes(apiKeyRouter)
+	s.setupImpersonationRoutes(impersonationRouter)
+	s.setupSessionRoutes(sessionRouter)
 }
@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+type sessionResponse struct {
+	db.Session
+	Current bool `json:"current"`
+}
+
+func (s *Service) setupSessionRoutes(router fiber.Router) {
+	router.Get("", s.requireAuth, s.findSessionsHandler)
+	router.Delete("", s.requireAuth, forbidImpersonation, s.deleteSessionsHandler)
+	router.Delete("/:id", s.requireAuth, forbidImpersonation, s.deleteSessionHandler)
+}
+
+func (s *Service) findSessionsHandler(c *fiber.Ctx) error {
+	sessions, err := s.queries.GetUserSessions(c.Context(), currentUserID(c))
+	if err != nil {
+		return err
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{session, session.ID == currentSessionID(c)})
+	}
+
+	return c.JSON(response)
+}
+
+func (s *Service) deleteSessionHandler(c *fiber.Ctx) error {
+	revoked, err := s.queries.RevokeSession(c.Context(), c.Params("id"), currentUserID(c))
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"message": "Session not found",
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
+
+// deleteSessionsHandler logs the user out everywhere, including here.
+func (s *Service) deleteSessionsHandler(c *fiber.Ctx) error {
+	if err := s.queries.RevokeUserRefreshTokens(c.Context(), currentUserID(c)); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
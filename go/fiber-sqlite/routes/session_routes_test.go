@@ -0,0 +1,224 @@
+package routes
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type SessionRoutesTestSuite struct {
+	suite.Suite
+	q    *db.Queries
+	conn *sql.DB
+	app  *fiber.App
+}
+
+func (s *SessionRoutesTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+		ProxyHeader: fiber.HeaderXForwardedFor,
+	})
+	idGen := utils.NewNanoIDGenerator(21)
+	tokens := utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, tokens, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+}
+
+func (s *SessionRoutesTestSuite) SetupTest() {
+	s.conn.Exec("DELETE FROM sessions")
+	s.conn.Exec("DELETE FROM refresh_tokens")
+}
+
+func (s *SessionRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+}
+
+func (s *SessionRoutesTestSuite) TestListSessions() {
+	laptop := s.login("janedoe@example.com", "Laptop", "10.0.1.1")
+	s.login("janedoe@example.com", "Phone", "10.0.1.2")
+	s.login("johndoe@example.com", "Desktop", "10.0.1.3")
+
+	sessions := s.sessions(laptop.AccessToken)
+	s.Len(sessions, 2, "Only the caller's sessions are listed")
+
+	devices := map[string]string{}
+	for _, session := range sessions {
+		s.Equal("2", session.UserID)
+		s.NotEmpty(session.CreatedAt)
+		s.NotEmpty(session.LastSeenAt)
+		devices[session.UserAgent] = session.IP
+	}
+	s.Equal(map[string]string{"Laptop": "10.0.1.1", "Phone": "10.0.1.2"}, devices)
+
+	for _, session := range sessions {
+		s.Equal(session.UserAgent == "Laptop", session.Current, "Only the caller's session is current")
+	}
+}
+
+func (s *SessionRoutesTestSuite) TestRefreshUpdatesSession() {
+	tokens := s.login("janedoe@example.com", "Laptop", "10.0.1.1")
+
+	req := s.jsonRequest("POST", "/api/v1/auth/refresh", RefreshParams{RefreshToken: tokens.RefreshToken}, "")
+	req.Header.Set(fiber.HeaderUserAgent, "Laptop (updated)")
+	req.Header.Set(fiber.HeaderXForwardedFor, "10.0.1.9")
+	s.checkReqStatus(req, fiber.StatusOK, &tokens)
+
+	sessions := s.sessions(tokens.AccessToken)
+	s.Len(sessions, 1, "Refreshing keeps the same session")
+	s.Equal("Laptop (updated)", sessions[0].UserAgent)
+	s.Equal("10.0.1.9", sessions[0].IP)
+}
+
+func (s *SessionRoutesTestSuite) TestRevokeSession() {
+	laptop := s.login("janedoe@example.com", "Laptop", "10.0.1.1")
+	phone := s.login("janedoe@example.com", "Phone", "10.0.1.2")
+
+	var phoneSession sessionResponse
+	for _, session := range s.sessions(laptop.AccessToken) {
+		if session.UserAgent == "Phone" {
+			phoneSession = session
+		}
+	}
+	s.NotEmpty(phoneSession.ID)
+
+	req := s.jsonRequest("DELETE", "/api/v1/me/sessions/"+phoneSession.ID, nil, laptop.AccessToken)
+	s.checkReqStatus(req, fiber.StatusNoContent, nil)
+
+	req = s.jsonRequest("POST", "/api/v1/auth/refresh", RefreshParams{RefreshToken: phone.RefreshToken}, "")
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+
+	// the revoked session's access token stops working before it expires
+	req = s.jsonRequest("GET", "/api/v1/me/sessions", nil, phone.AccessToken)
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+
+	s.Len(s.sessions(laptop.AccessToken), 1)
+
+	req = s.jsonRequest("DELETE", "/api/v1/me/sessions/"+phoneSession.ID, nil, laptop.AccessToken)
+	s.checkReqStatus(req, fiber.StatusNotFound, nil)
+}
+
+func (s *SessionRoutesTestSuite) TestCannotRevokeOtherUsersSession() {
+	jane := s.login("janedoe@example.com", "Laptop", "10.0.1.1")
+	john := s.login("johndoe@example.com", "Desktop", "10.0.1.3")
+
+	sessions := s.sessions(jane.AccessToken)
+	s.Len(sessions, 1)
+
+	req := s.jsonRequest("DELETE", "/api/v1/me/sessions/"+sessions[0].ID, nil, john.AccessToken)
+	s.checkReqStatus(req, fiber.StatusNotFound, nil)
+
+	s.Len(s.sessions(jane.AccessToken), 1)
+}
+
+func (s *SessionRoutesTestSuite) TestLogoutEverywhere() {
+	laptop := s.login("janedoe@example.com", "Laptop", "10.0.1.1")
+	phone := s.login("janedoe@example.com", "Phone", "10.0.1.2")
+	desktop := s.login("johndoe@example.com", "Desktop", "10.0.1.3")
+
+	req := s.jsonRequest("DELETE", "/api/v1/me/sessions", nil, laptop.AccessToken)
+	s.checkReqStatus(req, fiber.StatusNoContent, nil)
+
+	for _, tokens := range []tokenResponse{laptop, phone} {
+		req = s.jsonRequest("GET", "/api/v1/me/sessions", nil, tokens.AccessToken)
+		s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+
+		req = s.jsonRequest("POST", "/api/v1/auth/refresh", RefreshParams{RefreshToken: tokens.RefreshToken}, "")
+		s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+	}
+
+	s.Len(s.sessions(desktop.AccessToken), 1, "Other users stay logged in")
+}
+
+func (s *SessionRoutesTestSuite) TestLogoutEndsAccessToken() {
+	tokens := s.login("janedoe@example.com", "Laptop", "10.0.1.1")
+
+	req := s.jsonRequest("POST", "/api/v1/auth/logout", RefreshParams{RefreshToken: tokens.RefreshToken}, "")
+	s.checkReqStatus(req, fiber.StatusNoContent, nil)
+
+	req = s.jsonRequest("GET", "/api/v1/me/sessions", nil, tokens.AccessToken)
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *SessionRoutesTestSuite) TestSessionsWithoutToken() {
+	req := s.jsonRequest("GET", "/api/v1/me/sessions", nil, "")
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func TestSessionRoutes(t *testing.T) {
+	suite.Run(t, new(SessionRoutesTestSuite))
+}
+
+func (s *SessionRoutesTestSuite) login(email, userAgent, ip string) tokenResponse {
+	s.T().Helper()
+	req := s.jsonRequest("POST", "/api/v1/auth/login", LoginParams{Email: email, Password: "password"}, "")
+	req.Header.Set(fiber.HeaderUserAgent, userAgent)
+	req.Header.Set(fiber.HeaderXForwardedFor, ip)
+
+	var tokens tokenResponse
+	s.checkReqStatus(req, fiber.StatusOK, &tokens)
+	return tokens
+}
+
+func (s *SessionRoutesTestSuite) sessions(accessToken string) []sessionResponse {
+	s.T().Helper()
+	var sessions []sessionResponse
+	s.checkReqStatus(s.jsonRequest("GET", "/api/v1/me/sessions", nil, accessToken), fiber.StatusOK, &sessions)
+	return sessions
+}
+
+func (s *SessionRoutesTestSuite) jsonRequest(method, path string, params interface{}, accessToken string) *http.Request {
+	var body io.Reader
+	if params != nil {
+		b, _ := json.Marshal(params)
+		body = bytes.NewBuffer(b)
+	}
+
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return req
+}
+
+func (s *SessionRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
@@ -0,0 +1,186 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/sso"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	ssoCookie         = "sso_auth"
+	ssoCookiePath     = "/api/v1/auth/sso"
+	ssoCookieDuration = 10 * time.Minute
+)
+
+func (s *Service) setupSSORoutes(router fiber.Router) {
+	router.Get("/:provider", s.ssoRedirectHandler)
+	router.Get("/:provider/callback", s.ssoCallbackHandler)
+}
+
+func (s *Service) ssoProvider(name string) *sso.Provider {
+	for _, p := range s.config.SSOProviders {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Service) ssoRedirectHandler(c *fiber.Ctx) error {
+	provider := s.ssoProvider(c.Params("provider"))
+	if provider == nil {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"message": "Unknown sign-in provider",
+		})
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := sso.RandomString()
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	// The browser carries the flow's secrets back to the callback, which
+	// keeps the server stateless and ties the callback to this browser.
+	c.Cookie(&fiber.Cookie{
+		Name:     ssoCookie,
+		Value:    strings.Join([]string{provider.Name(), state, nonce, verifier}, "."),
+		Path:     ssoCookiePath,
+		Expires:  time.Now().Add(ssoCookieDuration),
+		Secure:   c.Secure(),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return c.Redirect(provider.AuthCodeURL(state, nonce, verifier), fiber.StatusFound)
+}
+
+func (s *Service) ssoCallbackHandler(c *fiber.Ctx) error {
+	provider := s.ssoProvider(c.Params("provider"))
+	if provider == nil {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"message": "Unknown sign-in provider",
+		})
+	}
+
+	parts := strings.Split(c.Cookies(ssoCookie), ".")
+	c.Cookie(&fiber.Cookie{
+		Name:    ssoCookie,
+		Path:    ssoCookiePath,
+		Expires: time.Unix(0, 0),
+	})
+
+	if len(parts) != 4 || parts[0] != provider.Name() || c.Query("state") == "" ||
+		subtle.ConstantTimeCompare([]byte(parts[1]), []byte(c.Query("state"))) != 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Invalid or expired sign-in request",
+		})
+	}
+
+	if c.Query("error") != "" || c.Query("code") == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Sign-in was cancelled or denied by the provider",
+		})
+	}
+
+	identity, err := provider.Exchange(c.Context(), c.Query("code"), parts[2], parts[3])
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Could not verify sign-in with the provider",
+		})
+	}
+
+	user, err := s.ssoUser(c, provider.Name(), identity)
+	if err != nil {
+		return err
+	}
+
+	return s.completeLogin(c, user)
+}
+
+// ssoUser finds the user linked to the identity. Unknown identities are
+// linked to the account with the same email when both the provider and the
+// account have verified the address, and otherwise get a new account. The
+// lookup, account creation and linking run in one transaction, so concurrent
+// callbacks for the same identity cannot create two accounts.
+func (s *Service) ssoUser(c *fiber.Ctx, provider string, identity sso.Identity) (db.User, error) {
+	var user db.User
+	err := s.queries.RunInTx(c.Context(), func(q *db.Queries) error {
+		linked, err := q.GetUserIdentity(c.Context(), provider, identity.Subject)
+		if err != nil {
+			return err
+		}
+
+		if linked.ID != "" {
+			user, err = q.GetUserByID(c.Context(), linked.UserID)
+			return err
+		}
+
+		if identity.Email == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "The provider did not share an email address")
+		}
+
+		// Accounts are keyed by email, so an address the provider has not
+		// verified could belong to anyone.
+		if !identity.EmailVerified {
+			return fiber.NewError(fiber.StatusForbidden, "The provider has not verified your email address")
+		}
+
+		user, err = q.GetUserByEmail(c.Context(), identity.Email)
+		if err != nil {
+			return err
+		}
+
+		// An account that was never verified may have been registered by
+		// someone else ahead of the real owner, whose password would keep
+		// working after linking.
+		if user.Email != "" && !user.EmailVerifiedAt.Valid {
+			return fiber.NewError(fiber.StatusConflict, "An account with this email address already exists")
+		}
+
+		if user.Email == "" {
+			var name db.NullString
+			name.String = identity.Name
+			name.Valid = identity.Name != ""
+
+			// An empty password hash never matches, so the account can only
+			// sign in through the provider until a password is set.
+			user, err = q.CreateUser(c.Context(), db.CreateUserParams{
+				ID:    s.idGen.Generate(),
+				Name:  name,
+				Email: identity.Email,
+			})
+			if err != nil {
+				return err
+			}
+
+			user, err = q.MarkEmailVerified(c.Context(), user.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		var email db.NullString
+		email.String = identity.Email
+		email.Valid = true
+
+		_, err = q.CreateUserIdentity(c.Context(), db.CreateUserIdentityParams{
+			ID:       s.idGen.Generate(),
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  identity.Subject,
+			Email:    email,
+		})
+		return err
+	})
+
+	return user, err
+}
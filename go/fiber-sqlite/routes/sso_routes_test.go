@@ -0,0 +1,230 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/sso"
+	"github.com/ashwins93/fiber-sql/sso/ssotest"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type SSORoutesTestSuite struct {
+	suite.Suite
+	q        *db.Queries
+	conn     *sql.DB
+	app      *fiber.App
+	tokens   utils.TokenMaker
+	provider *ssotest.Server
+	browser  *http.Client
+}
+
+func (s *SSORoutesTestSuite) SetupSuite() {
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	s.provider = ssotest.NewServer()
+	s.browser = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	mock, err := sso.NewProvider(context.Background(), s.provider.Config("mock", "http://example.com/api/v1/auth/sso/mock/callback"))
+	if err != nil {
+		panic(err)
+	}
+
+	idGen := utils.NewNanoIDGenerator(21)
+	s.tokens = utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, s.tokens, testHasher, &recordingMailer{}, Config{SSOProviders: []*sso.Provider{mock}})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+	if _, err := s.q.MarkEmailVerified(context.Background(), "2"); err != nil {
+		panic(err)
+	}
+}
+
+func (s *SSORoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+	s.provider.Close()
+}
+
+func (s *SSORoutesTestSuite) TestSignUpThroughProvider() {
+	s.provider.SetUser(ssotest.User{Subject: "new-1", Email: "newcomer@example.com", EmailVerified: true, Name: "Newcomer"})
+
+	var tokens tokenResponse
+	s.checkResponse(s.signIn(nil), fiber.StatusOK, &tokens)
+
+	claims, err := s.tokens.VerifyToken(tokens.AccessToken)
+	s.NoError(err)
+
+	user, err := s.q.GetUserByID(context.Background(), claims.Subject)
+	s.NoError(err)
+	s.Equal("newcomer@example.com", user.Email)
+	s.Equal("Newcomer", user.Name.String)
+	s.Equal(db.RoleMember, user.Role)
+	s.True(user.EmailVerifiedAt.Valid)
+
+	// signing in again with a changed email keeps the same account
+	s.provider.SetUser(ssotest.User{Subject: "new-1", Email: "renamed@example.com", EmailVerified: true})
+
+	var again tokenResponse
+	s.checkResponse(s.signIn(nil), fiber.StatusOK, &again)
+
+	claims, err = s.tokens.VerifyToken(again.AccessToken)
+	s.NoError(err)
+	s.Equal(user.ID, claims.Subject)
+}
+
+func (s *SSORoutesTestSuite) TestLinkAccountWithVerifiedEmail() {
+	s.provider.SetUser(ssotest.User{Subject: "jane-1", Email: "janedoe@example.com", EmailVerified: true})
+
+	var tokens tokenResponse
+	s.checkResponse(s.signIn(nil), fiber.StatusOK, &tokens)
+
+	claims, err := s.tokens.VerifyToken(tokens.AccessToken)
+	s.NoError(err)
+	s.Equal("2", claims.Subject)
+
+	identity, err := s.q.GetUserIdentity(context.Background(), "mock", "jane-1")
+	s.NoError(err)
+	s.Equal("2", identity.UserID)
+}
+
+func (s *SSORoutesTestSuite) TestUnverifiedEmailDoesNotLinkAccount() {
+	s.provider.SetUser(ssotest.User{Subject: "john-1", Email: "johndoe@example.com", EmailVerified: false})
+
+	s.checkResponse(s.signIn(nil), fiber.StatusForbidden, nil)
+
+	identity, err := s.q.GetUserIdentity(context.Background(), "mock", "john-1")
+	s.NoError(err)
+	s.Equal("", identity.ID)
+}
+
+func (s *SSORoutesTestSuite) TestUnverifiedEmailDoesNotCreateAccount() {
+	s.provider.SetUser(ssotest.User{Subject: "claim-1", Email: "unclaimed@example.com", EmailVerified: false})
+
+	s.checkResponse(s.signIn(nil), fiber.StatusForbidden, nil)
+
+	user, err := s.q.GetUserByEmail(context.Background(), "unclaimed@example.com")
+	s.NoError(err)
+	s.Equal("", user.ID)
+}
+
+func (s *SSORoutesTestSuite) TestUnverifiedAccountIsNotLinked() {
+	s.provider.SetUser(ssotest.User{Subject: "ashwin-1", Email: "ashwin@example.com", EmailVerified: true})
+
+	s.checkResponse(s.signIn(nil), fiber.StatusConflict, nil)
+
+	identity, err := s.q.GetUserIdentity(context.Background(), "mock", "ashwin-1")
+	s.NoError(err)
+	s.Equal("", identity.ID)
+
+	user, err := s.q.GetUserByID(context.Background(), "3")
+	s.NoError(err)
+	s.False(user.EmailVerifiedAt.Valid)
+}
+
+func (s *SSORoutesTestSuite) TestCallbackRequiresMatchingState() {
+	s.provider.SetUser(ssotest.User{Subject: "state-1", Email: "state@example.com", EmailVerified: true})
+
+	s.checkResponse(s.signIn(func(cookie *http.Cookie) {
+		cookie.Value = ""
+	}), fiber.StatusBadRequest, nil)
+}
+
+func (s *SSORoutesTestSuite) TestCallbackRequiresCodeVerifier() {
+	s.provider.SetUser(ssotest.User{Subject: "pkce-1", Email: "pkce@example.com", EmailVerified: true})
+
+	s.checkResponse(s.signIn(func(cookie *http.Cookie) {
+		parts := strings.Split(cookie.Value, ".")
+		parts[3] = "wrong-verifier"
+		cookie.Value = strings.Join(parts, ".")
+	}), fiber.StatusUnauthorized, nil)
+}
+
+func (s *SSORoutesTestSuite) TestUnknownProvider() {
+	resp, err := s.app.Test(httptest.NewRequest("GET", "/api/v1/auth/sso/unknown", nil), -1)
+	s.NoError(err)
+	s.Equal(fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestSSORoutes(t *testing.T) {
+	suite.Run(t, new(SSORoutesTestSuite))
+}
+
+// signIn walks through the redirects a browser would follow and returns the
+// callback response. tamper can modify the flow cookie before the callback.
+func (s *SSORoutesTestSuite) signIn(tamper func(*http.Cookie)) *http.Response {
+	s.T().Helper()
+	resp, err := s.app.Test(httptest.NewRequest("GET", "/api/v1/auth/sso/mock", nil), -1)
+	s.NoError(err)
+	s.Equal(fiber.StatusFound, resp.StatusCode)
+
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == ssoCookie {
+			cookie = c
+		}
+	}
+	s.NotNil(cookie)
+
+	authorize, err := s.browser.Get(resp.Header.Get("Location"))
+	s.NoError(err)
+	authorize.Body.Close()
+	s.Equal(http.StatusFound, authorize.StatusCode)
+
+	callback, err := url.Parse(authorize.Header.Get("Location"))
+	s.NoError(err)
+
+	if tamper != nil {
+		tamper(cookie)
+	}
+
+	req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+	req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+
+	resp, err = s.app.Test(req, -1)
+	s.NoError(err)
+	return resp
+}
+
+func (s *SSORoutesTestSuite) checkResponse(resp *http.Response, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
@@ -2,11 +2,28 @@ package routes
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/mail"
+	"github.com/ashwins93/fiber-sql/utils"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const testJWTSecret = "test-secret"
+
+const testPublicURL = "https://app.example.com"
+
+// cheap parameters keep the tests fast
+var testHasher = utils.NewArgon2idHasher(utils.Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+})
+
 func seedDataIntoDb(q *db.Queries) error {
 	userList := []struct {
 		id       string
@@ -28,7 +45,7 @@ func seedDataIntoDb(q *db.Queries) error {
 			ID:       user.id,
 			Name:     name,
 			Email:    user.email,
-			Password: user.email,
+			Password: hashPassword(user.password),
 		})
 
 		if err != nil {
@@ -38,7 +55,71 @@ func seedDataIntoDb(q *db.Queries) error {
 	return nil
 }
 
+// createSessionToken starts a session for the user the way a login does and
+// returns an access token issued to it.
+func createSessionToken(q *db.Queries, tokens utils.TokenMaker, userID string, role string) (string, error) {
+	idGen := utils.NewNanoIDGenerator(21)
+
+	session, err := q.CreateSession(context.Background(), db.CreateSessionParams{
+		ID:     idGen.Generate(),
+		UserID: userID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.CreateRefreshToken(context.Background(), db.CreateRefreshTokenParams{
+		ID:        idGen.Generate(),
+		UserID:    userID,
+		FamilyID:  session.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tokens.CreateToken(userID, role, session.ID, time.Minute)
+}
+
 func hashPassword(password string) string {
 	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(hash)
 }
+
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+	// err, when set, is returned instead of recording the message.
+	err error
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *recordingMailer) fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+func (m *recordingMailer) last() (mail.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.messages) == 0 {
+		return mail.Message{}, false
+	}
+	return m.messages[len(m.messages)-1], true
+}
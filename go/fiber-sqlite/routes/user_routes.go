@@ -1,18 +1,24 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/ashwins93/fiber-sql/db"
 	"github.com/ashwins93/fiber-sql/utils"
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
+type SearchUsersParams struct {
+	Q string `query:"q" validate:"required,max=100"`
+}
+
 func (s *Service) setupUserRoutes(router fiber.Router) {
 	router.Post("", s.createUserHandler)
-	router.Get("", s.findUsersHandler)
-	router.Get("/:id", s.findUserByIDHandler)
-	router.Patch("/:id", s.updateUserHandler)
-	router.Delete("/:id", s.deleteUserHandler)
+	router.Get("", s.requireAuth, requireRole(db.RoleAdmin), s.findUsersHandler)
+	router.Get("/search", s.requireAuth, requireRole(db.RoleAdmin), s.searchUsersHandler)
+	router.Get("/:id", s.requireAuth, s.findUserByIDHandler)
+	router.Patch("/:id", s.requireAuth, s.updateUserHandler)
+	router.Delete("/:id", s.requireAuth, forbidImpersonation, s.deleteUserHandler)
 }
 
 func (s *Service) createUserHandler(c *fiber.Ctx) error {
@@ -28,43 +34,131 @@ func (s *Service) createUserHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errors)
 	}
 
-	existingUser, err := s.queries.GetUserByEmail(c.Context(), userParams.Email)
+	// Accounts without a password sign in with magic links or a provider.
+	// The empty hash never matches, so password login stays closed to them.
+	if userParams.Password != "" {
+		hash, err := s.hasher.Hash(userParams.Password)
+		if err != nil {
+			return err
+		}
+
+		userParams.Password = hash
+	}
+
+	// The email check and the insert share a transaction, so two requests
+	// for the same email cannot both pass the check.
+	var user db.User
+	emailTaken := false
+	err := s.queries.RunInTx(c.Context(), func(q *db.Queries) error {
+		existingUser, err := q.GetUserByEmail(c.Context(), userParams.Email)
+		if err != nil {
+			return err
+		}
+
+		emailTaken = existingUser.Email != ""
+		if emailTaken {
+			return nil
+		}
+
+		user, err = q.CreateUser(c.Context(), userParams)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	if existingUser.Email != "" {
+	if emailTaken {
 		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
 			"message": "Email already exists",
 		})
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(userParams.Password), bcrypt.DefaultCost)
-	if err != nil {
+	// The account exists either way, so a mail failure must not make the
+	// client retry into "Email already exists".
+	if err := s.sendVerificationEmail(c, user.ID, user.Email); err != nil {
+		log.Printf("send verification email to user %s: %v", user.ID, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
+
+var userListSchema = &listSchema[db.User]{
+	filters: map[string]listFilter{
+		"email_contains": {`email LIKE ? ESCAPE '\'`, parseContains},
+		"name_contains":  {`name LIKE ? ESCAPE '\'`, parseContains},
+		"created_after":  {"created_at > ?", parseTime},
+		"created_before": {"created_at < ?", parseTime},
+		"verified":       {"(email_verified_at IS NOT NULL) = ?", parseBool},
+		"role":           {"role = ?", parseString},
+	},
+	sorts: map[string]func(db.User) interface{}{
+		"id":         func(user db.User) interface{} { return user.ID },
+		"email":      func(user db.User) interface{} { return user.Email },
+		"created_at": func(user db.User) interface{} { return user.CreatedAt },
+	},
+	id:          func(user db.User) string { return user.ID },
+	defaultSort: "id",
+}
+
+func (s *Service) findUsersHandler(c *fiber.Ctx) error {
+	pageParams := utils.PageParams{}
+
+	if err := c.QueryParser(&pageParams); err != nil {
 		return err
 	}
 
-	userParams.Password = string(hash)
+	errors := utils.ValidateStruct(pageParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
 
-	user, err := s.queries.CreateUser(c.Context(), userParams)
+	query, sortBy, errors := userListSchema.compile(c, pageParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	users, err := s.queries.GetUsers(c.Context(), query)
 	if err != nil {
 		return err
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(user)
+	return c.JSON(utils.NewPage(users, pageParams.PageLimit(), userListSchema.cursor(sortBy)))
 }
 
-func (s *Service) findUsersHandler(c *fiber.Ctx) error {
-	users, err := s.queries.GetUsers(c.Context())
+func (s *Service) searchUsersHandler(c *fiber.Ctx) error {
+	if !db.SearchEnabled {
+		return fiber.NewError(fiber.StatusNotImplemented, "User search is not available")
+	}
+
+	searchParams := SearchUsersParams{}
+
+	if err := c.QueryParser(&searchParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(searchParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	users, err := s.queries.SearchUsers(c.Context(), searchParams.Q)
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(users)
+	return c.JSON(&fiber.Map{
+		"data": users,
+	})
 }
 
 func (s *Service) findUserByIDHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
+	if !canManageUser(c, id) {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "You are not allowed to do this",
+		})
+	}
+
 	user, err := s.queries.GetUserByID(c.Context(), id)
 	if err != nil {
 		return err
@@ -81,6 +175,12 @@ func (s *Service) findUserByIDHandler(c *fiber.Ctx) error {
 
 func (s *Service) updateUserHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
+	if !canManageUser(c, id) {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "You are not allowed to do this",
+		})
+	}
+
 	existingUser, err := s.queries.GetUserByID(c.Context(), id)
 	if err != nil {
 		return err
@@ -103,31 +203,99 @@ func (s *Service) updateUserHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errors)
 	}
 
-	user, err := s.queries.UpdateUser(c.Context(), userParams, id)
+	if userParams.Password.Valid && isImpersonating(c) {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "Not allowed while impersonating a user",
+		})
+	}
+
+	// Admins would otherwise take over the account without the audit trail
+	// that impersonating it leaves.
+	if userParams.Password.Valid && currentUserID(c) != id {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "Only the account owner can change its password",
+		})
+	}
+
+	if userParams.Password.Valid {
+		errors = utils.ValidatePassword("UpdateUserParams.Password", userParams.Password.String,
+			existingUser.Email, existingUser.Name.String, userParams.Name.String)
+		if errors != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(errors)
+		}
+
+		hash, err := s.hasher.Hash(userParams.Password.String)
+		if err != nil {
+			return err
+		}
+		userParams.Password.String = hash
+	}
+
+	var user db.User
+	found := false
+	err = s.queries.RunInTx(c.Context(), func(q *db.Queries) error {
+		existingUser, err := q.GetUserByID(c.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		found = existingUser.Email != ""
+		if !found {
+			return nil
+		}
+
+		user, err = q.UpdateUser(c.Context(), userParams, id)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
+	if !found {
+		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
+			"message": "User not found",
+		})
+	}
+
 	return c.JSON(user)
 }
 
 func (s *Service) deleteUserHandler(c *fiber.Ctx) error {
 	id := c.Params("id")
-	existingUser, err := s.queries.GetUserByID(c.Context(), id)
+	if !canManageUser(c, id) {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "You are not allowed to do this",
+		})
+	}
+
+	found := false
+	err := s.queries.RunInTx(c.Context(), func(q *db.Queries) error {
+		existingUser, err := q.GetUserByID(c.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		found = existingUser.Email != ""
+		if !found {
+			return nil
+		}
+
+		// The impersonations go with the account, so record that they ended.
+		if err := s.endAdminImpersonations(c, q, id, "admin deleted"); err != nil {
+			return err
+		}
+
+		return q.DeleteUser(c.Context(), id)
+	})
 	if err != nil {
 		return err
 	}
 
-	if existingUser.Email == "" {
+	if !found {
 		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
 			"message": "User not found",
 		})
 	}
 
-	err = s.queries.DeleteUser(c.Context(), id)
-	if err != nil {
-		return err
-	}
-
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
@@ -0,0 +1,13 @@
+//go:build !sqlite_fts5
+
+package routes
+
+import (
+	"net/http/httptest"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (s *UserRoutesTestSuite) TestSearchUsersNotBuiltIn() {
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users/search?q=ash", nil), fiber.StatusNotImplemented, nil)
+}
@@ -0,0 +1,29 @@
+//go:build sqlite_fts5
+
+package routes
+
+import (
+	"net/http/httptest"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+func (s *UserRoutesTestSuite) TestSearchUsers() {
+	var result struct {
+		Data []db.UserSearchResult `json:"data"`
+	}
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users/search?q=ash", nil), fiber.StatusOK, &result)
+	s.Len(result.Data, 1)
+	s.Equal("3", result.Data[0].ID)
+	s.Equal("<mark>Ashwin</mark>", result.Data[0].Snippet)
+
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users/search?q=exam", nil), fiber.StatusOK, &result)
+	s.Len(result.Data, 3)
+
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users/search", nil), fiber.StatusBadRequest, nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/users/search?q=ash", nil)
+	req.Header.Set("Authorization", "Bearer "+s.memberToken)
+	s.checkReqStatus(req, fiber.StatusForbidden, nil)
+}
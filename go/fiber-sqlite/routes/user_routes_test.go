@@ -2,11 +2,13 @@ package routes
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ashwins93/fiber-sql/db"
@@ -19,13 +21,15 @@ import (
 
 type UserRoutesTestSuite struct {
 	suite.Suite
-	q    *db.Queries
-	conn *sql.DB
-	app  *fiber.App
+	q           *db.Queries
+	conn        *sql.DB
+	app         *fiber.App
+	token       string
+	memberToken string
 }
 
 func (s *UserRoutesTestSuite) SetupSuite() {
-	conn, err := sql.Open("sqlite3", "file:../db/test.db?_fk=1")
+	conn, err := sql.Open("sqlite3", "file:"+testDB+"?_fk=1")
 	if err != nil {
 		panic(err)
 	}
@@ -37,11 +41,23 @@ func (s *UserRoutesTestSuite) SetupSuite() {
 		JSONDecoder: json.Unmarshal,
 	})
 	idGen := utils.NewNanoIDGenerator(21)
+	tokens := utils.NewJWTMaker(testJWTSecret)
 
-	service := NewService(s.q, s.app, idGen)
+	service := NewService(s.q, s.app, idGen, tokens, testHasher, &recordingMailer{}, Config{})
 	service.SetupV1Routes()
 
 	seedDataIntoDb(s.q)
+	s.conn.Exec("UPDATE users SET role = 'admin' WHERE id = '1'")
+
+	s.token, err = createSessionToken(s.q, tokens, "1", db.RoleAdmin)
+	if err != nil {
+		panic(err)
+	}
+
+	s.memberToken, err = createSessionToken(s.q, tokens, "2", db.RoleMember)
+	if err != nil {
+		panic(err)
+	}
 }
 
 func (s *UserRoutesTestSuite) BeforeTest(suite, testName string) {
@@ -59,24 +75,105 @@ func (s *UserRoutesTestSuite) AfterTest(suite, testName string) {
 	switch testName {
 	case "TestCreateUser":
 		s.conn.Exec("DELETE FROM users WHERE email = 'ash@example.com'")
+	case "TestCreateUserWithLongPassphrase":
+		s.conn.Exec("DELETE FROM users WHERE email = 'passphrase@example.com'")
+	case "TestCreateUserConcurrently":
+		s.conn.Exec("DELETE FROM users WHERE email = 'race@example.com'")
 	}
 }
 
 func (s *UserRoutesTestSuite) TearDownSuite() {
 	// cleanup
 	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
 	s.conn.Close()
 }
 
 func (s *UserRoutesTestSuite) TestGetUsers() {
 	req := httptest.NewRequest("GET", "/api/v1/users", nil)
 
-	var users []db.User
-	s.checkReqStatus(req, fiber.StatusOK, &users)
+	var page utils.Page[db.User]
+	s.checkReqStatus(req, fiber.StatusOK, &page)
+
+	if len(page.Data) != 3 {
+		s.T().Errorf("Expected 3 users but got %d", len(page.Data))
+	}
+	s.Nil(page.NextCursor)
+}
 
-	if len(users) != 3 {
-		s.T().Errorf("Expected 3 users but got %d", len(users))
+func (s *UserRoutesTestSuite) TestGetUsersPaginated() {
+	var ids []string
+	cursor := ""
+	for pages := 0; pages < 3; pages++ {
+		req := httptest.NewRequest("GET", "/api/v1/users?limit=2&cursor="+cursor, nil)
+
+		var page utils.Page[db.User]
+		s.checkReqStatus(req, fiber.StatusOK, &page)
+		for _, user := range page.Data {
+			ids = append(ids, user.ID)
+		}
+
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
 	}
+
+	s.Equal([]string{"1", "2", "3"}, ids)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersFiltered() {
+	s.Equal([]string{"1", "2"}, s.listUserIDs("/api/v1/users?email_contains=DOE"))
+	s.Equal([]string{"3"}, s.listUserIDs("/api/v1/users?name_contains=ash&email_contains=example"))
+	s.Empty(s.listUserIDs("/api/v1/users?email_contains=%25"))
+	s.Empty(s.listUserIDs("/api/v1/users?created_after=2999-01-01T00:00:00Z"))
+	s.Len(s.listUserIDs("/api/v1/users?created_after=2023-01-01&verified=false&email_contains="), 3)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersSorted() {
+	s.Equal([]string{"3", "2", "1"}, s.listUserIDs("/api/v1/users?sort=-id"))
+	s.Equal([]string{"3", "2", "1"}, s.listUserIDs("/api/v1/users?sort=email"))
+
+	// the seeded users share a created_at, so pages fall back to the id
+	var ids []string
+	cursor := ""
+	for pages := 0; pages < 3; pages++ {
+		req := httptest.NewRequest("GET", "/api/v1/users?sort=-created_at&limit=1&cursor="+cursor, nil)
+
+		var page utils.Page[db.User]
+		s.checkReqStatus(req, fiber.StatusOK, &page)
+		for _, user := range page.Data {
+			ids = append(ids, user.ID)
+		}
+
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+	s.Equal([]string{"3", "2"}, ids[:2])
+	s.Len(ids, 3)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersRejectsUnknownFields() {
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?password=secret", nil), fiber.StatusBadRequest, &errors)
+	s.Len(errors, 1)
+	s.Equal("password", errors[0].FailedField)
+
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?sort=password", nil), fiber.StatusBadRequest, nil)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?created_after=yesterday", nil), fiber.StatusBadRequest, nil)
+
+	var page utils.Page[db.User]
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?limit=1", nil), fiber.StatusOK, &page)
+	s.NotNil(page.NextCursor)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?sort=email&cursor="+*page.NextCursor, nil), fiber.StatusBadRequest, nil)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersInvalidPage() {
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?limit=-1", nil), fiber.StatusBadRequest, nil)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?limit=101", nil), fiber.StatusBadRequest, nil)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?cursor=not-a-cursor!", nil), fiber.StatusBadRequest, nil)
 }
 
 func (s *UserRoutesTestSuite) TestGetUser() {
@@ -114,6 +211,34 @@ func (s *UserRoutesTestSuite) TestCreateUser() {
 	s.NotEmpty(user.UpdatedAt)
 }
 
+func (s *UserRoutesTestSuite) TestCreateUserConcurrently() {
+	var wg sync.WaitGroup
+	statuses := make(chan int, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			requestBody := []byte(`{"name": "Racer", "email": "race@example.com", "password": "password"}`)
+			req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(requestBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := s.app.Test(req, -1)
+			if err != nil {
+				panic(err)
+			}
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	s.Equal(map[int]int{fiber.StatusCreated: 1, fiber.StatusBadRequest: 4}, counts)
+}
+
 func (s *UserRoutesTestSuite) TestCreateUserWithInvalidBody() {
 	requestBody := []byte(`{"name": "Ashwin", "email": "ash@example.com", "password": "pass"}`)
 	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(requestBody))
@@ -126,6 +251,39 @@ func (s *UserRoutesTestSuite) TestCreateUserWithInvalidBody() {
 	s.Contains(strings.ToLower(errors[0].FailedField), "password")
 }
 
+func (s *UserRoutesTestSuite) TestCreateUserWithLongPassphrase() {
+	requestBody := []byte(`{"email": "passphrase@example.com", "password": "correct horse battery staple"}`)
+	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	s.checkReqStatus(req, fiber.StatusCreated, nil)
+}
+
+func (s *UserRoutesTestSuite) TestCreateUserPasswordContainingEmail() {
+	requestBody := []byte(`{"email": "marigold@example.com", "password": "marigold2023"}`)
+	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(req, fiber.StatusBadRequest, &errors)
+
+	s.Equal(1, len(errors))
+	s.Equal("password", errors[0].Tag)
+	s.Equal(utils.PasswordRuleUserInfo, errors[0].Value)
+}
+
+func (s *UserRoutesTestSuite) TestUpdateUserPasswordContainingName() {
+	requestBody := []byte(`{"password": "johndoe2023!"}`)
+	req := httptest.NewRequest("PATCH", "/api/v1/users/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(req, fiber.StatusBadRequest, &errors)
+
+	s.Equal(1, len(errors))
+	s.Equal(utils.PasswordRuleUserInfo, errors[0].Value)
+}
+
 func (s *UserRoutesTestSuite) TestUpdateUserName() {
 	requestBody := []byte(`{"name": "Ashwin S"}`)
 	req := httptest.NewRequest("PATCH", "/api/v1/users/1", bytes.NewBuffer(requestBody))
@@ -138,6 +296,36 @@ func (s *UserRoutesTestSuite) TestUpdateUserName() {
 	s.Equal("1", user.ID)
 }
 
+func (s *UserRoutesTestSuite) TestUpdateUserPasswordIsHashed() {
+	requestBody := []byte(`{"password": "newpassword"}`)
+	req := httptest.NewRequest("PATCH", "/api/v1/users/2", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.memberToken)
+	s.checkReqStatus(req, fiber.StatusOK, nil)
+
+	user, err := s.q.GetUserByID(context.Background(), "2")
+	s.NoError(err)
+	s.NotEqual("newpassword", user.Password)
+
+	ok, _, err := testHasher.Verify("newpassword", user.Password)
+	s.NoError(err)
+	s.True(ok)
+}
+
+func (s *UserRoutesTestSuite) TestAdminCannotChangeOthersPassword() {
+	requestBody := []byte(`{"password": "takeover-password"}`)
+	req := httptest.NewRequest("PATCH", "/api/v1/users/3", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	s.checkReqStatus(req, fiber.StatusForbidden, nil)
+
+	user, err := s.q.GetUserByID(context.Background(), "3")
+	s.NoError(err)
+
+	ok, _, err := testHasher.Verify("password", user.Password)
+	s.NoError(err)
+	s.True(ok, "The password is unchanged")
+}
+
 func (s *UserRoutesTestSuite) TestDeleteUser() {
 	req := httptest.NewRequest("DELETE", "/api/v1/users/5", nil)
 
@@ -152,12 +340,59 @@ func (s *UserRoutesTestSuite) TestDeleteUser() {
 	s.Equal("", user.Name.String)
 }
 
+func (s *UserRoutesTestSuite) TestGetUsersWithoutToken() {
+	req := httptest.NewRequest("GET", "/api/v1/users", nil)
+
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+	s.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func (s *UserRoutesTestSuite) TestMemberCannotListUsers() {
+	req := httptest.NewRequest("GET", "/api/v1/users", nil)
+	req.Header.Set("Authorization", "Bearer "+s.memberToken)
+
+	s.checkReqStatus(req, fiber.StatusForbidden, nil)
+}
+
+func (s *UserRoutesTestSuite) TestMemberCanUpdateSelf() {
+	requestBody := []byte(`{"name": "Jane D"}`)
+	req := httptest.NewRequest("PATCH", "/api/v1/users/2", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.memberToken)
+
+	var user db.User
+	s.checkReqStatus(req, fiber.StatusOK, &user)
+
+	s.Equal("Jane D", user.Name.String)
+	s.Equal(db.RoleMember, user.Role)
+}
+
+func (s *UserRoutesTestSuite) TestMemberCannotUpdateOthers() {
+	requestBody := []byte(`{"name": "Hacked"}`)
+	req := httptest.NewRequest("PATCH", "/api/v1/users/1", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.memberToken)
+
+	s.checkReqStatus(req, fiber.StatusForbidden, nil)
+}
+
+func (s *UserRoutesTestSuite) TestMemberCannotDeleteOthers() {
+	req := httptest.NewRequest("DELETE", "/api/v1/users/3", nil)
+	req.Header.Set("Authorization", "Bearer "+s.memberToken)
+
+	s.checkReqStatus(req, fiber.StatusForbidden, nil)
+}
+
 func TestUserRoutes(t *testing.T) {
 	suite.Run(t, new(UserRoutesTestSuite))
 }
 
 func (s *UserRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
 	s.T().Helper()
+	if req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
 	resp, err := s.app.Test(req, -1)
 	s.NoError(err)
 
@@ -173,3 +408,15 @@ func (s *UserRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus i
 		s.NoError(err)
 	}
 }
+
+func (s *UserRoutesTestSuite) listUserIDs(path string) []string {
+	s.T().Helper()
+	var page utils.Page[db.User]
+	s.checkReqStatus(httptest.NewRequest("GET", path, nil), fiber.StatusOK, &page)
+
+	ids := []string{}
+	for _, user := range page.Data {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
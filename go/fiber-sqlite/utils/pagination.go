@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type PageParams struct {
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor string `query:"cursor"`
+}
+
+// PageLimit returns the requested limit, or DefaultPageLimit when none was
+// given.
+func (p PageParams) PageLimit() int {
+	if p.Limit == 0 {
+		return DefaultPageLimit
+	}
+	return p.Limit
+}
+
+// Page is the response envelope for paginated listings. NextCursor is null
+// on the last page.
+type Page[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// NewPage builds a page from up to limit+1 items. The extra item only tells
+// that another page follows, and is dropped.
+func NewPage[T any](items []T, limit int, key func(T) string) Page[T] {
+	page := Page[T]{Data: items}
+	if len(items) > limit {
+		page.Data = items[:limit]
+		cursor := EncodeCursor(key(items[limit-1]))
+		page.NextCursor = &cursor
+	}
+	return page
+}
+
+// EncodeCursor hides the key so clients treat cursors as opaque.
+func EncodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func DecodeCursor(cursor string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(key) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(key), nil
+}
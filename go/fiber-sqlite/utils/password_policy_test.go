@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PasswordPolicyTestSuite struct {
+	suite.Suite
+	policy PasswordPolicy
+}
+
+func (s *PasswordPolicyTestSuite) SetupTest() {
+	s.policy = DefaultPasswordPolicy
+}
+
+func (s *PasswordPolicyTestSuite) TestLength() {
+	s.checkRule("short", PasswordRuleMinLength)
+	s.checkRule("correct horse battery staple", "")
+
+	s.policy.MaxLength = 10
+	s.checkRule("correct horse battery staple", PasswordRuleMaxLength)
+}
+
+func (s *PasswordPolicyTestSuite) TestLengthCountsCharacters() {
+	s.policy.MinEntropy = 0
+	s.checkRule("пароль", PasswordRuleMinLength)
+	s.checkRule("парольпароль", "")
+}
+
+func (s *PasswordPolicyTestSuite) TestMaxBytes() {
+	s.policy.MinEntropy = 0
+	s.policy.MaxBytes = BcryptMaxPasswordBytes
+
+	// 42 characters, but 84 bytes in UTF-8
+	s.checkRule(strings.Repeat("пароль", 7), PasswordRuleMaxLength)
+	s.checkRule(strings.Repeat("parole", 7), "")
+}
+
+func (s *PasswordPolicyTestSuite) TestCharClasses() {
+	s.policy.MinCharClasses = 3
+	s.checkRule("onlylowercase", PasswordRuleCharClasses)
+	s.checkRule("Mixed-case", "")
+}
+
+func (s *PasswordPolicyTestSuite) TestEntropy() {
+	s.checkRule("aaaaaaaaaaaa", PasswordRuleEntropy)
+	s.checkRule("123456789012", PasswordRuleEntropy)
+	s.checkRule("zebraquilt", "")
+
+	s.Less(PasswordEntropy("abcdefgh"), PasswordEntropy("hbfdaceg"))
+	s.Equal(0.0, PasswordEntropy(""))
+}
+
+func (s *PasswordPolicyTestSuite) TestUserInfo() {
+	rule, err := s.policy.Check("johnny2023!", "john.doe@example.com", "John Doe")
+	s.NoError(err)
+	s.Equal(PasswordRuleUserInfo, rule)
+
+	// the domain and words shorter than three characters are ignored
+	rule, err = s.policy.Check("exampledeal", "jo@example.com", "Jo")
+	s.NoError(err)
+	s.Equal("", rule)
+
+	s.policy.DisallowUserInfo = false
+	rule, err = s.policy.Check("johnny2023!", "john.doe@example.com")
+	s.NoError(err)
+	s.Equal("", rule)
+}
+
+func (s *PasswordPolicyTestSuite) TestBreachedPasswordDir() {
+	dir := s.T().TempDir()
+
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	err := os.WriteFile(filepath.Join(dir, "5BAA6"), []byte(
+		"003D68EB55068C33ACE09247EE4C639306B:3\r\n"+
+			"1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n",
+	), 0o644)
+	s.NoError(err)
+
+	// a padded range file lists fake suffixes with a count of 0
+	err = os.WriteFile(filepath.Join(dir, "7C4A8"), []byte(
+		"D09CA3762AF61E59520943DC26494F8941B:0\n",
+	), 0o644)
+	s.NoError(err)
+
+	breached := NewBreachedPasswordDir(dir)
+
+	found, err := breached.Contains("password")
+	s.NoError(err)
+	s.True(found)
+
+	found, err = breached.Contains("123456")
+	s.NoError(err)
+	s.False(found)
+
+	found, err = breached.Contains("zebraquilt")
+	s.NoError(err)
+	s.False(found, "A missing range file means the password is not known")
+
+	s.policy.Breached = breached
+	s.checkRule("password", PasswordRuleBreached)
+}
+
+func (s *PasswordPolicyTestSuite) TestUnreadableBreachedPasswordDir() {
+	dir := s.T().TempDir()
+
+	// a directory where the range file should be cannot be read
+	s.NoError(os.Mkdir(filepath.Join(dir, "5BAA6"), 0o755))
+
+	_, err := NewBreachedPasswordDir(dir).Contains("password")
+	s.Error(err)
+
+	policy := DefaultPasswordPolicy
+	policy.MinEntropy = 0
+	policy.Breached = NewBreachedPasswordDir(dir)
+	SetPasswordPolicy(policy)
+	defer SetPasswordPolicy(DefaultPasswordPolicy)
+
+	errors := ValidatePassword("Password", "password")
+	s.Len(errors, 1)
+	s.Equal(PasswordRuleUnchecked, errors[0].Value, "The password is refused but not called breached")
+}
+
+func (s *PasswordPolicyTestSuite) TestValidateStructReportsRule() {
+	type params struct {
+		Email    string `validate:"required,email"`
+		Password string `validate:"required,password=Email"`
+	}
+
+	errors := ValidateStruct(params{Email: "zebra@example.com", Password: "zebraquilt"})
+	s.Len(errors, 1)
+	s.Equal("params.Password", errors[0].FailedField)
+	s.Equal("password", errors[0].Tag)
+	s.Equal(PasswordRuleUserInfo, errors[0].Value)
+
+	errors = ValidateStruct(params{Email: "ash@example.com", Password: "zebraquilt"})
+	s.Nil(errors)
+}
+
+func (s *PasswordPolicyTestSuite) TestValidatePassword() {
+	errors := ValidatePassword("Password", "short")
+	s.Len(errors, 1)
+	s.Equal(PasswordRuleMinLength, errors[0].Value)
+
+	s.Nil(ValidatePassword("Password", "zebraquilt", "ash@example.com"))
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	suite.Run(t, new(PasswordPolicyTestSuite))
+}
+
+func (s *PasswordPolicyTestSuite) checkRule(password string, expected string) {
+	s.T().Helper()
+	rule, err := s.policy.Check(password)
+	s.NoError(err)
+	s.Equal(expected, rule, password)
+}
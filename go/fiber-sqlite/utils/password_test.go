@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters keep the tests fast
+var testArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+type PasswordTestSuite struct {
+	suite.Suite
+	argon2id PasswordHasher
+	bcrypt   PasswordHasher
+}
+
+func (s *PasswordTestSuite) SetupSuite() {
+	s.argon2id = NewArgon2idHasher(testArgon2idParams)
+	s.bcrypt = NewBcryptHasher(bcrypt.MinCost)
+}
+
+func (s *PasswordTestSuite) TestArgon2idHash() {
+	hash, err := s.argon2id.Hash("password")
+	s.NoError(err)
+	s.True(strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	ok, needsRehash, err := s.argon2id.Verify("password", hash)
+	s.NoError(err)
+	s.True(ok)
+	s.False(needsRehash)
+
+	ok, _, err = s.argon2id.Verify("wrongpassword", hash)
+	s.NoError(err)
+	s.False(ok)
+
+	other, err := s.argon2id.Hash("password")
+	s.NoError(err)
+	s.NotEqual(hash, other, "Every hash gets its own salt")
+}
+
+func (s *PasswordTestSuite) TestArgon2idParamsChange() {
+	hash, err := s.argon2id.Hash("password")
+	s.NoError(err)
+
+	stronger := testArgon2idParams
+	stronger.Iterations = 2
+
+	ok, needsRehash, err := NewArgon2idHasher(stronger).Verify("password", hash)
+	s.NoError(err)
+	s.True(ok)
+	s.True(needsRehash)
+}
+
+func (s *PasswordTestSuite) TestBcryptUpgradesToArgon2id() {
+	hash, err := s.bcrypt.Hash("password")
+	s.NoError(err)
+
+	ok, needsRehash, err := s.argon2id.Verify("password", hash)
+	s.NoError(err)
+	s.True(ok)
+	s.True(needsRehash)
+
+	ok, needsRehash, err = s.argon2id.Verify("wrongpassword", hash)
+	s.NoError(err)
+	s.False(ok)
+	s.False(needsRehash)
+}
+
+func (s *PasswordTestSuite) TestBcryptCostChange() {
+	hash, err := s.bcrypt.Hash("password")
+	s.NoError(err)
+
+	ok, needsRehash, err := s.bcrypt.Verify("password", hash)
+	s.NoError(err)
+	s.True(ok)
+	s.False(needsRehash)
+
+	ok, needsRehash, err = NewBcryptHasher(bcrypt.MinCost+1).Verify("password", hash)
+	s.NoError(err)
+	s.True(ok)
+	s.True(needsRehash)
+}
+
+func (s *PasswordTestSuite) TestBcryptRejectsLongPasswords() {
+	_, err := s.bcrypt.Hash(strings.Repeat("a", BcryptMaxPasswordBytes+1))
+	s.ErrorIs(err, ErrPasswordTooLong)
+
+	_, err = s.argon2id.Hash(strings.Repeat("a", BcryptMaxPasswordBytes+1))
+	s.NoError(err)
+}
+
+func (s *PasswordTestSuite) TestEmptyHashNeverMatches() {
+	ok, _, err := s.argon2id.Verify("", "")
+	s.NoError(err)
+	s.False(ok)
+
+	ok, _, err = s.bcrypt.Verify("password", "")
+	s.NoError(err)
+	s.False(ok)
+}
+
+func (s *PasswordTestSuite) TestMalformedHash() {
+	_, _, err := s.argon2id.Verify("password", "plaintext")
+	s.ErrorIs(err, ErrUnknownHashFormat)
+
+	_, _, err = s.argon2id.Verify("password", "$argon2id$v=19$m=1024$salt$key")
+	s.ErrorIs(err, ErrUnknownHashFormat)
+}
+
+func TestPassword(t *testing.T) {
+	suite.Run(t, new(PasswordTestSuite))
+}
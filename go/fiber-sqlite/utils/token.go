@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrInvalidToken = errors.New("token is invalid or has expired")
+
+type TokenClaims struct {
+	Role string `json:"role"`
+	// SessionID is the session the token was issued to, so that revoking the
+	// session also invalidates the token.
+	SessionID string `json:"sid,omitempty"`
+	// ImpersonationID is set when an admin is acting as the subject.
+	ImpersonationID string `json:"imp,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type TokenMaker interface {
+	CreateToken(userID string, role string, sessionID string, duration time.Duration) (string, error)
+	CreateImpersonationToken(userID string, role string, impersonationID string, duration time.Duration) (string, error)
+	VerifyToken(token string) (*TokenClaims, error)
+}
+
+type jwtMaker struct {
+	secret []byte
+}
+
+func NewJWTMaker(secret string) TokenMaker {
+	return &jwtMaker{
+		[]byte(secret),
+	}
+}
+
+func (m *jwtMaker) CreateToken(userID string, role string, sessionID string, duration time.Duration) (string, error) {
+	return m.createToken(userID, TokenClaims{Role: role, SessionID: sessionID}, duration)
+}
+
+func (m *jwtMaker) CreateImpersonationToken(userID string, role string, impersonationID string, duration time.Duration) (string, error) {
+	return m.createToken(userID, TokenClaims{Role: role, ImpersonationID: impersonationID}, duration)
+}
+
+func (m *jwtMaker) createToken(userID string, claims TokenClaims, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Subject:   userID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(m.secret)
+}
+
+func (m *jwtMaker) VerifyToken(token string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return m.secret, nil
+	})
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// GenerateOpaqueToken returns a random URL-safe token together with the hash
+// that should be persisted in its place.
+func GenerateOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashOpaqueToken(token), nil
+}
+
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const APIKeyPrefix = "sk_"
+
+// GenerateAPIKey returns a new key of the form sk_<id>.<secret> along with the
+// lookup prefix (sk_<id>) and the hash of the secret that should be stored.
+func GenerateAPIKey() (string, string, string, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", "", err
+	}
+	prefix := APIKeyPrefix + hex.EncodeToString(id)
+
+	secret, secretHash, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return prefix + "." + secret, prefix, secretHash, nil
+}
+
+// SplitAPIKey returns the prefix and secret of key, or false when key is not
+// shaped like an API key.
+func SplitAPIKey(key string) (string, string, bool) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return "", "", false
+	}
+	prefix, secret, ok := strings.Cut(key, ".")
+	if !ok || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
@@ -1,6 +1,11 @@
 package utils
 
 import (
+	"context"
+	"log"
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 )
 
@@ -12,21 +17,93 @@ type ErrorResponse struct {
 
 var validate *validator.Validate
 
+type passwordRulesKey struct{}
+
 func init() {
 	validate = validator.New()
+	validate.RegisterValidationCtx("password", validatePassword)
 }
 
 func ValidateStruct(s interface{}) []*ErrorResponse {
 	var errors []*ErrorResponse
-	err := validate.Struct(s)
+
+	// validatePassword records which rule each failing password broke, in
+	// the same order as the validation errors.
+	var rules []string
+	ctx := context.WithValue(context.Background(), passwordRulesKey{}, &rules)
+
+	err := validate.StructCtx(ctx, s)
 	if err != nil {
 		for _, err := range err.(validator.ValidationErrors) {
 			var element ErrorResponse
 			element.FailedField = err.StructNamespace()
 			element.Tag = err.Tag()
 			element.Value = err.Param()
+			if err.Tag() == "password" && len(rules) > 0 {
+				element.Value, rules = rules[0], rules[1:]
+			}
 			errors = append(errors, &element)
 		}
 	}
 	return errors
 }
+
+// ValidatePassword checks a password against the password policy outside of
+// a struct, for handlers that only learn the user's details after parsing
+// the request.
+func ValidatePassword(field string, password string, userInputs ...string) []*ErrorResponse {
+	rule := checkPassword(password, userInputs)
+	if rule == "" {
+		return nil
+	}
+	return []*ErrorResponse{{FailedField: field, Tag: "password", Value: rule}}
+}
+
+// validatePassword implements the "password" tag. The optional parameter
+// lists sibling fields, such as "password=Email Name", whose values the
+// password must not contain.
+func validatePassword(ctx context.Context, fl validator.FieldLevel) bool {
+	var userInputs []string
+	parent := reflect.Indirect(fl.Parent())
+	for _, name := range strings.Fields(fl.Param()) {
+		if field := parent.FieldByName(name); field.IsValid() {
+			userInputs = append(userInputs, stringValue(field))
+		}
+	}
+
+	rule := checkPassword(fl.Field().String(), userInputs)
+	if rule == "" {
+		return true
+	}
+
+	if rules, ok := ctx.Value(passwordRulesKey{}).(*[]string); ok {
+		*rules = append(*rules, rule)
+	}
+	return false
+}
+
+// checkPassword applies the password policy and fails closed when the
+// breached password list cannot be read.
+func checkPassword(password string, userInputs []string) string {
+	rule, err := passwordPolicy.Check(password, userInputs...)
+	if err != nil {
+		log.Printf("check breached passwords: %v", err)
+		return PasswordRuleUnchecked
+	}
+	return rule
+}
+
+// stringValue reads a string field or the String field of a nullable
+// wrapper like db.NullString.
+func stringValue(v reflect.Value) string {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Struct:
+		if s := v.FieldByName("String"); s.IsValid() && s.Kind() == reflect.String {
+			return s.String()
+		}
+	}
+	return ""
+}
@@ -12,10 +12,9 @@ import (
 )
 
 type Queries struct {
-	db *sqlx.DB
+	db DBTX
 }
 
-
 func NewDb(db *sqlx.DB) *Queries {
 	return &Queries{
 		db: db,
@@ -39,5 +38,5 @@ func (s *NullString) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 	s.Valid = true
-  return json.Unmarshal(data, &s.String)
-}
\ No newline at end of file
+	return json.Unmarshal(data, &s.String)
+}
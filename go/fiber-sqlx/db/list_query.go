@@ -0,0 +1,38 @@
+package db
+
+import (
+	"strings"
+)
+
+// ListQuery filters, orders and limits a listing. It is compiled by the
+// routes package from whitelisted query parameters: Conditions and OrderBy
+// hold SQL built only from known column names, and every value is passed
+// through Args with ? placeholders.
+type ListQuery struct {
+	Conditions []string
+	Args       []interface{}
+	OrderBy    []string
+	Limit      int
+}
+
+// build appends the WHERE, ORDER BY and LIMIT clauses to a SELECT.
+func (l ListQuery) build(query string) (string, []interface{}) {
+	var b strings.Builder
+	b.WriteString(query)
+	for i, condition := range l.Conditions {
+		if i == 0 {
+			b.WriteString("WHERE ")
+		} else {
+			b.WriteString("AND ")
+		}
+		b.WriteString("(" + condition + ")\n")
+	}
+	if len(l.OrderBy) > 0 {
+		b.WriteString("ORDER BY " + strings.Join(l.OrderBy, ", ") + "\n")
+	}
+	b.WriteString("LIMIT ?\n")
+
+	args := make([]interface{}, 0, len(l.Args)+1)
+	args = append(args, l.Args...)
+	return b.String(), append(args, l.Limit)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type LoginAttempt struct {
+	Key          string    `json:"key"`
+	Failures     int64     `json:"failures"`
+	LastFailedAt int64     `json:"last_failed_at" db:"last_failed_at"`
+	LockedUntil  NullInt64 `json:"locked_until" db:"locked_until"`
+}
+
+const getLoginAttempt = `
+SELECT key, failures, last_failed_at, locked_until
+FROM login_attempts
+WHERE key = $1
+`
+
+func (q *Queries) GetLoginAttempt(ctx context.Context, key string) (LoginAttempt, error) {
+	var i LoginAttempt
+	err := q.db.GetContext(ctx, &i, getLoginAttempt, key)
+	if err == sql.ErrNoRows {
+		return LoginAttempt{}, nil
+	}
+	return i, err
+}
+
+const recordLoginFailure = `
+INSERT INTO login_attempts (key, failures, last_failed_at)
+VALUES ($1, 1, unixepoch())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE WHEN last_failed_at < $2 THEN 1 ELSE failures + 1 END,
+    last_failed_at = unixepoch()
+RETURNING key, failures, last_failed_at, locked_until
+`
+
+// RecordLoginFailure counts a failed login for key. Failures older than
+// windowStart no longer count and the tally starts over.
+func (q *Queries) RecordLoginFailure(ctx context.Context, key string, windowStart int64) (LoginAttempt, error) {
+	var i LoginAttempt
+	err := q.db.GetContext(ctx, &i, recordLoginFailure, key, windowStart)
+	return i, err
+}
+
+const lockLogin = `
+UPDATE login_attempts
+SET locked_until = $1
+WHERE key = $2
+`
+
+func (q *Queries) LockLogin(ctx context.Context, key string, until int64) error {
+	_, err := q.db.ExecContext(ctx, lockLogin, until, key)
+	return err
+}
+
+const clearLoginAttempts = `
+DELETE FROM login_attempts
+WHERE key = $1
+`
+
+func (q *Queries) ClearLoginAttempts(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, clearLoginAttempts, key)
+	return err
+}
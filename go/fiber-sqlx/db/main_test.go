@@ -0,0 +1,25 @@
+package db
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TestMain brings test.db up to date before the suites open it.
+func TestMain(m *testing.M) {
+	conn, err := sqlx.Connect("sqlite3", "file:test.db?_fk=1&_txlock=immediate")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := MigrateUp(context.Background(), conn); err != nil {
+		log.Fatal(err)
+	}
+	conn.Close()
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrateTestSuite struct {
+	suite.Suite
+	conn *sqlx.DB
+}
+
+func (s *MigrateTestSuite) SetupTest() {
+	path := filepath.Join(s.T().TempDir(), "migrate.db")
+	conn, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_fk=1&_txlock=immediate", path))
+	s.Require().NoError(err)
+	s.conn = conn
+}
+
+func (s *MigrateTestSuite) TearDownTest() {
+	s.conn.Close()
+}
+
+func (s *MigrateTestSuite) tableExists(name string) bool {
+	var count int
+	err := s.conn.Get(&count, "SELECT COUNT(*) FROM sqlite_master WHERE name = $1", name)
+	s.Require().NoError(err)
+	return count > 0
+}
+
+func (s *MigrateTestSuite) columnExists(table string, column string) bool {
+	var count int
+	err := s.conn.Get(&count, "SELECT COUNT(*) FROM pragma_table_info($1) WHERE name = $2", table, column)
+	s.Require().NoError(err)
+	return count > 0
+}
+
+func (s *MigrateTestSuite) TestMigrateUp() {
+	ctx := context.Background()
+	migrations, err := Migrations()
+	s.Require().NoError(err)
+	s.Require().NotEmpty(migrations)
+
+	statuses, err := MigrationStatuses(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Len(statuses, len(migrations))
+	for _, status := range statuses {
+		s.False(status.Applied)
+	}
+
+	ran, err := MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Equal(migrations, ran)
+	s.True(s.tableExists("users"))
+	s.True(s.tableExists("user_tokens"))
+	s.True(s.columnExists("users", "email_verified_at"))
+	s.True(s.tableExists("recovery_codes"))
+	s.True(s.tableExists("login_attempts"))
+
+	ran, err = MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Empty(ran)
+
+	statuses, err = MigrationStatuses(ctx, s.conn)
+	s.Require().NoError(err)
+	for _, status := range statuses {
+		s.True(status.Applied)
+	}
+}
+
+func (s *MigrateTestSuite) TestMigrateDown() {
+	ctx := context.Background()
+	migration, err := MigrateDown(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Nil(migration)
+
+	_, err = MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+
+	migrations, err := Migrations()
+	s.Require().NoError(err)
+	latest := migrations[len(migrations)-1]
+
+	migration, err = MigrateDown(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Require().NotNil(migration)
+	s.Equal(latest.Version, migration.Version)
+	s.False(s.tableExists("login_attempts"))
+	s.True(s.tableExists("recovery_codes"))
+
+	statuses, err := MigrationStatuses(ctx, s.conn)
+	s.Require().NoError(err)
+	s.False(statuses[len(statuses)-1].Applied)
+	s.True(statuses[len(statuses)-2].Applied)
+
+	ran, err := MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+	s.Require().Len(ran, 1)
+	s.Equal(latest.Version, ran[0].Version)
+}
+
+func (s *MigrateTestSuite) TestSectionsWithoutTransaction() {
+	ctx := context.Background()
+	_, err := MigrateUp(ctx, s.conn)
+	s.Require().NoError(err)
+
+	// VACUUM fails inside a transaction
+	migration := Migration{Version: "29990101000000", Name: "vacuum", Up: "VACUUM;", Down: "VACUUM;", UpNoTransaction: true}
+	applied, err := runMigration(ctx, s.conn, migration, true)
+	s.Require().NoError(err)
+	s.True(applied)
+
+	_, err = runMigration(ctx, s.conn, migration, false)
+	s.ErrorContains(err, "within a transaction")
+
+	migration.DownNoTransaction = true
+	applied, err = runMigration(ctx, s.conn, migration, false)
+	s.NoError(err)
+	s.True(applied)
+}
+
+func (s *MigrateTestSuite) TestParseMigration() {
+	migration, err := parseMigration("20230101000000_create_table_things.sql",
+		"-- migrate:up transaction:false\nCREATE TABLE things (id text);\n\n-- migrate:down\nDROP TABLE things;")
+	s.Require().NoError(err)
+	s.Equal("20230101000000", migration.Version)
+	s.Equal("create_table_things", migration.Name)
+	s.Equal("CREATE TABLE things (id text);\n\n", migration.Up)
+	s.Equal("DROP TABLE things;", migration.Down)
+	s.True(migration.UpNoTransaction)
+	s.False(migration.DownNoTransaction)
+
+	migration, err = parseMigration("20230101000000_drop_index.sql",
+		"-- migrate:up\nCREATE INDEX a ON things (id);\n-- migrate:down transaction:false\nDROP INDEX a;")
+	s.Require().NoError(err)
+	s.False(migration.UpNoTransaction)
+	s.True(migration.DownNoTransaction)
+
+	_, err = parseMigration("things.sql", "-- migrate:up\nSELECT 1;")
+	s.Error(err)
+
+	_, err = parseMigration("20230101000000_empty.sql", "-- migrate:down\nSELECT 1;")
+	s.Error(err)
+}
+
+func TestMigrateTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrateTestSuite))
+}
@@ -1,12 +0,0 @@
-CREATE TABLE IF NOT EXISTS "schema_migrations" (version varchar(255) primary key);
-CREATE TABLE users (
-  id TEXT NOT NULL PRIMARY KEY,
-  name TEXT,
-  email TEXT UNIQUE NOT NULL,
-  password TEXT NOT NULL,
-  created_at INTEGER NOT NULL DEFAULT (UNIXEPOCH()),
-  updated_at INTEGER NOT NULL DEFAULT (UNIXEPOCH())
-) WITHOUT ROWID;
--- Dbmate schema migrations
-INSERT INTO "schema_migrations" (version) VALUES
-  ('20221212073732');
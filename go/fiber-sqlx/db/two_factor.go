@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CreateRecoveryCodeParams struct {
+	ID       string `db:"id"`
+	UserID   string `db:"user_id"`
+	CodeHash string `db:"code_hash"`
+}
+
+const setTOTPSecret = `
+UPDATE users
+SET totp_secret = $1, totp_last_counter = NULL, updated_at = unixepoch()
+WHERE id = $2 AND totp_enabled_at IS NULL
+`
+
+// SetTOTPSecret stores a pending secret. It reports false when two-factor
+// authentication is already enabled for the user.
+func (q *Queries) SetTOTPSecret(ctx context.Context, id string, secret string) (bool, error) {
+	result, err := q.db.ExecContext(ctx, setTOTPSecret, secret, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+const enableTOTP = `
+UPDATE users
+SET totp_enabled_at = unixepoch(), updated_at = unixepoch()
+WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL
+`
+
+func (q *Queries) EnableTOTP(ctx context.Context, id string) (bool, error) {
+	result, err := q.db.ExecContext(ctx, enableTOTP, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+const useTOTPCounter = `
+UPDATE users
+SET totp_last_counter = $1, updated_at = unixepoch()
+WHERE id = $2 AND (totp_last_counter IS NULL OR totp_last_counter < $1)
+`
+
+// UseTOTPCounter records the period of an accepted code. It reports false
+// when a code for this or a later period was already accepted, so each code
+// works only once.
+func (q *Queries) UseTOTPCounter(ctx context.Context, id string, counter int64) (bool, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPCounter, counter, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+const createRecoveryCode = `
+INSERT INTO recovery_codes (id, user_id, code_hash, created_at)
+VALUES (:id, :user_id, :code_hash, unixepoch())
+`
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := sqlx.NamedExecContext(ctx, q.db, createRecoveryCode, arg)
+	return err
+}
+
+const deleteRecoveryCodes = `
+DELETE FROM recovery_codes
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const consumeRecoveryCode = `
+UPDATE recovery_codes
+SET used_at = unixepoch()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+`
+
+// ConsumeRecoveryCode marks a code as used. It reports false when the code
+// does not belong to the user or was already used.
+func (q *Queries) ConsumeRecoveryCode(ctx context.Context, userID string, codeHash string) (bool, error) {
+	result, err := q.db.ExecContext(ctx, consumeRecoveryCode, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// A transaction that finds the database locked is tried up to txAttempts
+// times, waiting txRetryDelay before the first retry and twice as long
+// before each one after that.
+const (
+	txAttempts   = 5
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// DBTX is implemented by both *sqlx.DB and *sqlx.Tx, so the same queries run
+// inside and outside a transaction.
+type DBTX interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// WithTx returns queries that run in tx.
+func (q *Queries) WithTx(tx *sqlx.Tx) *Queries {
+	return &Queries{
+		db: tx,
+	}
+}
+
+type txBeginner interface {
+	BeginTxx(context.Context, *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// RunInTx calls fn with queries bound to a new transaction, committing when
+// fn returns nil and rolling back otherwise. When the database is busy the
+// whole transaction is run again, so fn must not have side effects outside
+// it. Called on queries that are already in a transaction, fn joins it.
+func (q *Queries) RunInTx(ctx context.Context, fn func(*Queries) error) error {
+	conn, ok := q.db.(txBeginner)
+	if !ok {
+		return fn(q)
+	}
+
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := q.runInTx(ctx, conn, fn)
+		if err == nil || !isBusy(err) || attempt == txAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (q *Queries) runInTx(ctx context.Context, conn txBeginner, fn func(*Queries) error) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(q.WithTx(tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
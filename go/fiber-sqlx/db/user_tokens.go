@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeTwoFactorLogin    = "two_factor_login"
+)
+
+type UserToken struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Purpose   string    `json:"purpose"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	ExpiresAt int64     `json:"expires_at" db:"expires_at"`
+	UsedAt    NullInt64 `json:"-" db:"used_at"`
+	CreatedAt int64     `json:"created_at" db:"created_at"`
+}
+
+type CreateUserTokenParams struct {
+	ID        string `db:"id"`
+	UserID    string `db:"user_id"`
+	Purpose   string `db:"purpose"`
+	TokenHash string `db:"token_hash"`
+	ExpiresAt int64  `db:"expires_at"`
+}
+
+const createUserToken = `
+INSERT INTO user_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+VALUES (:id, :user_id, :purpose, :token_hash, :expires_at, unixepoch())
+RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+`
+
+func (q *Queries) CreateUserToken(ctx context.Context, arg CreateUserTokenParams) (UserToken, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, q.db, createUserToken, arg)
+	if err != nil {
+		return UserToken{}, err
+	}
+	defer rows.Close()
+
+	var i UserToken
+	for rows.Next() {
+		err = rows.StructScan(&i)
+	}
+	return i, err
+}
+
+const getUserToken = `
+SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+FROM user_tokens
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > unixepoch()
+`
+
+// GetUserToken returns an unused, unexpired token without consuming it, or an
+// empty UserToken when there is none.
+func (q *Queries) GetUserToken(ctx context.Context, purpose string, tokenHash string) (UserToken, error) {
+	var i UserToken
+	err := q.db.GetContext(ctx, &i, getUserToken, tokenHash, purpose)
+	if err == sql.ErrNoRows {
+		return UserToken{}, nil
+	}
+	return i, err
+}
+
+const consumeUserToken = `
+UPDATE user_tokens
+SET used_at = unixepoch()
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > unixepoch()
+RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+`
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it.
+// An empty UserToken is returned when no such token exists, so every token
+// can be consumed at most once.
+func (q *Queries) ConsumeUserToken(ctx context.Context, purpose string, tokenHash string) (UserToken, error) {
+	var i UserToken
+	err := q.db.GetContext(ctx, &i, consumeUserToken, tokenHash, purpose)
+	if err == sql.ErrNoRows {
+		return UserToken{}, nil
+	}
+	return i, err
+}
+
+const deleteUserTokens = `
+DELETE FROM user_tokens
+WHERE user_id = $1 AND purpose = $2
+`
+
+func (q *Queries) DeleteUserTokens(ctx context.Context, userID string, purpose string) error {
+	_, err := q.db.ExecContext(ctx, deleteUserTokens, userID, purpose)
+	return err
+}
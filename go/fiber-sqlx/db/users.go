@@ -3,22 +3,27 @@ package db
 import (
 	"context"
 	"database/sql"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type User struct {
-	ID        string     `json:"id"`
-	Name      NullString `json:"name"`
-	Email     string     `json:"email"`
-	Password  string     `json:"-"`
-	CreatedAt int64      `json:"created_at" db:"created_at"`
-	UpdatedAt int64      `json:"updated_at" db:"updated_at"`
+	ID              string     `json:"id"`
+	Name            NullString `json:"name"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	CreatedAt       int64      `json:"created_at" db:"created_at"`
+	UpdatedAt       int64      `json:"updated_at" db:"updated_at"`
+	EmailVerifiedAt NullInt64  `json:"email_verified_at" db:"email_verified_at"`
+	TOTPSecret      NullString `json:"-" db:"totp_secret"`
+	TOTPEnabledAt   NullInt64  `json:"totp_enabled_at" db:"totp_enabled_at"`
 }
 
 type CreateUserParams struct {
 	ID       string     `json:"id" validate:"required,min=1,max=36"`
 	Name     NullString `json:"name"`
 	Email    string     `json:"email" validate:"required,email"`
-	Password string     `json:"password" validate:"required,min=8,max=15"`
+	Password string     `json:"password" validate:"required,password=Email Name"`
 }
 
 type UpdateUserParams struct {
@@ -28,13 +33,14 @@ type UpdateUserParams struct {
 }
 
 const getUsers = `
-SELECT id, name, email, password, created_at, updated_at
+SELECT id, name, email, password, created_at, updated_at, email_verified_at, totp_secret, totp_enabled_at
 FROM users
 `
 
-func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
-	var users []User
-	err := q.db.SelectContext(ctx, &users, getUsers)
+func (q *Queries) GetUsers(ctx context.Context, arg ListQuery) ([]User, error) {
+	query, args := arg.build(getUsers)
+	users := []User{}
+	err := q.db.SelectContext(ctx, &users, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -44,11 +50,11 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 const createUser = `
 INSERT INTO users (id, name, email, password, created_at, updated_at)
 VALUES (:id, :name, :email, :password, unixepoch(), unixepoch())
-RETURNING id, name, email, password, created_at, updated_at
+RETURNING id, name, email, password, created_at, updated_at, email_verified_at, totp_secret, totp_enabled_at
 `
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (*User, error) {
-	rows, err := q.db.NamedQueryContext(ctx, createUser, arg)
+	rows, err := sqlx.NamedQueryContext(ctx, q.db, createUser, arg)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +67,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (*User,
 }
 
 const getUserByEmail = `
-SELECT id, name, email, password, created_at, updated_at
+SELECT id, name, email, password, created_at, updated_at, email_verified_at, totp_secret, totp_enabled_at
 FROM users
 WHERE email = $1
 LIMIT 1
@@ -77,7 +83,7 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 }
 
 const getUserById = `
-SELECT id, name, email, password, created_at, updated_at
+SELECT id, name, email, password, created_at, updated_at, email_verified_at, totp_secret, totp_enabled_at
 FROM users
 WHERE id = $1
 LIMIT 1
@@ -96,11 +102,11 @@ const updateUser = `
 UPDATE users
 SET name = coalesce(:name, name), password = coalesce(:password, password), updated_at = unixepoch()
 WHERE id = :id 
-RETURNING id, name, email, password, created_at, updated_at
+RETURNING id, name, email, password, created_at, updated_at, email_verified_at, totp_secret, totp_enabled_at
 `
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	rows, err := q.db.NamedQueryContext(ctx, updateUser, arg)
+	rows, err := sqlx.NamedQueryContext(ctx, q.db, updateUser, arg)
 	if err != nil {
 		return User{}, err
 	}
@@ -122,3 +128,16 @@ func (q *Queries) DeleteUser(ctx context.Context, id string) error {
 	_, err := q.db.ExecContext(ctx, deleteUser, id)
 	return err
 }
+
+const markEmailVerified = `
+UPDATE users
+SET email_verified_at = coalesce(email_verified_at, unixepoch()), updated_at = unixepoch()
+WHERE id = $1
+RETURNING id, name, email, password, created_at, updated_at, email_verified_at, totp_secret, totp_enabled_at
+`
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, id string) (User, error) {
+	var i User
+	err := q.db.GetContext(ctx, &i, markEmailVerified, id)
+	return i, err
+}
@@ -83,11 +83,24 @@ func (s *UsersTestSuite) TestCreateUser() {
 }
 
 func (s *UsersTestSuite) TestGetUsers() {
-	users, err := s.q.GetUsers(context.Background())
+	users, err := s.q.GetUsers(context.Background(), ListQuery{Limit: 10})
 	s.NoError(err)
 	s.Len(users, 4)
 }
 
+func (s *UsersTestSuite) TestGetUsersWithListQuery() {
+	users, err := s.q.GetUsers(context.Background(), ListQuery{
+		Conditions: []string{"id > ?", "email LIKE ?"},
+		Args:       []interface{}{"1", "%smith%"},
+		OrderBy:    []string{"id DESC"},
+		Limit:      2,
+	})
+	s.NoError(err)
+	s.Len(users, 2)
+	s.Equal("3", users[0].ID)
+	s.Equal("2", users[1].ID)
+}
+
 func (s *UsersTestSuite) TestGetUserByEmail() {
 	email := "johndoe@example.com"
 	user, err := s.q.GetUserByEmail(context.Background(), email)
@@ -161,6 +174,19 @@ func (s *UsersTestSuite) TestPartialUpdates() {
 	s.True(isPasswordSame("password", updatedUser.Password))
 }
 
+func (s *UsersTestSuite) TestMarkEmailVerified() {
+	id := "3"
+
+	user, err := s.q.MarkEmailVerified(context.Background(), id)
+	s.NoError(err)
+	s.True(user.EmailVerifiedAt.Valid)
+
+	verifiedAt := user.EmailVerifiedAt.Int64
+	user, err = s.q.MarkEmailVerified(context.Background(), id)
+	s.NoError(err)
+	s.Equal(verifiedAt, user.EmailVerifiedAt.Int64, "Verification time is kept")
+}
+
 func TestUsers(t *testing.T) {
 	suite.Run(t, new(UsersTestSuite))
 }
@@ -175,6 +201,7 @@ func (s *UsersTestSuite) insertUser(userParams CreateUserParams) (*User, error)
 	s.Assert().Equal(userParams.Name.String, user.Name.String)
 	s.Assert().NotEqual(0, user.CreatedAt)
 	s.Assert().NotEqual(0, user.UpdatedAt)
+	s.Assert().False(user.EmailVerifiedAt.Valid)
 
 	return user, err
 }
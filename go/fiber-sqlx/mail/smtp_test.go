@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"strings"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/mail/mailtest"
+	"github.com/stretchr/testify/suite"
+)
+
+type SMTPTestSuite struct {
+	suite.Suite
+	server *mailtest.Server
+	mailer Mailer
+}
+
+func (s *SMTPTestSuite) SetupSuite() {
+	s.server = mailtest.NewServer()
+	s.mailer = NewSMTPMailer(SMTPConfig{
+		Host:     s.server.Host(),
+		Port:     s.server.Port(),
+		Username: "user",
+		Password: "secret",
+		From:     "no-reply@example.com",
+	})
+}
+
+func (s *SMTPTestSuite) TearDownSuite() {
+	s.server.Close()
+}
+
+func (s *SMTPTestSuite) TestSendTextMessage() {
+	err := s.mailer.Send(context.Background(), Message{
+		To:      "janedoe@example.com",
+		Subject: "Hello",
+		Text:    "Hello Jane",
+	})
+	s.NoError(err)
+
+	received := s.lastMessage()
+	s.Equal("no-reply@example.com", received.From)
+	s.Equal([]string{"janedoe@example.com"}, received.To)
+
+	msg, err := netmail.ReadMessage(strings.NewReader(received.Data))
+	s.NoError(err)
+	s.Equal("Hello", msg.Header.Get("Subject"))
+
+	body, err := io.ReadAll(msg.Body)
+	s.NoError(err)
+	s.Contains(string(body), "Hello Jane")
+}
+
+func (s *SMTPTestSuite) TestSendTemplateMessage() {
+	msg, err := NewMessage("johndoe@example.com", "Verify your email address", "email_verification", map[string]string{
+		"Link":      "http://localhost/verify?token=abc&x=<y>",
+		"ExpiresIn": "24h0m0s",
+	})
+	s.NoError(err)
+	s.Contains(msg.Text, "http://localhost/verify?token=abc&x=<y>")
+	s.Contains(msg.HTML, "http://localhost/verify?token=abc&amp;x=%3cy%3e", "Data is escaped in HTML")
+
+	s.NoError(s.mailer.Send(context.Background(), msg))
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(s.lastMessage().Data))
+	s.NoError(err)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	s.NoError(err)
+	s.Equal("multipart/alternative", mediaType)
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var contentTypes []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		s.NoError(err)
+		contentTypes = append(contentTypes, part.Header.Get("Content-Type"))
+	}
+	s.Equal([]string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}, contentTypes)
+}
+
+func TestSMTP(t *testing.T) {
+	suite.Run(t, new(SMTPTestSuite))
+}
+
+func (s *SMTPTestSuite) lastMessage() mailtest.Message {
+	s.T().Helper()
+	messages := s.server.Messages()
+	s.NotEmpty(messages)
+	return messages[len(messages)-1]
+}
@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+type mailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates map[string]*mailTemplate
+
+func init() {
+	var err error
+	templates, err = loadTemplates(templateFS)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// loadTemplates pairs every templates/<name>.txt with an optional
+// templates/<name>.html. HTML bodies go through html/template so that data
+// is escaped.
+func loadTemplates(fsys fs.FS) (map[string]*mailTemplate, error) {
+	files, err := fs.Glob(fsys, "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]*mailTemplate, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".txt")
+		t := &mailTemplate{}
+
+		t.text, err = texttemplate.ParseFS(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		htmlFile := path.Join("templates", name+".html")
+		if _, err := fs.Stat(fsys, htmlFile); err == nil {
+			t.html, err = htmltemplate.ParseFS(fsys, htmlFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		loaded[name] = t
+	}
+
+	return loaded, nil
+}
+
+// NewMessage renders the named template with data into a message for to.
+func NewMessage(to string, subject string, name string, data interface{}) (Message, error) {
+	t, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("mail: unknown template %q", name)
+	}
+
+	msg := Message{
+		To:      to,
+		Subject: subject,
+	}
+
+	var text bytes.Buffer
+	if err := t.text.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+	msg.Text = text.String()
+
+	if t.html != nil {
+		var html bytes.Buffer
+		if err := t.html.Execute(&html, data); err != nil {
+			return Message{}, err
+		}
+		msg.HTML = html.String()
+	}
+
+	return msg, nil
+}
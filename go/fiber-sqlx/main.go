@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/mail"
 	"github.com/ashwins93/fiber-sql/routes"
 	"github.com/ashwins93/fiber-sql/utils"
 	"github.com/goccy/go-json"
@@ -15,6 +21,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
@@ -23,23 +30,78 @@ func main() {
 		log.Fatal("GO_DB_URL is not set")
 	}
 
-	conn, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_fk=1", dbUrl))
+	conn, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?_fk=1&_txlock=immediate", dbUrl))
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer conn.Close()
 
+	if len(os.Args) > 1 {
+		if err := migrateCommand(conn, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	jwtSecret := os.Getenv("GO_JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("GO_JWT_SECRET is not set")
+	}
+
+	ran, err := db.MigrateUp(context.Background(), conn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, migration := range ran {
+		log.Printf("applied migration %s_%s", migration.Version, migration.Name)
+	}
+
+	proxyHeader, trustedProxies, err := newProxyConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	queries := db.NewDb(conn)
 	app := fiber.New(fiber.Config{
 		JSONEncoder:  json.Marshal,
 		JSONDecoder:  json.Unmarshal,
 		ErrorHandler: errorHandler,
+		// Set when running behind a reverse proxy so login throttling sees client IPs.
+		ProxyHeader: proxyHeader,
+		// The proxy header is only read from these addresses, otherwise any
+		// client could pick the IP it is throttled by.
+		EnableTrustedProxyCheck: proxyHeader != "",
+		TrustedProxies:          trustedProxies,
 	})
 	app.Use(recover.New())
 	app.Use(logger.New())
 	idGen := utils.NewNanoIDGenerator(21)
+	tokens := utils.NewJWTMaker(jwtSecret)
+	hasher, err := newPasswordHasher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy, err := newPasswordPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.SetPasswordPolicy(policy)
+	mailer, err := newMailer()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	publicURL, err := newPublicURL()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config := routes.Config{
+		RequireVerifiedEmail: os.Getenv("GO_REQUIRE_VERIFIED_EMAIL") == "true",
+		PublicURL:            publicURL,
+	}
 
-	server := routes.NewService(queries, app, idGen)
+	server := routes.NewService(queries, app, idGen, tokens, hasher, mailer, config)
 	server.SetupV1Routes()
 
 	app.Hooks().OnShutdown(func() error {
@@ -52,6 +114,164 @@ func main() {
 
 }
 
+// newPasswordHasher picks the algorithm from GO_PASSWORD_HASHER. Hashes made
+// by the other algorithm still verify and are upgraded on the next login.
+func newPasswordHasher() (utils.PasswordHasher, error) {
+	switch algorithm := os.Getenv("GO_PASSWORD_HASHER"); algorithm {
+	case "bcrypt":
+		cost, err := envInt("GO_BCRYPT_COST", bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		return utils.NewBcryptHasher(cost), nil
+	case "", "argon2id":
+		params := utils.DefaultArgon2idParams
+		memory, err := envInt("GO_ARGON2_MEMORY", int(params.Memory))
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := envInt("GO_ARGON2_ITERATIONS", int(params.Iterations))
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := envInt("GO_ARGON2_PARALLELISM", int(params.Parallelism))
+		if err != nil {
+			return nil, err
+		}
+		params.Memory = uint32(memory)
+		params.Iterations = uint32(iterations)
+		params.Parallelism = uint8(parallelism)
+		return utils.NewArgon2idHasher(params), nil
+	default:
+		return nil, fmt.Errorf("unknown GO_PASSWORD_HASHER %q", algorithm)
+	}
+}
+
+// newPasswordPolicy adjusts the default policy from the environment.
+// GO_PASSWORD_BREACHED_DIR points at a local k-anonymity range dataset.
+func newPasswordPolicy() (utils.PasswordPolicy, error) {
+	policy := utils.DefaultPasswordPolicy
+
+	minLength, err := envInt("GO_PASSWORD_MIN_LENGTH", policy.MinLength)
+	if err != nil {
+		return policy, err
+	}
+	maxLength, err := envInt("GO_PASSWORD_MAX_LENGTH", policy.MaxLength)
+	if err != nil {
+		return policy, err
+	}
+	minCharClasses, err := envInt("GO_PASSWORD_MIN_CHAR_CLASSES", policy.MinCharClasses)
+	if err != nil {
+		return policy, err
+	}
+	minEntropy, err := envInt("GO_PASSWORD_MIN_ENTROPY", int(policy.MinEntropy))
+	if err != nil {
+		return policy, err
+	}
+
+	policy.MinLength = minLength
+	policy.MaxLength = maxLength
+	policy.MinCharClasses = minCharClasses
+	policy.MinEntropy = float64(minEntropy)
+	policy.DisallowUserInfo = os.Getenv("GO_PASSWORD_ALLOW_USER_INFO") != "true"
+
+	// bcrypt ignores everything past 72 bytes, which a password of 64
+	// characters outside ASCII can pass.
+	if os.Getenv("GO_PASSWORD_HASHER") == "bcrypt" {
+		policy.MaxBytes = utils.BcryptMaxPasswordBytes
+	}
+
+	if dir := os.Getenv("GO_PASSWORD_BREACHED_DIR"); dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return policy, err
+		}
+		policy.Breached = utils.NewBreachedPasswordDir(dir)
+	}
+
+	return policy, nil
+}
+
+func envInt(name string, fallback int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s is invalid: %q", name, value)
+	}
+	return n, nil
+}
+
+// newProxyConfig reads GO_PROXY_HEADER and GO_TRUSTED_PROXIES, a comma
+// separated list of the IPs and CIDR ranges of the proxies allowed to set it.
+func newProxyConfig() (string, []string, error) {
+	header := os.Getenv("GO_PROXY_HEADER")
+	proxies := []string{}
+
+	for _, proxy := range strings.Split(os.Getenv("GO_TRUSTED_PROXIES"), ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return "", nil, fmt.Errorf("GO_TRUSTED_PROXIES is invalid: %q", proxy)
+			}
+		}
+		proxies = append(proxies, proxy)
+	}
+
+	if header != "" && len(proxies) == 0 {
+		return "", nil, errors.New("GO_TRUSTED_PROXIES must be set when GO_PROXY_HEADER is")
+	}
+	return header, proxies, nil
+}
+
+// newPublicURL reads GO_PUBLIC_URL, the address users reach the API at, which
+// links in emails are built from.
+func newPublicURL() (string, error) {
+	value := os.Getenv("GO_PUBLIC_URL")
+	if value == "" {
+		return "http://localhost:3000", nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("GO_PUBLIC_URL is invalid: %q", value)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+func newMailer() (mail.Mailer, error) {
+	from := os.Getenv("GO_MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := os.Getenv("GO_MAIL_DRIVER"); driver {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("GO_SMTP_PORT"))
+		if err != nil {
+			return nil, fmt.Errorf("GO_SMTP_PORT is invalid: %w", err)
+		}
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     os.Getenv("GO_SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("GO_SMTP_USERNAME"),
+			Password: os.Getenv("GO_SMTP_PASSWORD"),
+			From:     from,
+		}), nil
+	case "file":
+		return mail.NewFileMailer(os.Getenv("GO_MAIL_DIR"), from), nil
+	case "", "log":
+		return mail.NewLogMailer(os.Stdout, from), nil
+	default:
+		return nil, fmt.Errorf("unknown GO_MAIL_DRIVER %q", driver)
+	}
+}
+
 func errorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
 	message := "Something went wrong"
@@ -66,3 +286,49 @@ func errorHandler(c *fiber.Ctx, err error) error {
 		"message": message,
 	})
 }
+
+// migrateCommand runs "migrate up", "migrate down" or "migrate status".
+func migrateCommand(conn *sqlx.DB, args []string) error {
+	if len(args) != 2 || args[0] != "migrate" {
+		return errors.New("usage: www [migrate up|down|status]")
+	}
+
+	ctx := context.Background()
+	switch args[1] {
+	case "up":
+		ran, err := db.MigrateUp(ctx, conn)
+		for _, migration := range ran {
+			fmt.Printf("applied %s_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		return err
+	case "down":
+		migration, err := db.MigrateDown(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if migration == nil {
+			fmt.Println("nothing to roll back")
+		} else {
+			fmt.Printf("rolled back %s_%s\n", migration.Version, migration.Name)
+		}
+		return nil
+	case "status":
+		statuses, err := db.MigrationStatuses(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%-8s %s_%s\n", state, status.Version, status.Name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[1])
+	}
+}
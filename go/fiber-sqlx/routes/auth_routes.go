@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const accessTokenDuration = 15 * time.Minute
+
+type LoginParams struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (s *Service) setupAuthRoutes(router fiber.Router) {
+	router.Post("/login", s.loginHandler)
+}
+
+func (s *Service) loginHandler(c *fiber.Ctx) error {
+	loginParams := LoginParams{}
+
+	if err := c.BodyParser(&loginParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(loginParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	if err := s.checkLoginThrottle(c, loginParams.Email); err != nil {
+		return err
+	}
+
+	user, err := s.queries.GetUserByEmail(c.Context(), loginParams.Email)
+	if err != nil {
+		return err
+	}
+
+	valid, needsRehash := false, false
+	if user.Email != "" {
+		valid, needsRehash, err = s.hasher.Verify(loginParams.Password, user.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !valid {
+		if err := s.recordLoginFailure(c, loginParams.Email); err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid email or password",
+		})
+	}
+
+	// Upgrade hashes made with an older algorithm or weaker parameters
+	// while the plain password is at hand.
+	if needsRehash {
+		if err := s.setPassword(c, user.ID, loginParams.Password); err != nil {
+			return err
+		}
+	}
+
+	if s.config.RequireVerifiedEmail && !user.EmailVerifiedAt.Valid {
+		return c.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"message": "Email address is not verified",
+		})
+	}
+
+	if user.TOTPEnabledAt.Valid {
+		challenge, err := s.createTwoFactorChallenge(c, user.ID)
+		if err != nil {
+			return err
+		}
+		return c.JSON(challenge)
+	}
+
+	if err := s.clearLoginFailures(c, user.Email); err != nil {
+		return err
+	}
+
+	tokens, err := s.issueTokens(user)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(tokens)
+}
+
+func (s *Service) issueTokens(user db.User) (tokenResponse, error) {
+	accessToken, err := s.tokens.CreateToken(user.ID, accessTokenDuration)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	return tokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(accessTokenDuration.Seconds()),
+	}, nil
+}
@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthRoutesTestSuite struct {
+	suite.Suite
+	q      *db.Queries
+	conn   *sqlx.DB
+	app    *fiber.App
+	tokens utils.TokenMaker
+}
+
+func (s *AuthRoutesTestSuite) SetupSuite() {
+	conn, err := sqlx.Connect("sqlite3", "file:../db/test.db?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	idGen := utils.NewNanoIDGenerator(21)
+	s.tokens = utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, s.tokens, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+}
+
+func (s *AuthRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+}
+
+func (s *AuthRoutesTestSuite) TestLogin() {
+	req := s.loginRequest(`{"email": "johndoe@example.com", "password": "password"}`)
+
+	var tokens tokenResponse
+	s.checkReqStatus(req, fiber.StatusOK, &tokens)
+
+	s.Equal("Bearer", tokens.TokenType)
+	s.NotEmpty(tokens.AccessToken)
+
+	claims, err := s.tokens.VerifyToken(tokens.AccessToken)
+	s.NoError(err)
+	s.Equal("1", claims.Subject)
+}
+
+func (s *AuthRoutesTestSuite) TestLoginWithWrongPassword() {
+	req := s.loginRequest(`{"email": "johndoe@example.com", "password": "wrongpassword"}`)
+
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestLoginWithUnknownEmail() {
+	req := s.loginRequest(`{"email": "nobody@example.com", "password": "password"}`)
+
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *AuthRoutesTestSuite) TestLoginWithInvalidBody() {
+	req := s.loginRequest(`{"email": "johndoe"}`)
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(req, fiber.StatusBadRequest, &errors)
+
+	s.Equal(2, len(errors))
+}
+
+func (s *AuthRoutesTestSuite) TestLoginRehashesOutdatedHash() {
+	req := s.loginRequest(`{"email": "ashwin@example.com", "password": "password"}`)
+	s.checkReqStatus(req, fiber.StatusOK, nil)
+
+	user, err := s.q.GetUserByEmail(context.Background(), "ashwin@example.com")
+	s.NoError(err)
+	s.True(strings.HasPrefix(user.Password, "$argon2id$"), "The bcrypt seed hash is upgraded")
+}
+
+func TestAuthRoutes(t *testing.T) {
+	suite.Run(t, new(AuthRoutesTestSuite))
+}
+
+func (s *AuthRoutesTestSuite) loginRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/api/v1/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *AuthRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TestMain brings ../db/test.db up to date before the suites open it.
+func TestMain(m *testing.M) {
+	conn, err := sqlx.Connect("sqlite3", "file:../db/test.db?_fk=1&_txlock=immediate")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := db.MigrateUp(context.Background(), conn); err != nil {
+		log.Fatal(err)
+	}
+	conn.Close()
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const userIDKey = "userID"
+
+// requireAuth rejects requests without a valid bearer access token and stores
+// the caller's user ID on the context for the handlers that follow.
+func (s *Service) requireAuth(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderAuthorization)
+	if !strings.HasPrefix(header, "Bearer ") {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Missing access token",
+		})
+	}
+
+	claims, err := s.tokens.VerifyToken(strings.TrimPrefix(header, "Bearer "))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid access token",
+		})
+	}
+
+	c.Locals(userIDKey, claims.Subject)
+	return c.Next()
+}
+
+// currentUserID returns the ID of the authenticated caller, or an empty
+// string when the route is not behind requireAuth.
+func currentUserID(c *fiber.Ctx) string {
+	id, _ := c.Locals(userIDKey).(string)
+	return id
+}
@@ -0,0 +1,165 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type PasswordRoutesTestSuite struct {
+	suite.Suite
+	q      *db.Queries
+	conn   *sqlx.DB
+	app    *fiber.App
+	mailer *recordingMailer
+}
+
+func (s *PasswordRoutesTestSuite) SetupSuite() {
+	conn, err := sqlx.Connect("sqlite3", "file:../db/test.db?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	s.mailer = &recordingMailer{}
+	idGen := utils.NewNanoIDGenerator(21)
+
+	service := NewService(s.q, s.app, idGen, utils.NewJWTMaker(testJWTSecret), testHasher, s.mailer, Config{})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+}
+
+func (s *PasswordRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Close()
+}
+
+func (s *PasswordRoutesTestSuite) TestForgotPasswordUnknownEmail() {
+	before := len(s.mailer.messages)
+
+	req := s.jsonRequest("/api/v1/auth/password/forgot", ForgotPasswordParams{Email: "nobody@example.com"})
+	s.checkReqStatus(req, fiber.StatusAccepted, nil)
+
+	s.Len(s.mailer.messages, before, "No email is sent for unknown addresses")
+}
+
+func (s *PasswordRoutesTestSuite) TestResetPassword() {
+	token := s.requestReset("janedoe@example.com")
+
+	req := s.jsonRequest("/api/v1/auth/password/reset", ResetPasswordParams{Token: token, Password: "newpassword"})
+	s.checkReqStatus(req, fiber.StatusNoContent, nil)
+
+	user, err := s.q.GetUserByEmail(context.Background(), "janedoe@example.com")
+	s.NoError(err)
+	ok, _, err := testHasher.Verify("newpassword", user.Password)
+	s.NoError(err)
+	s.True(ok)
+}
+
+func (s *PasswordRoutesTestSuite) TestResetTokenIsSingleUse() {
+	token := s.requestReset("ashwin@example.com")
+
+	req := s.jsonRequest("/api/v1/auth/password/reset", ResetPasswordParams{Token: token, Password: "newpassword"})
+	s.checkReqStatus(req, fiber.StatusNoContent, nil)
+
+	req = s.jsonRequest("/api/v1/auth/password/reset", ResetPasswordParams{Token: token, Password: "otherpassword"})
+	s.checkReqStatus(req, fiber.StatusBadRequest, nil)
+}
+
+func (s *PasswordRoutesTestSuite) TestNewResetTokenReplacesOldOne() {
+	first := s.requestReset("johndoe@example.com")
+	second := s.requestReset("johndoe@example.com")
+
+	req := s.jsonRequest("/api/v1/auth/password/reset", ResetPasswordParams{Token: first, Password: "newpassword"})
+	s.checkReqStatus(req, fiber.StatusBadRequest, nil)
+
+	req = s.jsonRequest("/api/v1/auth/password/reset", ResetPasswordParams{Token: second, Password: "newpassword"})
+	s.checkReqStatus(req, fiber.StatusNoContent, nil)
+}
+
+func (s *PasswordRoutesTestSuite) TestResetPasswordWithInvalidBody() {
+	req := s.jsonRequest("/api/v1/auth/password/reset", ResetPasswordParams{Token: "token", Password: "short"})
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(req, fiber.StatusBadRequest, &errors)
+
+	s.Equal(1, len(errors))
+	s.Contains(strings.ToLower(errors[0].FailedField), "password")
+}
+
+func (s *PasswordRoutesTestSuite) TestResetPasswordContainingNameKeepsToken() {
+	token := s.requestReset("janedoe@example.com")
+
+	req := s.jsonRequest("/api/v1/auth/password/reset", ResetPasswordParams{Token: token, Password: "janespassword"})
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(req, fiber.StatusBadRequest, &errors)
+
+	s.Equal(1, len(errors))
+	s.Equal(utils.PasswordRuleUserInfo, errors[0].Value)
+
+	req = s.jsonRequest("/api/v1/auth/password/reset", ResetPasswordParams{Token: token, Password: "newpassword"})
+	s.checkReqStatus(req, fiber.StatusNoContent, nil)
+}
+
+func TestPasswordRoutes(t *testing.T) {
+	suite.Run(t, new(PasswordRoutesTestSuite))
+}
+
+func (s *PasswordRoutesTestSuite) requestReset(email string) string {
+	s.T().Helper()
+	req := s.jsonRequest("/api/v1/auth/password/forgot", ForgotPasswordParams{Email: email})
+	s.checkReqStatus(req, fiber.StatusAccepted, nil)
+
+	msg, ok := s.mailer.last()
+	s.True(ok)
+	s.Equal(email, msg.To)
+
+	// the token is the last line of the message body
+	lines := strings.Split(strings.TrimSpace(msg.Text), "\n")
+	return lines[len(lines)-1]
+}
+
+func (s *PasswordRoutesTestSuite) jsonRequest(path string, params interface{}) *http.Request {
+	body, _ := json.Marshal(params)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *PasswordRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
@@ -2,24 +2,44 @@ package routes
 
 import (
 	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/mail"
 	"github.com/ashwins93/fiber-sql/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
+type Config struct {
+	// RequireVerifiedEmail blocks login until the user has verified their email address.
+	RequireVerifiedEmail bool
+	// PublicURL is the scheme and host that links in emails point at. Links are
+	// never built from the request's Host header, which the client controls.
+	PublicURL string
+}
+
 type Service struct {
 	queries *db.Queries
 	app     *fiber.App
 	idGen   utils.IDGenerator
+	tokens  utils.TokenMaker
+	hasher  utils.PasswordHasher
+	mailer  mail.Mailer
+	config  Config
 }
 
-func NewService(queries *db.Queries, app *fiber.App, idGen utils.IDGenerator) *Service {
-	return &Service{queries, app, idGen}
+func NewService(queries *db.Queries, app *fiber.App, idGen utils.IDGenerator, tokens utils.TokenMaker, hasher utils.PasswordHasher, mailer mail.Mailer, config Config) *Service {
+	return &Service{queries, app, idGen, tokens, hasher, mailer, config}
 }
 
 func (s *Service) SetupV1Routes() {
 	v1Routes := s.app.Group("/api/v1")
 
+	authRouter := v1Routes.Group("/auth")
+	passwordRouter := authRouter.Group("/password")
+	twoFactorRouter := authRouter.Group("/2fa")
 	userRouter := v1Routes.Group("/users")
 
+	s.setupAuthRoutes(authRouter)
+	s.setupPasswordRoutes(passwordRouter)
+	s.setupVerificationRoutes(authRouter)
+	s.setupTwoFactorRoutes(twoFactorRouter)
 	s.setupUserRoutes(userRouter)
 }
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	maxUserLoginFailures = 5
+	maxIPLoginFailures   = 20
+	loginFailureWindow   = time.Hour
+	minLockoutDuration   = time.Minute
+	maxLockoutDuration   = time.Hour
+)
+
+type throttleKey struct {
+	key   string
+	limit int64
+}
+
+func loginThrottleKeys(c *fiber.Ctx, email string) []throttleKey {
+	return []throttleKey{
+		{"user:" + strings.ToLower(email), maxUserLoginFailures},
+		{"ip:" + c.IP(), maxIPLoginFailures},
+	}
+}
+
+// lockoutDuration doubles the lockout for every failure past the limit.
+func lockoutDuration(failures int64, limit int64) time.Duration {
+	if failures < limit {
+		return 0
+	}
+
+	d := minLockoutDuration
+	for i := limit; i < failures && d < maxLockoutDuration; i++ {
+		d *= 2
+	}
+	if d > maxLockoutDuration {
+		d = maxLockoutDuration
+	}
+	return d
+}
+
+// checkLoginThrottle fails with 429 while the account or the client IP is
+// locked out.
+func (s *Service) checkLoginThrottle(c *fiber.Ctx, email string) error {
+	now := time.Now().Unix()
+
+	for _, k := range loginThrottleKeys(c, email) {
+		attempt, err := s.queries.GetLoginAttempt(c.Context(), k.key)
+		if err != nil {
+			return err
+		}
+
+		if attempt.LockedUntil.Valid && attempt.LockedUntil.Int64 > now {
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(attempt.LockedUntil.Int64-now, 10))
+			return fiber.NewError(fiber.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) recordLoginFailure(c *fiber.Ctx, email string) error {
+	now := time.Now()
+
+	for _, k := range loginThrottleKeys(c, email) {
+		attempt, err := s.queries.RecordLoginFailure(c.Context(), k.key, now.Add(-loginFailureWindow).Unix())
+		if err != nil {
+			return err
+		}
+
+		if d := lockoutDuration(attempt.Failures, k.limit); d > 0 {
+			if err := s.queries.LockLogin(c.Context(), k.key, now.Add(d).Unix()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// clearLoginFailures resets the account after a successful login. The IP
+// tally is left alone so one valid account cannot unlock a noisy client.
+func (s *Service) clearLoginFailures(c *fiber.Ctx, email string) error {
+	return s.queries.ClearLoginAttempts(c.Context(), loginThrottleKeys(c, email)[0].key)
+}
@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type ThrottleTestSuite struct {
+	suite.Suite
+	q    *db.Queries
+	conn *sqlx.DB
+	app  *fiber.App
+}
+
+func (s *ThrottleTestSuite) SetupSuite() {
+	conn, err := sqlx.Connect("sqlite3", "file:../db/test.db?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+		ProxyHeader: fiber.HeaderXForwardedFor,
+	})
+	idGen := utils.NewNanoIDGenerator(21)
+	tokens := utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, tokens, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+}
+
+func (s *ThrottleTestSuite) SetupTest() {
+	s.conn.Exec("DELETE FROM login_attempts")
+}
+
+func (s *ThrottleTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+}
+
+func (s *ThrottleTestSuite) TestLockoutAfterRepeatedFailures() {
+	for i := 0; i < maxUserLoginFailures; i++ {
+		s.checkReqStatus(s.loginRequest("johndoe@example.com", "wrongpassword", "10.0.0.1"), fiber.StatusUnauthorized)
+	}
+
+	resp := s.checkReqStatus(s.loginRequest("johndoe@example.com", "password", "10.0.0.1"), fiber.StatusTooManyRequests)
+	s.NotEmpty(resp.Header.Get(fiber.HeaderRetryAfter))
+
+	// the lock is on the account, not just the client
+	s.checkReqStatus(s.loginRequest("JohnDoe@example.com", "password", "10.0.0.2"), fiber.StatusTooManyRequests)
+
+	// other accounts are unaffected
+	s.checkReqStatus(s.loginRequest("janedoe@example.com", "password", "10.0.0.2"), fiber.StatusOK)
+}
+
+func (s *ThrottleTestSuite) TestLockoutExpires() {
+	for i := 0; i < maxUserLoginFailures; i++ {
+		s.checkReqStatus(s.loginRequest("janedoe@example.com", "wrongpassword", "10.0.0.3"), fiber.StatusUnauthorized)
+	}
+	s.checkReqStatus(s.loginRequest("janedoe@example.com", "password", "10.0.0.3"), fiber.StatusTooManyRequests)
+
+	s.conn.Exec("UPDATE login_attempts SET locked_until = unixepoch() - 1")
+
+	s.checkReqStatus(s.loginRequest("janedoe@example.com", "password", "10.0.0.3"), fiber.StatusOK)
+
+	attempt, err := s.q.GetLoginAttempt(context.Background(), "user:janedoe@example.com")
+	s.NoError(err)
+	s.Equal("", attempt.Key, "A successful login clears the account's failures")
+}
+
+func (s *ThrottleTestSuite) TestLockoutPerIP() {
+	for i := 0; i < maxIPLoginFailures; i++ {
+		email := fmt.Sprintf("nobody%d@example.com", i)
+		s.checkReqStatus(s.loginRequest(email, "wrongpassword", "10.0.0.4"), fiber.StatusUnauthorized)
+	}
+
+	s.checkReqStatus(s.loginRequest("ashwin@example.com", "password", "10.0.0.4"), fiber.StatusTooManyRequests)
+	s.checkReqStatus(s.loginRequest("ashwin@example.com", "password", "10.0.0.5"), fiber.StatusOK)
+}
+
+func (s *ThrottleTestSuite) TestLockoutDuration() {
+	s.Equal(time.Duration(0), lockoutDuration(4, 5))
+	s.Equal(time.Minute, lockoutDuration(5, 5))
+	s.Equal(2*time.Minute, lockoutDuration(6, 5))
+	s.Equal(4*time.Minute, lockoutDuration(7, 5))
+	s.Equal(maxLockoutDuration, lockoutDuration(50, 5))
+}
+
+func TestThrottle(t *testing.T) {
+	suite.Run(t, new(ThrottleTestSuite))
+}
+
+func (s *ThrottleTestSuite) loginRequest(email, password, ip string) *http.Request {
+	body, _ := json.Marshal(LoginParams{Email: email, Password: password})
+	req := httptest.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fiber.HeaderXForwardedFor, ip)
+	return req
+}
+
+func (s *ThrottleTestSuite) checkReqStatus(req *http.Request, expectedStatus int) *http.Response {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+	return resp
+}
@@ -0,0 +1,253 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	totpIssuer             = "fiber-sql"
+	twoFactorTokenDuration = 5 * time.Minute
+	recoveryCodeCount      = 10
+)
+
+type ConfirmTwoFactorParams struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type TwoFactorLoginParams struct {
+	Token        string `json:"two_factor_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+type enrollTwoFactorResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	TwoFactorToken    string `json:"two_factor_token"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (s *Service) setupTwoFactorRoutes(router fiber.Router) {
+	router.Post("/enroll", s.requireAuth, s.enrollTwoFactorHandler)
+	router.Post("/confirm", s.requireAuth, s.confirmTwoFactorHandler)
+	router.Post("/login", s.twoFactorLoginHandler)
+}
+
+func (s *Service) enrollTwoFactorHandler(c *fiber.Ctx) error {
+	user, err := s.queries.GetUserByID(c.Context(), currentUserID(c))
+	if err != nil {
+		return err
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return err
+	}
+
+	// Enrolling again before confirming replaces the pending secret.
+	stored, err := s.queries.SetTOTPSecret(c.Context(), user.ID, secret)
+	if err != nil {
+		return err
+	}
+
+	if !stored {
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	return c.JSON(enrollTwoFactorResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+func (s *Service) confirmTwoFactorHandler(c *fiber.Ctx) error {
+	confirmParams := ConfirmTwoFactorParams{}
+
+	if err := c.BodyParser(&confirmParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(confirmParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	user, err := s.queries.GetUserByID(c.Context(), currentUserID(c))
+	if err != nil {
+		return err
+	}
+
+	if user.TOTPEnabledAt.Valid {
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	valid, err := s.useTOTPCode(c, user, confirmParams.Code)
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Invalid two-factor code",
+		})
+	}
+
+	enabled, err := s.queries.EnableTOTP(c.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return c.Status(fiber.StatusConflict).JSON(&fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	codes, err := s.replaceRecoveryCodes(c, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// The plain recovery codes are only ever shown in this response.
+	return c.JSON(recoveryCodesResponse{codes})
+}
+
+func (s *Service) twoFactorLoginHandler(c *fiber.Ctx) error {
+	loginParams := TwoFactorLoginParams{}
+
+	if err := c.BodyParser(&loginParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(loginParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	// The challenge token is single use so every guess needs the password again.
+	token, err := s.queries.ConsumeUserToken(c.Context(), db.TokenPurposeTwoFactorLogin, utils.HashOpaqueToken(loginParams.Token))
+	if err != nil {
+		return err
+	}
+
+	if token.ID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid or expired two-factor token",
+		})
+	}
+
+	user, err := s.queries.GetUserByID(c.Context(), token.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkLoginThrottle(c, user.Email); err != nil {
+		return err
+	}
+
+	valid := false
+	if loginParams.Code != "" {
+		valid, err = s.useTOTPCode(c, user, loginParams.Code)
+		if err != nil {
+			return err
+		}
+	} else {
+		valid, err = s.queries.ConsumeRecoveryCode(c.Context(), user.ID, utils.HashOpaqueToken(utils.NormalizeRecoveryCode(loginParams.RecoveryCode)))
+		if err != nil {
+			return err
+		}
+	}
+
+	if !valid {
+		if err := s.recordLoginFailure(c, user.Email); err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"message": "Invalid two-factor code",
+		})
+	}
+
+	if err := s.clearLoginFailures(c, user.Email); err != nil {
+		return err
+	}
+
+	tokens, err := s.issueTokens(user)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(tokens)
+}
+
+// useTOTPCode checks a code from the user's authenticator and records its
+// period, rejecting codes that were already used (RFC 6238 section 5.2).
+func (s *Service) useTOTPCode(c *fiber.Ctx, user db.User, code string) (bool, error) {
+	if !user.TOTPSecret.Valid {
+		return false, nil
+	}
+
+	counter, ok := utils.ValidateTOTP(user.TOTPSecret.String, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return s.queries.UseTOTPCounter(c.Context(), user.ID, counter)
+}
+
+func (s *Service) createTwoFactorChallenge(c *fiber.Ctx, userID string) (twoFactorChallengeResponse, error) {
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return twoFactorChallengeResponse{}, err
+	}
+
+	_, err = s.queries.CreateUserToken(c.Context(), db.CreateUserTokenParams{
+		ID:        s.idGen.Generate(),
+		UserID:    userID,
+		Purpose:   db.TokenPurposeTwoFactorLogin,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(twoFactorTokenDuration).Unix(),
+	})
+	if err != nil {
+		return twoFactorChallengeResponse{}, err
+	}
+
+	return twoFactorChallengeResponse{TwoFactorRequired: true, TwoFactorToken: token}, nil
+}
+
+func (s *Service) replaceRecoveryCodes(c *fiber.Ctx, userID string) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.queries.DeleteRecoveryCodes(c.Context(), userID); err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		err := s.queries.CreateRecoveryCode(c.Context(), db.CreateRecoveryCodeParams{
+			ID:       s.idGen.Generate(),
+			UserID:   userID,
+			CodeHash: utils.HashOpaqueToken(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,235 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type TwoFactorRoutesTestSuite struct {
+	suite.Suite
+	q    *db.Queries
+	conn *sqlx.DB
+	app  *fiber.App
+}
+
+func (s *TwoFactorRoutesTestSuite) SetupSuite() {
+	conn, err := sqlx.Connect("sqlite3", "file:../db/test.db?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	idGen := utils.NewNanoIDGenerator(21)
+	tokens := utils.NewJWTMaker(testJWTSecret)
+
+	service := NewService(s.q, s.app, idGen, tokens, testHasher, &recordingMailer{}, Config{})
+	service.SetupV1Routes()
+
+	seedDataIntoDb(s.q)
+}
+
+func (s *TwoFactorRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Exec("DELETE FROM login_attempts")
+	s.conn.Close()
+}
+
+func (s *TwoFactorRoutesTestSuite) TestLoginWithoutTwoFactor() {
+	var tokens tokenResponse
+	s.checkReqStatus(s.jsonRequest("/api/v1/auth/login", "", LoginParams{Email: "johndoe@example.com", Password: "password"}), fiber.StatusOK, &tokens)
+	s.NotEmpty(tokens.AccessToken)
+}
+
+func (s *TwoFactorRoutesTestSuite) TestEnrollAndLoginWithCode() {
+	secret, _ := s.enable("janedoe@example.com")
+
+	challenge := s.challenge("janedoe@example.com")
+	s.True(challenge.TwoFactorRequired)
+	s.NotEmpty(challenge.TwoFactorToken)
+
+	// the code from confirming cannot be used again, so take the next one
+	code, err := utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	s.NoError(err)
+
+	var tokens tokenResponse
+	req := s.jsonRequest("/api/v1/auth/2fa/login", "", TwoFactorLoginParams{Token: challenge.TwoFactorToken, Code: code})
+	s.checkReqStatus(req, fiber.StatusOK, &tokens)
+	s.NotEmpty(tokens.AccessToken)
+
+	// challenge tokens are single use
+	req = s.jsonRequest("/api/v1/auth/2fa/login", "", TwoFactorLoginParams{Token: challenge.TwoFactorToken, Code: code})
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *TwoFactorRoutesTestSuite) TestCodesCannotBeReplayed() {
+	secret, _ := s.enable("replay@example.com")
+
+	// the code that confirmed enrollment
+	code, err := utils.TOTPCode(secret, time.Now())
+	s.NoError(err)
+
+	challenge := s.challenge("replay@example.com")
+	req := s.jsonRequest("/api/v1/auth/2fa/login", "", TwoFactorLoginParams{Token: challenge.TwoFactorToken, Code: code})
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+
+	next, err := utils.TOTPCode(secret, time.Now().Add(30*time.Second))
+	s.NoError(err)
+
+	challenge = s.challenge("replay@example.com")
+	req = s.jsonRequest("/api/v1/auth/2fa/login", "", TwoFactorLoginParams{Token: challenge.TwoFactorToken, Code: next})
+	s.checkReqStatus(req, fiber.StatusOK, nil)
+
+	// neither the same code nor an older one works after that
+	for _, replayed := range []string{next, code} {
+		challenge = s.challenge("replay@example.com")
+		req = s.jsonRequest("/api/v1/auth/2fa/login", "", TwoFactorLoginParams{Token: challenge.TwoFactorToken, Code: replayed})
+		s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+	}
+}
+
+func (s *TwoFactorRoutesTestSuite) TestLoginWithWrongCode() {
+	s.enable("ashwin@example.com")
+
+	challenge := s.challenge("ashwin@example.com")
+	req := s.jsonRequest("/api/v1/auth/2fa/login", "", TwoFactorLoginParams{Token: challenge.TwoFactorToken, Code: "000000"})
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *TwoFactorRoutesTestSuite) TestLoginWithRecoveryCode() {
+	_, codes := s.enable("recovery@example.com")
+	s.Len(codes, recoveryCodeCount)
+
+	challenge := s.challenge("recovery@example.com")
+	req := s.jsonRequest("/api/v1/auth/2fa/login", "", TwoFactorLoginParams{Token: challenge.TwoFactorToken, RecoveryCode: codes[0]})
+	s.checkReqStatus(req, fiber.StatusOK, nil)
+
+	// recovery codes are single use
+	challenge = s.challenge("recovery@example.com")
+	req = s.jsonRequest("/api/v1/auth/2fa/login", "", TwoFactorLoginParams{Token: challenge.TwoFactorToken, RecoveryCode: codes[0]})
+	s.checkReqStatus(req, fiber.StatusUnauthorized, nil)
+}
+
+func (s *TwoFactorRoutesTestSuite) TestConfirmWithWrongCode() {
+	accessToken := s.signUp("wrongcode@example.com")
+
+	s.checkReqStatus(s.jsonRequest("/api/v1/auth/2fa/enroll", accessToken, nil), fiber.StatusOK, nil)
+
+	req := s.jsonRequest("/api/v1/auth/2fa/confirm", accessToken, ConfirmTwoFactorParams{Code: "000000"})
+	s.checkReqStatus(req, fiber.StatusBadRequest, nil)
+}
+
+func (s *TwoFactorRoutesTestSuite) TestEnrollTwiceAfterConfirm() {
+	accessToken := s.signUp("twice@example.com")
+	s.confirm(accessToken)
+
+	s.checkReqStatus(s.jsonRequest("/api/v1/auth/2fa/enroll", accessToken, nil), fiber.StatusConflict, nil)
+}
+
+func (s *TwoFactorRoutesTestSuite) TestEnrollRequiresAuth() {
+	s.checkReqStatus(s.jsonRequest("/api/v1/auth/2fa/enroll", "", nil), fiber.StatusUnauthorized, nil)
+}
+
+func TestTwoFactorRoutes(t *testing.T) {
+	suite.Run(t, new(TwoFactorRoutesTestSuite))
+}
+
+// enable turns on two-factor authentication for the user, creating it first
+// when it is not part of the seed data.
+func (s *TwoFactorRoutesTestSuite) enable(email string) (string, []string) {
+	s.T().Helper()
+	user, err := s.q.GetUserByEmail(context.Background(), email)
+	s.NoError(err)
+
+	var accessToken string
+	if user.Email == "" {
+		accessToken = s.signUp(email)
+	} else {
+		var tokens tokenResponse
+		s.checkReqStatus(s.jsonRequest("/api/v1/auth/login", "", LoginParams{Email: email, Password: "password"}), fiber.StatusOK, &tokens)
+		accessToken = tokens.AccessToken
+	}
+
+	return s.confirm(accessToken)
+}
+
+func (s *TwoFactorRoutesTestSuite) confirm(accessToken string) (string, []string) {
+	s.T().Helper()
+	var enrollment enrollTwoFactorResponse
+	s.checkReqStatus(s.jsonRequest("/api/v1/auth/2fa/enroll", accessToken, nil), fiber.StatusOK, &enrollment)
+	s.Contains(enrollment.URI, "secret="+enrollment.Secret)
+
+	code, err := utils.TOTPCode(enrollment.Secret, time.Now())
+	s.NoError(err)
+
+	var codes recoveryCodesResponse
+	s.checkReqStatus(s.jsonRequest("/api/v1/auth/2fa/confirm", accessToken, ConfirmTwoFactorParams{Code: code}), fiber.StatusOK, &codes)
+
+	return enrollment.Secret, codes.RecoveryCodes
+}
+
+func (s *TwoFactorRoutesTestSuite) signUp(email string) string {
+	s.T().Helper()
+	req := s.jsonRequest("/api/v1/users", "", db.CreateUserParams{ID: email, Email: email, Password: "password"})
+	s.checkReqStatus(req, fiber.StatusCreated, nil)
+
+	var tokens tokenResponse
+	s.checkReqStatus(s.jsonRequest("/api/v1/auth/login", "", LoginParams{Email: email, Password: "password"}), fiber.StatusOK, &tokens)
+	return tokens.AccessToken
+}
+
+func (s *TwoFactorRoutesTestSuite) challenge(email string) twoFactorChallengeResponse {
+	s.T().Helper()
+	var challenge twoFactorChallengeResponse
+	s.checkReqStatus(s.jsonRequest("/api/v1/auth/login", "", LoginParams{Email: email, Password: "password"}), fiber.StatusOK, &challenge)
+	return challenge
+}
+
+func (s *TwoFactorRoutesTestSuite) jsonRequest(path string, accessToken string, params interface{}) *http.Request {
+	var body []byte
+	if params != nil {
+		body, _ = json.Marshal(params)
+	}
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return req
+}
+
+func (s *TwoFactorRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
@@ -1,10 +1,11 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/ashwins93/fiber-sql/db"
 	"github.com/ashwins93/fiber-sql/utils"
 	"github.com/gofiber/fiber/v2"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func (s *Service) setupUserRoutes(router fiber.Router) {
@@ -28,39 +29,90 @@ func (s *Service) createUserHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errors)
 	}
 
-	existingUser, err := s.queries.GetUserByEmail(c.Context(), userParams.Email)
+	hash, err := s.hasher.Hash(userParams.Password)
 	if err != nil {
 		return err
 	}
 
-	if existingUser.Email != "" {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": "Email already exists",
-		})
-	}
+	userParams.Password = hash
+
+	// The email check and the insert share a transaction, so two requests
+	// for the same email cannot both pass the check.
+	var user *db.User
+	emailTaken := false
+	err = s.queries.RunInTx(c.Context(), func(q *db.Queries) error {
+		existingUser, err := q.GetUserByEmail(c.Context(), userParams.Email)
+		if err != nil {
+			return err
+		}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(userParams.Password), bcrypt.DefaultCost)
+		emailTaken = existingUser.Email != ""
+		if emailTaken {
+			return nil
+		}
+
+		user, err = q.CreateUser(c.Context(), userParams)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	userParams.Password = string(hash)
+	if emailTaken {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Email already exists",
+		})
+	}
 
-	user, err := s.queries.CreateUser(c.Context(), userParams)
-	if err != nil {
-		return err
+	// The account exists either way, so a mail failure must not make the
+	// client retry into "Email already exists".
+	if err := s.sendVerificationEmail(c, user.ID, user.Email); err != nil {
+		log.Printf("send verification email to user %s: %v", user.ID, err)
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(user)
 }
 
+var userListSchema = &listSchema[db.User]{
+	filters: map[string]listFilter{
+		"email_contains": {`email LIKE ? ESCAPE '\'`, parseContains},
+		"name_contains":  {`name LIKE ? ESCAPE '\'`, parseContains},
+		"created_after":  {"created_at > ?", parseTime},
+		"created_before": {"created_at < ?", parseTime},
+		"verified":       {"(email_verified_at IS NOT NULL) = ?", parseBool},
+	},
+	sorts: map[string]func(db.User) interface{}{
+		"id":         func(user db.User) interface{} { return user.ID },
+		"email":      func(user db.User) interface{} { return user.Email },
+		"created_at": func(user db.User) interface{} { return user.CreatedAt },
+	},
+	id:          func(user db.User) string { return user.ID },
+	defaultSort: "id",
+}
+
 func (s *Service) findUsersHandler(c *fiber.Ctx) error {
-	users, err := s.queries.GetUsers(c.Context())
+	pageParams := utils.PageParams{}
+
+	if err := c.QueryParser(&pageParams); err != nil {
+		return err
+	}
+
+	errors := utils.ValidateStruct(pageParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	query, sortBy, errors := userListSchema.compile(c, pageParams)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	users, err := s.queries.GetUsers(c.Context(), query)
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(users)
+	return c.JSON(utils.NewPage(users, pageParams.PageLimit(), userListSchema.cursor(sortBy)))
 }
 
 func (s *Service) findUserByIDHandler(c *fiber.Ctx) error {
@@ -104,6 +156,20 @@ func (s *Service) updateUserHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errors)
 	}
 
+	if userParams.Password.Valid {
+		errors = utils.ValidatePassword("UpdateUserParams.Password", userParams.Password.String,
+			existingUser.Email, existingUser.Name.String, userParams.Name.String)
+		if errors != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(errors)
+		}
+
+		hash, err := s.hasher.Hash(userParams.Password.String)
+		if err != nil {
+			return err
+		}
+		userParams.Password.String = hash
+	}
+
 	user, err := s.queries.UpdateUser(c.Context(), userParams)
 	if err != nil {
 		return err
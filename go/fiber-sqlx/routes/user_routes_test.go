@@ -38,7 +38,7 @@ func (s *UserRoutesTestSuite) SetupSuite() {
 	})
 	idGen := utils.NewNanoIDGenerator(21)
 
-	service := NewService(s.q, s.app, idGen)
+	service := NewService(s.q, s.app, idGen, utils.NewJWTMaker(testJWTSecret), testHasher, &recordingMailer{}, Config{})
 	service.SetupV1Routes()
 
 	seedDataIntoDb(s.q)
@@ -71,12 +71,88 @@ func (s *UserRoutesTestSuite) TearDownSuite() {
 func (s *UserRoutesTestSuite) TestGetUsers() {
 	req := httptest.NewRequest("GET", "/api/v1/users", nil)
 
-	var users []db.User
-	s.checkReqStatus(req, fiber.StatusOK, &users)
+	var page utils.Page[db.User]
+	s.checkReqStatus(req, fiber.StatusOK, &page)
 
-	if len(users) != 3 {
-		s.T().Errorf("Expected 3 users but got %d", len(users))
+	if len(page.Data) != 3 {
+		s.T().Errorf("Expected 3 users but got %d", len(page.Data))
 	}
+	s.Nil(page.NextCursor)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersPaginated() {
+	var ids []string
+	cursor := ""
+	for pages := 0; pages < 3; pages++ {
+		req := httptest.NewRequest("GET", "/api/v1/users?limit=2&cursor="+cursor, nil)
+
+		var page utils.Page[db.User]
+		s.checkReqStatus(req, fiber.StatusOK, &page)
+		for _, user := range page.Data {
+			ids = append(ids, user.ID)
+		}
+
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+
+	s.Equal([]string{"1", "2", "3"}, ids)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersFiltered() {
+	s.Equal([]string{"1", "2"}, s.listUserIDs("/api/v1/users?email_contains=DOE"))
+	s.Equal([]string{"3"}, s.listUserIDs("/api/v1/users?name_contains=ash&email_contains=example"))
+	s.Empty(s.listUserIDs("/api/v1/users?email_contains=%25"))
+	s.Empty(s.listUserIDs("/api/v1/users?created_after=2999-01-01T00:00:00Z"))
+	s.Len(s.listUserIDs("/api/v1/users?created_after=2023-01-01&verified=false&email_contains="), 3)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersSorted() {
+	s.Equal([]string{"3", "2", "1"}, s.listUserIDs("/api/v1/users?sort=-id"))
+	s.Equal([]string{"3", "2", "1"}, s.listUserIDs("/api/v1/users?sort=email"))
+
+	// the seeded users share a created_at, so pages fall back to the id
+	var ids []string
+	cursor := ""
+	for pages := 0; pages < 3; pages++ {
+		req := httptest.NewRequest("GET", "/api/v1/users?sort=-created_at&limit=1&cursor="+cursor, nil)
+
+		var page utils.Page[db.User]
+		s.checkReqStatus(req, fiber.StatusOK, &page)
+		for _, user := range page.Data {
+			ids = append(ids, user.ID)
+		}
+
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+	s.Equal([]string{"3", "2"}, ids[:2])
+	s.Len(ids, 3)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersRejectsUnknownFields() {
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?password=secret", nil), fiber.StatusBadRequest, &errors)
+	s.Len(errors, 1)
+	s.Equal("password", errors[0].FailedField)
+
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?sort=password", nil), fiber.StatusBadRequest, nil)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?created_after=yesterday", nil), fiber.StatusBadRequest, nil)
+
+	var page utils.Page[db.User]
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?limit=1", nil), fiber.StatusOK, &page)
+	s.NotNil(page.NextCursor)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?sort=email&cursor="+*page.NextCursor, nil), fiber.StatusBadRequest, nil)
+}
+
+func (s *UserRoutesTestSuite) TestGetUsersInvalidPage() {
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?limit=-1", nil), fiber.StatusBadRequest, nil)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?limit=101", nil), fiber.StatusBadRequest, nil)
+	s.checkReqStatus(httptest.NewRequest("GET", "/api/v1/users?cursor=not-a-cursor!", nil), fiber.StatusBadRequest, nil)
 }
 
 func (s *UserRoutesTestSuite) TestGetUser() {
@@ -126,6 +202,19 @@ func (s *UserRoutesTestSuite) TestCreateUserWithInvalidBody() {
 	s.Contains(strings.ToLower(errors[0].FailedField), "password")
 }
 
+func (s *UserRoutesTestSuite) TestCreateUserPasswordContainingEmail() {
+	requestBody := []byte(`{"email": "marigold@example.com", "password": "marigold2023"}`)
+	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	var errors []*utils.ErrorResponse
+	s.checkReqStatus(req, fiber.StatusBadRequest, &errors)
+
+	s.Equal(1, len(errors))
+	s.Equal("password", errors[0].Tag)
+	s.Equal(utils.PasswordRuleUserInfo, errors[0].Value)
+}
+
 func (s *UserRoutesTestSuite) TestUpdateUserName() {
 	requestBody := []byte(`{"name": "Ashwin S"}`)
 	req := httptest.NewRequest("PATCH", "/api/v1/users/1", bytes.NewBuffer(requestBody))
@@ -173,3 +262,15 @@ func (s *UserRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus i
 		s.NoError(err)
 	}
 }
+
+func (s *UserRoutesTestSuite) listUserIDs(path string) []string {
+	s.T().Helper()
+	var page utils.Page[db.User]
+	s.checkReqStatus(httptest.NewRequest("GET", path, nil), fiber.StatusOK, &page)
+
+	ids := []string{}
+	for _, user := range page.Data {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
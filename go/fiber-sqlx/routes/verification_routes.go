@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/mail"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const verificationTokenDuration = 24 * time.Hour
+
+func (s *Service) setupVerificationRoutes(router fiber.Router) {
+	router.Get("/verify", s.verifyEmailHandler)
+}
+
+func (s *Service) verifyEmailHandler(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Missing verification token",
+		})
+	}
+
+	verificationToken, err := s.queries.ConsumeUserToken(c.Context(), db.TokenPurposeEmailVerification, utils.HashOpaqueToken(token))
+	if err != nil {
+		return err
+	}
+
+	if verificationToken.ID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Invalid or expired verification token",
+		})
+	}
+
+	user, err := s.queries.MarkEmailVerified(c.Context(), verificationToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(user)
+}
+
+// sendVerificationEmail replaces any outstanding verification token for the
+// user with a new one and mails the link that consumes it.
+func (s *Service) sendVerificationEmail(c *fiber.Ctx, userID string, email string) error {
+	if err := s.queries.DeleteUserTokens(c.Context(), userID, db.TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.queries.CreateUserToken(c.Context(), db.CreateUserTokenParams{
+		ID:        s.idGen.Generate(),
+		UserID:    userID,
+		Purpose:   db.TokenPurposeEmailVerification,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(verificationTokenDuration).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/v1/auth/verify?token=%s", s.config.PublicURL, url.QueryEscape(token))
+
+	msg, err := mail.NewMessage(email, "Verify your email address", "email_verification", fiber.Map{
+		"Link":      link,
+		"ExpiresIn": verificationTokenDuration,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(c.Context(), msg)
+}
@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ashwins93/fiber-sql/db"
+	"github.com/ashwins93/fiber-sql/utils"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type VerificationRoutesTestSuite struct {
+	suite.Suite
+	q      *db.Queries
+	conn   *sqlx.DB
+	app    *fiber.App
+	mailer *recordingMailer
+}
+
+func (s *VerificationRoutesTestSuite) SetupSuite() {
+	conn, err := sqlx.Connect("sqlite3", "file:../db/test.db?_fk=1")
+	if err != nil {
+		panic(err)
+	}
+
+	s.q = db.NewDb(conn)
+	s.conn = conn
+	s.app = fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+	})
+	s.mailer = &recordingMailer{}
+	idGen := utils.NewNanoIDGenerator(21)
+
+	service := NewService(s.q, s.app, idGen, utils.NewJWTMaker(testJWTSecret), testHasher, s.mailer, Config{PublicURL: testPublicURL})
+	service.SetupV1Routes()
+}
+
+func (s *VerificationRoutesTestSuite) TearDownSuite() {
+	// cleanup
+	s.conn.Exec("DELETE FROM users")
+	s.conn.Close()
+}
+
+func (s *VerificationRoutesTestSuite) TestVerifyEmail() {
+	link := s.signup("verify@example.com")
+
+	var user db.User
+	s.checkReqStatus(httptest.NewRequest("GET", link, nil), fiber.StatusOK, &user)
+
+	s.Equal("verify@example.com", user.Email)
+	s.True(user.EmailVerifiedAt.Valid)
+
+	s.checkReqStatus(httptest.NewRequest("GET", link, nil), fiber.StatusBadRequest, nil)
+}
+
+func (s *VerificationRoutesTestSuite) TestVerifyWithInvalidToken() {
+	req := httptest.NewRequest("GET", "/api/v1/auth/verify?token=invalid", nil)
+
+	s.checkReqStatus(req, fiber.StatusBadRequest, nil)
+}
+
+func (s *VerificationRoutesTestSuite) TestLinkIgnoresHostHeader() {
+	req := s.jsonRequest("/api/v1/users", db.CreateUserParams{Email: "spoofed@example.com", Password: "password"})
+	req.Host = "evil.example"
+	s.checkReqStatus(req, fiber.StatusCreated, nil)
+
+	link := s.lastLink()
+	s.Equal(testPublicURL, link.Scheme+"://"+link.Host)
+	s.Equal("/api/v1/auth/verify", link.Path)
+}
+
+func (s *VerificationRoutesTestSuite) TestSignupSucceedsWhenMailFails() {
+	s.mailer.fail(errors.New("connection refused"))
+	defer s.mailer.fail(nil)
+
+	var user db.User
+	req := s.jsonRequest("/api/v1/users", db.CreateUserParams{Email: "unmailed@example.com", Password: "password"})
+	s.checkReqStatus(req, fiber.StatusCreated, &user)
+	s.Equal("unmailed@example.com", user.Email)
+}
+
+func TestVerificationRoutes(t *testing.T) {
+	suite.Run(t, new(VerificationRoutesTestSuite))
+}
+
+// signup creates a user through the API and returns the path of the
+// verification link that was mailed to them.
+func (s *VerificationRoutesTestSuite) signup(email string) string {
+	s.T().Helper()
+	req := s.jsonRequest("/api/v1/users", db.CreateUserParams{Email: email, Password: "password"})
+
+	var user db.User
+	s.checkReqStatus(req, fiber.StatusCreated, &user)
+	s.False(user.EmailVerifiedAt.Valid)
+
+	msg, ok := s.mailer.last()
+	s.True(ok)
+	s.Equal(email, msg.To)
+
+	return s.lastLink().RequestURI()
+}
+
+// lastLink returns the link at the end of the last mail that was sent.
+func (s *VerificationRoutesTestSuite) lastLink() *url.URL {
+	s.T().Helper()
+	msg, ok := s.mailer.last()
+	s.Require().True(ok)
+
+	lines := strings.Split(strings.TrimSpace(msg.Text), "\n")
+	link, err := url.Parse(lines[len(lines)-1])
+	s.NoError(err)
+	return link
+}
+
+func (s *VerificationRoutesTestSuite) jsonRequest(path string, params interface{}) *http.Request {
+	body, _ := json.Marshal(params)
+	req := httptest.NewRequest("POST", path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *VerificationRoutesTestSuite) checkReqStatus(req *http.Request, expectedStatus int, out interface{}) {
+	s.T().Helper()
+	resp, err := s.app.Test(req, -1)
+	s.NoError(err)
+
+	s.Equal(expectedStatus, resp.StatusCode)
+
+	if out != nil {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		s.T().Log(string(body))
+		s.NoError(err)
+
+		err = json.Unmarshal(body, &out)
+		s.NoError(err)
+	}
+}
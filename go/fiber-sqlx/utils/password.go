@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// ErrPasswordTooLong is returned by the bcrypt hasher rather than silently
+// ignoring everything past BcryptMaxPasswordBytes.
+var ErrPasswordTooLong = errors.New("password is too long for bcrypt")
+
+// BcryptMaxPasswordBytes is the most of a password bcrypt reads.
+const BcryptMaxPasswordBytes = 72
+
+// PasswordHasher hashes passwords into self-describing strings. Verify
+// accepts hashes made by any supported algorithm and reports whether the
+// hash should be replaced with one made by this hasher.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) (ok bool, needsRehash bool, err error)
+}
+
+type Argon2idParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+type argon2idHasher struct {
+	params Argon2idParams
+}
+
+func NewArgon2idHasher(params Argon2idParams) PasswordHasher {
+	return &argon2idHasher{params}
+}
+
+// Hash returns a PHC string such as $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2idHasher) Verify(password, hash string) (bool, bool, error) {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		ok, err := verifyPassword(password, hash)
+		return ok, ok, err
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+
+	current := h.params
+	current.SaltLength = uint32(len(salt))
+	return true, params != current, nil
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(cost int) PasswordHasher {
+	return &bcryptHasher{cost}
+}
+
+// Hash returns a standard $2a$ bcrypt string, which PHC accepts as is.
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	if len(password) > BcryptMaxPasswordBytes {
+		return "", ErrPasswordTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(hash), err
+}
+
+func (h *bcryptHasher) Verify(password, hash string) (bool, bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		ok, err := verifyPassword(password, hash)
+		return ok, ok, err
+	}
+
+	ok, err := verifyPassword(password, hash)
+	if !ok || err != nil {
+		return false, false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, cost != h.cost, err
+}
+
+// verifyPassword checks a password against a hash of any supported format.
+// Empty hashes belong to passwordless accounts and never match.
+func verifyPassword(password, hash string) (bool, error) {
+	switch {
+	case hash == "":
+		return false, nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		ok, _, err := (&argon2idHasher{}).Verify(password, hash)
+		return ok, err
+	case strings.HasPrefix(hash, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownHashFormat
+	}
+}
+
+func decodeArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password rules reported as the Value of a failed "password" validation.
+const (
+	PasswordRuleMinLength   = "min_length"
+	PasswordRuleMaxLength   = "max_length"
+	PasswordRuleCharClasses = "char_classes"
+	PasswordRuleEntropy     = "entropy"
+	PasswordRuleUserInfo    = "user_info"
+	PasswordRuleBreached    = "breached"
+	// PasswordRuleUnchecked means the breached password list could not be
+	// read, so the password was refused without being judged.
+	PasswordRuleUnchecked = "unchecked"
+)
+
+type PasswordPolicy struct {
+	// MinLength and MaxLength count characters, not bytes.
+	MinLength int
+	MaxLength int
+	// MaxBytes caps the UTF-8 length for hashers that only read so many
+	// bytes, like bcrypt. Zero means no cap.
+	MaxBytes int
+	// MinCharClasses is how many of lower case, upper case, digits and
+	// symbols a password must mix.
+	MinCharClasses int
+	// MinEntropy is the lowest estimated strength in bits.
+	MinEntropy float64
+	// DisallowUserInfo rejects passwords containing the user's email or
+	// name.
+	DisallowUserInfo bool
+	// Breached is checked last. A nil list skips the check.
+	Breached BreachedPasswords
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:        8,
+	MaxLength:        64,
+	MinCharClasses:   1,
+	MinEntropy:       30,
+	DisallowUserInfo: true,
+}
+
+var passwordPolicy = DefaultPasswordPolicy
+
+// SetPasswordPolicy replaces the policy used by the "password" validation
+// tag. It is meant to be called once at startup.
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// Check returns the first rule the password breaks, or "" if it breaks none.
+// userInputs are values such as the email and name that the password must
+// not contain.
+func (p PasswordPolicy) Check(password string, userInputs ...string) (string, error) {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return PasswordRuleMinLength, nil
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return PasswordRuleMaxLength, nil
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return PasswordRuleMaxLength, nil
+	}
+
+	if len(charClasses(password)) < p.MinCharClasses {
+		return PasswordRuleCharClasses, nil
+	}
+
+	if PasswordEntropy(password) < p.MinEntropy {
+		return PasswordRuleEntropy, nil
+	}
+
+	if p.DisallowUserInfo && containsUserInfo(password, userInputs) {
+		return PasswordRuleUserInfo, nil
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return "", err
+		}
+		if breached {
+			return PasswordRuleBreached, nil
+		}
+	}
+
+	return "", nil
+}
+
+// PasswordEntropy estimates the strength of a password in bits from the
+// character classes it uses. Characters that repeat or continue a sequence,
+// as in aaaa or 1234, add nothing.
+func PasswordEntropy(password string) float64 {
+	pool := 0
+	for _, size := range charClasses(password) {
+		pool += size
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	effective := 0
+	prev := rune(-1)
+	for _, r := range password {
+		if d := r - prev; d < -1 || d > 1 {
+			effective++
+		}
+		prev = r
+	}
+
+	return float64(effective) * math.Log2(float64(pool))
+}
+
+// charClasses maps each class the password uses to the number of
+// characters in it.
+func charClasses(password string) map[string]int {
+	classes := map[string]int{}
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			classes["lower"] = 26
+		case r >= 'A' && r <= 'Z':
+			classes["upper"] = 26
+		case r >= '0' && r <= '9':
+			classes["digit"] = 10
+		case r < utf8.RuneSelf:
+			classes["symbol"] = 33
+		default:
+			// Other scripts are too varied to size, so count them as one
+			// large class.
+			classes["other"] = 100
+		}
+	}
+	return classes
+}
+
+// containsUserInfo reports whether the password contains a word of three or
+// more letters or digits taken from any of the inputs, ignoring case. Only
+// the local part of an email address is used.
+func containsUserInfo(password string, inputs []string) bool {
+	password = strings.ToLower(password)
+	for _, input := range inputs {
+		if at := strings.LastIndex(input, "@"); at >= 0 {
+			input = input[:at]
+		}
+
+		words := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if utf8.RuneCountInString(word) >= 3 && strings.Contains(password, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type BreachedPasswords interface {
+	Contains(password string) (bool, error)
+}
+
+type breachedPasswordDir struct {
+	dir string
+}
+
+// NewBreachedPasswordDir checks passwords against a local copy of a
+// k-anonymity range dataset such as Pwned Passwords. The directory holds one
+// file per five character upper case SHA-1 prefix, named after the prefix,
+// with a SUFFIX:COUNT line for each breached hash. A missing file means no
+// hash with that prefix is known.
+func NewBreachedPasswordDir(dir string) BreachedPasswords {
+	return &breachedPasswordDir{dir}
+}
+
+func (b *breachedPasswordDir) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(filepath.Join(b.dir, prefix))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		candidate, count, _ := strings.Cut(line, ":")
+
+		// Padded range responses add fake suffixes with a count of 0.
+		if strings.EqualFold(candidate, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TOTPTestSuite struct {
+	suite.Suite
+	secret string
+}
+
+func (s *TOTPTestSuite) SetupSuite() {
+	// the SHA1 seed from the RFC 6238 test vectors
+	s.secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+}
+
+func (s *TOTPTestSuite) TestRFC6238Vectors() {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, v := range vectors {
+		counter, ok := ValidateTOTP(s.secret, v.code, time.Unix(v.unix, 0))
+		s.True(ok, "code %s at %d", v.code, v.unix)
+		s.Equal(v.unix/totpPeriod, counter)
+	}
+}
+
+func (s *TOTPTestSuite) TestTOTPCode() {
+	code, err := TOTPCode(s.secret, time.Unix(1111111109, 0))
+	s.NoError(err)
+	s.Equal("081804", code)
+}
+
+func (s *TOTPTestSuite) TestClockDrift() {
+	counter, ok := ValidateTOTP(s.secret, "287082", time.Unix(59+totpPeriod, 0))
+	s.True(ok)
+	s.Equal(int64(1), counter, "The counter is the one the code was made for")
+
+	_, ok = ValidateTOTP(s.secret, "287082", time.Unix(59+3*totpPeriod, 0))
+	s.False(ok)
+}
+
+func (s *TOTPTestSuite) TestInvalidCodes() {
+	_, ok := ValidateTOTP(s.secret, "000000", time.Unix(59, 0))
+	s.False(ok)
+	_, ok = ValidateTOTP(s.secret, "28708", time.Unix(59, 0))
+	s.False(ok)
+	_, ok = ValidateTOTP("not base32!", "287082", time.Unix(59, 0))
+	s.False(ok)
+}
+
+func (s *TOTPTestSuite) TestGenerateTOTPSecret() {
+	secret, err := GenerateTOTPSecret()
+	s.NoError(err)
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	s.NoError(err)
+	s.Len(key, 20)
+}
+
+func (s *TOTPTestSuite) TestTOTPURI() {
+	uri := TOTPURI("fiber-sql", "jane@example.com", "ABC")
+	s.True(strings.HasPrefix(uri, "otpauth://totp/fiber-sql:jane@example.com?"))
+	s.Contains(uri, "secret=ABC")
+	s.Contains(uri, "issuer=fiber-sql")
+}
+
+func (s *TOTPTestSuite) TestGenerateRecoveryCodes() {
+	codes, err := GenerateRecoveryCodes(10)
+	s.NoError(err)
+	s.Len(codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		s.Len(code, 11)
+		s.False(seen[code])
+		seen[code] = true
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	suite.Run(t, new(TOTPTestSuite))
+}